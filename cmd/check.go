@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/policy"
+	"github.com/yardenshoham/skim/pkg/sarif"
+)
+
+// checkUnknownGVK is the SARIF rule of manifests with unknown GVKs.
+const checkUnknownGVK = "unknown-gvk"
+
+// sarifRules are the rules of SARIF logs, results refer to them by their index.
+var sarifRules = []sarif.ReportingDescriptor{
+	sarifRule(policy.CheckInvalidReference, "InvalidReference", "Image references must be valid.", sarif.LevelError),
+	sarifRule(checkUnknownGVK, "UnknownGVK", "Manifests should have a Group Version Kind skim knows how to extract images from.", sarif.LevelWarning),
+	sarifRule(policy.CheckAllowedRegistries, "AllowedRegistries", "Images must come from an allowed registry.", sarif.LevelError),
+	sarifRule(policy.CheckForbidLatest, "ForbidLatest", "Images must not use the latest tag.", sarif.LevelError),
+	sarifRule(policy.CheckRequireTag, "RequireTag", "Images must have a tag or digest.", sarif.LevelError),
+	sarifRule(policy.CheckRequireDigest, "RequireDigest", "Images must be pinned to a digest.", sarif.LevelError),
+	sarifRule(policy.CheckDeniedRepository, "DeniedRepository", "Images must not come from a denied repository.", sarif.LevelError),
+	sarifRule(policy.CheckTagPattern, "TagPattern", "Image tags must match the pattern of their repository.", sarif.LevelError),
+}
+
+func newCheckCmd() *cobra.Command {
+	var sources sourceOptions
+	var policyPath string
+	var format string
+	var checkCmd = &cobra.Command{
+		Use:   "check --policy POLICY PATH [PATH...]",
+		Short: "Check container image references in Kubernetes resources against a policy",
+		Long: `Check container image references in Kubernetes resources against a policy.
+
+The policy is a YAML file with rules, every rule can be scoped to namespaces, kinds and labels:
+
+  rules:
+    - name: production
+      match:
+        namespaces: [production]
+        kinds:
+          - apiVersion: apps/v1
+            kind: Deployment
+        labels:
+          tier: web
+      allowedRegistries: [registry.example.com, docker.io/library]
+      forbidLatest: true
+      requireTag: true
+      requireDigest: true
+      deniedRepositories: [busybox, quay.io/untrusted/*]
+      tagPatterns:
+        - repository: registry.example.com/*
+          pattern: v\d+\.\d+\.\d+
+
+Every violation is reported with the object and location of the image reference and fails the command.
+
+With --format sarif the violations are written as a SARIF 2.1.0 log for code scanning tools, e.g. GitHub code scanning.
+Every check is a rule, violations and invalid references are errors. Manifests with unknown GVKs that are skipped or read
+as free text (--unknown-gvk-behavior skip or freetext) are reported as warnings of the unknown-gvk rule.`,
+		Example: `skim check --policy policy.yaml path/to/manifests/
+skim check --policy policy.yaml --format sarif -u skip path/to/manifests/ > skim.sarif`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			content, err := os.ReadFile(policyPath)
+			if err != nil {
+				return fmt.Errorf("failed to read policy: %w", err)
+			}
+			p, err := policy.Parse(content)
+			if err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			var unknownGVKs []images.UnknownGVK
+			extractor.UnknownGVKFound = func(unknownGVK images.UnknownGVK) {
+				unknownGVKs = append(unknownGVKs, unknownGVK)
+			}
+			occurrences, err := sources.extractOccurrences(cmd, extractor, args)
+			if err != nil {
+				return err
+			}
+			violations := p.Check(occurrences)
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeViolationsText(cmd.OutOrStdout(), violations)
+			case "json":
+				if violations == nil {
+					violations = []policy.Violation{}
+				}
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(violations)
+			case "sarif":
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(newSARIFLog(violations, unknownGVKs))
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			if len(violations) > 0 {
+				return fmt.Errorf("found %d policy violations", len(violations))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(checkCmd)
+	checkCmd.Flags().StringVarP(&policyPath, "policy", "p", "", "Path to the policy file.")
+	_ = checkCmd.MarkFlagRequired("policy")
+	checkCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json, sarif).")
+	return checkCmd
+}
+
+// writeViolationsText writes a line per violation, e.g. "deploy.yaml:21:18: Deployment/prod/web: nginx:latest: the latest tag is forbidden [production/forbid-latest]".
+func writeViolationsText(w io.Writer, violations []policy.Violation) error {
+	var sb strings.Builder
+	for _, violation := range violations {
+		occurrence := violation.Occurrence
+		sb.WriteString(occurrence.Source)
+		if occurrence.Line > 0 {
+			fmt.Fprintf(&sb, ":%d:%d", occurrence.Line, occurrence.Column)
+		}
+		fmt.Fprintf(&sb, ": %s: %s: %s [", occurrence.Object, occurrence.Image, violation.Message)
+		if violation.Rule != "" {
+			sb.WriteString(violation.Rule + "/")
+		}
+		sb.WriteString(violation.Check + "]\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func sarifRule(id string, name string, description string, level string) sarif.ReportingDescriptor {
+	return sarif.ReportingDescriptor{
+		ID:                   id,
+		Name:                 name,
+		ShortDescription:     &sarif.Message{Text: description},
+		DefaultConfiguration: &sarif.Configuration{Level: level},
+	}
+}
+
+// newSARIFLog converts violations and manifests with unknown GVKs into a SARIF log.
+func newSARIFLog(violations []policy.Violation, unknownGVKs []images.UnknownGVK) *sarif.Log {
+	driver := sarif.Driver{
+		Name:           "skim",
+		InformationURI: "https://github.com/yardenshoham/skim",
+		Rules:          sarifRules,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		driver.Version = info.Main.Version
+	}
+	log := sarif.NewLog(driver)
+	run := &log.Runs[0]
+	for _, violation := range violations {
+		occurrence := violation.Occurrence
+		message := fmt.Sprintf("%s: %s", occurrence.Image, violation.Message)
+		properties := map[string]any{"image": occurrence.Image}
+		if violation.Rule != "" {
+			message += fmt.Sprintf(" (rule %s)", violation.Rule)
+			properties["rule"] = violation.Rule
+		}
+		run.Results = append(run.Results, sarifResult(violation.Check, message, occurrence.Object, occurrence.Source, occurrence.Line, occurrence.Column, properties))
+	}
+	for _, unknownGVK := range unknownGVKs {
+		message := fmt.Sprintf("unknown Group Version Kind %s, images of %s aren't checked", unknownGVK.GVK, unknownGVK.Object)
+		run.Results = append(run.Results, sarifResult(checkUnknownGVK, message, unknownGVK.Object, unknownGVK.Source, unknownGVK.Line, unknownGVK.Column, nil))
+	}
+	return log
+}
+
+func sarifResult(check string, message string, object images.Object, source string, line int, column int, properties map[string]any) sarif.Result {
+	index := slices.IndexFunc(sarifRules, func(rule sarif.ReportingDescriptor) bool { return rule.ID == check })
+	location := sarif.Location{
+		PhysicalLocation: sarif.PhysicalLocation{ArtifactLocation: sarif.ArtifactLocation{URI: sarif.URI(source)}},
+		LogicalLocations: []sarif.LogicalLocation{{Name: object.Name, FullyQualifiedName: object.String(), Kind: "resource"}},
+	}
+	if line > 0 {
+		location.PhysicalLocation.Region = &sarif.Region{StartLine: line, StartColumn: column}
+	}
+	return sarif.Result{
+		RuleID:     check,
+		RuleIndex:  index,
+		Level:      sarifRules[index].DefaultConfiguration.Level,
+		Message:    sarif.Message{Text: message},
+		Locations:  []sarif.Location{location},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/policy"
+	"github.com/yardenshoham/skim/pkg/sarif"
+)
+
+const checkManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: production
+  labels:
+    tier: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:latest
+        - name: sidecar
+          image: registry.example.com/sidecar:v1.0.0
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+  namespace: staging
+spec:
+  containers:
+    - name: debug
+      image: busybox
+`
+
+const checkPolicy = `rules:
+  - name: production
+    match:
+      namespaces: [production]
+      labels:
+        tier: web
+    allowedRegistries: [registry.example.com]
+    forbidLatest: true
+  - name: everywhere
+    requireTag: true
+`
+
+func TestCheckCmd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests.yaml")
+	require.NoError(t, os.WriteFile(manifests, []byte(checkManifests), 0o600))
+	policyPath := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(checkPolicy), 0o600))
+
+	checkCmd := newCheckCmd()
+	checkCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	checkCmd.SetOut(&stdout)
+	checkCmd.SetErr(io.Discard)
+	checkCmd.SetArgs([]string{"--policy", policyPath, manifests})
+	require.EqualError(t, checkCmd.Execute(), "found 3 policy violations")
+	require.Equal(t, manifests+":13:18: Deployment/production/web: nginx:latest: registry docker.io is not allowed, allowed registries are registry.example.com [production/allowed-registries]\n"+
+		manifests+":13:18: Deployment/production/web: nginx:latest: the latest tag is forbidden [production/forbid-latest]\n"+
+		manifests+":25:14: Pod/staging/debug: busybox: a tag is required, no tag means latest [everywhere/require-tag]\n", stdout.String())
+
+	checkCmd = newCheckCmd()
+	checkCmd.SilenceUsage = true
+	stdout.Reset()
+	checkCmd.SetOut(&stdout)
+	checkCmd.SetErr(io.Discard)
+	checkCmd.SetArgs([]string{"--policy", policyPath, "-o", "json", manifests})
+	require.Error(t, checkCmd.Execute())
+	var violations []policy.Violation
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &violations))
+	require.Len(t, violations, 3)
+	require.Equal(t, map[string]string{"tier": "web"}, violations[0].Occurrence.Labels)
+	require.Equal(t, "$.spec.template.spec.containers[0].image", violations[0].Occurrence.Path)
+}
+
+func TestCheckCmdCompliant(t *testing.T) {
+	t.Parallel()
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte("rules:\n  - name: tags\n    requireTag: true\n"), 0o600))
+	checkCmd := newCheckCmd()
+	var stdout bytes.Buffer
+	checkCmd.SetOut(&stdout)
+	checkCmd.SetErr(io.Discard)
+	checkCmd.SetArgs([]string{"--policy", policyPath, "../testdata/pod.yaml"})
+	require.NoError(t, checkCmd.Execute())
+	require.Empty(t, stdout.String())
+}
+
+func TestCheckCmdSARIF(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests.yaml")
+	unknown := "---\napiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: gadget\n"
+	require.NoError(t, os.WriteFile(manifests, []byte(checkManifests+unknown), 0o600))
+	policyPath := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(checkPolicy), 0o600))
+
+	checkCmd := newCheckCmd()
+	checkCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	checkCmd.SetOut(&stdout)
+	checkCmd.SetErr(io.Discard)
+	checkCmd.SetArgs([]string{"--policy", policyPath, "--format", "sarif", "-u", "skip", manifests})
+	require.EqualError(t, checkCmd.Execute(), "found 3 policy violations")
+	var log sarif.Log
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &log))
+	require.Equal(t, sarif.Version, log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	require.Equal(t, "skim", run.Tool.Driver.Name)
+	require.Len(t, run.Results, 4)
+	uri := sarif.URI(manifests)
+	require.Equal(t, sarif.Result{
+		RuleID:    policy.CheckForbidLatest,
+		RuleIndex: 3,
+		Level:     sarif.LevelError,
+		Message:   sarif.Message{Text: "nginx:latest: the latest tag is forbidden (rule production)"},
+		Locations: []sarif.Location{{
+			PhysicalLocation: sarif.PhysicalLocation{
+				ArtifactLocation: sarif.ArtifactLocation{URI: uri},
+				Region:           &sarif.Region{StartLine: 13, StartColumn: 18},
+			},
+			LogicalLocations: []sarif.LogicalLocation{{Name: "web", FullyQualifiedName: "Deployment/production/web", Kind: "resource"}},
+		}},
+		Properties: map[string]any{"image": "nginx:latest", "rule": "production"},
+	}, run.Results[1])
+	unknownGVK := run.Results[3]
+	require.Equal(t, "unknown-gvk", unknownGVK.RuleID)
+	require.Equal(t, "unknown-gvk", run.Tool.Driver.Rules[unknownGVK.RuleIndex].ID)
+	require.Equal(t, sarif.LevelWarning, unknownGVK.Level)
+	require.Equal(t, &sarif.Region{StartLine: 28, StartColumn: 7}, unknownGVK.Locations[0].PhysicalLocation.Region)
+	for _, result := range run.Results {
+		require.Equal(t, result.RuleID, run.Tool.Driver.Rules[result.RuleIndex].ID)
+	}
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// maxManifestSize limits the size of manifests read from layouts.
+const maxManifestSize = 4 << 20
+
+// imageSource provides the manifests and blobs of images that are copied.
+type imageSource interface {
+	// manifest returns the content of the manifest with digest.
+	manifest(ctx context.Context, digest string) ([]byte, error)
+	// blob opens the blob with digest, the caller must close it.
+	blob(ctx context.Context, digest string) (io.ReadCloser, error)
+	// has reports whether the source has a manifest, sources may have only some platforms of an index.
+	has(digest string) bool
+}
+
+// imageDestination stores the manifests and blobs of images that are copied.
+type imageDestination interface {
+	// sparse reports whether the destination can store an index without some of its manifests.
+	sparse() bool
+	hasManifest(ctx context.Context, digest string) (bool, error)
+	hasBlob(ctx context.Context, digest string) (bool, error)
+	writeBlob(ctx context.Context, descriptor registry.Descriptor, content io.Reader) error
+	// writeManifest stores a manifest by its digest.
+	writeManifest(ctx context.Context, descriptor registry.Descriptor, content []byte) error
+}
+
+// copyTarget maps an image to the name it is copied to with fn, which must map it to a new name.
+func copyTarget(fn images.RewriteFunc, image string) (string, reference.Reference, error) {
+	target, err := fn(images.Occurrence{Image: image})
+	if err != nil {
+		return "", reference.Reference{}, err
+	}
+	if target == image {
+		return "", reference.Reference{}, fmt.Errorf("no rule maps %s to a new name", image)
+	}
+	ref, err := reference.Parse(target)
+	return target, ref, err
+}
+
+// pushReference returns the reference a copied manifest is pushed to, its tag, latest when it has neither a tag nor a
+// digest as that's what it is pulled by, and its digest otherwise.
+func pushReference(ref reference.Reference) reference.Reference {
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if ref.Tag != "" {
+		ref.Digest = ""
+	}
+	return ref
+}
+
+// copyImage copies the blobs of the manifest with descriptor and content from src to dst. The manifests of an index are
+// copied with their blobs when they are for one of the platforms, or every platform when there are none, and src has them.
+// A single-platform image must be for one of the platforms, its platform is read from its config.
+// The manifest itself isn't written, it is returned for the caller to name. An index that is missing manifests in dst
+// is returned without them unless dst is sparse, its digest then differs.
+func copyImage(ctx context.Context, src imageSource, dst imageDestination, descriptor registry.Descriptor, content []byte, platforms []registry.Platform) (registry.Descriptor, []byte, error) {
+	manifest, err := registry.ParseManifest(descriptor, content)
+	if err != nil {
+		return registry.Descriptor{}, nil, err
+	}
+	if !registry.IsIndex(manifest.MediaType) && len(manifest.Manifests) == 0 {
+		if len(platforms) > 0 {
+			platform, err := configPlatform(ctx, src, manifest)
+			if err != nil {
+				return registry.Descriptor{}, nil, err
+			}
+			if !slices.ContainsFunc(platforms, platform.Matches) {
+				return registry.Descriptor{}, nil, fmt.Errorf("manifest %s is for %s, none of the platforms", descriptor.Digest, platform)
+			}
+		}
+		return descriptor, content, copyBlobs(ctx, src, dst, manifest)
+	}
+	var copied []registry.Descriptor
+	for _, child := range manifest.Manifests {
+		if len(platforms) > 0 && (child.Platform == nil || !slices.ContainsFunc(platforms, child.Platform.Matches)) {
+			continue
+		}
+		if !src.has(child.Digest) {
+			continue
+		}
+		err := copyManifest(ctx, src, dst, child)
+		if err != nil {
+			return registry.Descriptor{}, nil, err
+		}
+		copied = append(copied, child)
+	}
+	if len(copied) == 0 {
+		return registry.Descriptor{}, nil, fmt.Errorf("index %s has no manifests for the platforms", descriptor.Digest)
+	}
+	if len(copied) == len(manifest.Manifests) || dst.sparse() {
+		return descriptor, content, nil
+	}
+	manifest.Manifests = copied
+	content, err = json.Marshal(manifest)
+	if err != nil {
+		return registry.Descriptor{}, nil, err
+	}
+	descriptor.Digest, descriptor.Size = registry.Digest(content), int64(len(content))
+	return descriptor, content, nil
+}
+
+// configPlatform reads the platform of a single-platform image from its config.
+func configPlatform(ctx context.Context, src imageSource, manifest *registry.Manifest) (registry.Platform, error) {
+	if manifest.Config == nil {
+		return registry.Platform{}, errors.New("manifest has no config")
+	}
+	blob, err := src.blob(ctx, manifest.Config.Digest)
+	if err != nil {
+		return registry.Platform{}, fmt.Errorf("failed to read config %s: %w", manifest.Config.Digest, err)
+	}
+	defer blob.Close()
+	var platform registry.Platform
+	err = json.NewDecoder(io.LimitReader(blob, maxManifestSize)).Decode(&platform)
+	if err != nil {
+		return registry.Platform{}, fmt.Errorf("failed to decode config %s: %w", manifest.Config.Digest, err)
+	}
+	return platform, nil
+}
+
+// copyManifest copies a manifest of an index and its blobs unless dst has it.
+func copyManifest(ctx context.Context, src imageSource, dst imageDestination, descriptor registry.Descriptor) error {
+	exists, err := dst.hasManifest(ctx, descriptor.Digest)
+	if err != nil || exists {
+		return err
+	}
+	content, err := src.manifest(ctx, descriptor.Digest)
+	if err != nil {
+		return err
+	}
+	manifest, err := registry.ParseManifest(descriptor, content)
+	if err != nil {
+		return err
+	}
+	err = copyBlobs(ctx, src, dst, manifest)
+	if err != nil {
+		return err
+	}
+	return dst.writeManifest(ctx, descriptor, content)
+}
+
+// copyBlobs copies the config and layers of an image manifest that dst doesn't have.
+func copyBlobs(ctx context.Context, src imageSource, dst imageDestination, manifest *registry.Manifest) error {
+	blobs := slices.Clone(manifest.Layers)
+	if manifest.Config != nil {
+		blobs = append([]registry.Descriptor{*manifest.Config}, blobs...)
+	}
+	for _, descriptor := range blobs {
+		exists, err := dst.hasBlob(ctx, descriptor.Digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		err = copyBlob(ctx, src, dst, descriptor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyBlob(ctx context.Context, src imageSource, dst imageDestination, descriptor registry.Descriptor) error {
+	blob, err := src.blob(ctx, descriptor.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", descriptor.Digest, err)
+	}
+	defer blob.Close()
+	return dst.writeBlob(ctx, descriptor, blob)
+}
+
+// registrySource reads images from a repository of a registry.
+type registrySource struct {
+	client *registry.Client
+	ref    reference.Reference
+}
+
+func (s registrySource) manifest(ctx context.Context, digest string) ([]byte, error) {
+	_, content, err := s.client.Manifest(ctx, reference.Reference{Registry: s.ref.Registry, Repository: s.ref.Repository, Digest: digest})
+	return content, err
+}
+
+func (s registrySource) blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	return s.client.Blob(ctx, s.ref, digest)
+}
+
+func (registrySource) has(string) bool {
+	return true
+}
+
+// registryDestination writes images to a repository of a registry.
+type registryDestination struct {
+	client *registry.Client
+	ref    reference.Reference
+}
+
+func (registryDestination) sparse() bool {
+	return false
+}
+
+func (d registryDestination) hasManifest(ctx context.Context, digest string) (bool, error) {
+	_, err := d.client.Head(ctx, reference.Reference{Registry: d.ref.Registry, Repository: d.ref.Repository, Digest: digest})
+	if errors.Is(err, registry.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d registryDestination) hasBlob(ctx context.Context, digest string) (bool, error) {
+	return d.client.BlobExists(ctx, d.ref, digest)
+}
+
+// writeBlob uploads a blob, content that can't be sent again is spooled to a temporary file first.
+func (d registryDestination) writeBlob(ctx context.Context, descriptor registry.Descriptor, content io.Reader) error {
+	if _, ok := content.(io.ReadSeeker); !ok {
+		file, err := os.CreateTemp("", "skim-blob-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		_, err = io.Copy(file, content)
+		if err != nil {
+			return fmt.Errorf("failed to read blob %s: %w", descriptor.Digest, err)
+		}
+		content = file
+	}
+	return d.client.PushBlob(ctx, d.ref, descriptor.Digest, content)
+}
+
+func (d registryDestination) writeManifest(ctx context.Context, descriptor registry.Descriptor, content []byte) error {
+	_, err := d.client.PushManifest(ctx, reference.Reference{Registry: d.ref.Registry, Repository: d.ref.Repository, Digest: descriptor.Digest}, descriptor.MediaType, content)
+	return err
+}
+
+// layoutSource reads images from an OCI image layout.
+type layoutSource struct {
+	layout *ocilayout.Layout
+}
+
+func (s layoutSource) manifest(_ context.Context, digest string) ([]byte, error) {
+	return s.layout.ReadBlob(digest, maxManifestSize)
+}
+
+func (s layoutSource) blob(_ context.Context, digest string) (io.ReadCloser, error) {
+	return s.layout.Blob(digest)
+}
+
+func (s layoutSource) has(digest string) bool {
+	return s.layout.HasBlob(digest)
+}
+
+// layoutDestination writes images to an OCI image layout tarball.
+type layoutDestination struct {
+	writer *ocilayout.Writer
+}
+
+func (layoutDestination) sparse() bool {
+	return true
+}
+
+func (d layoutDestination) hasManifest(_ context.Context, digest string) (bool, error) {
+	return d.writer.HasBlob(digest), nil
+}
+
+func (d layoutDestination) hasBlob(_ context.Context, digest string) (bool, error) {
+	return d.writer.HasBlob(digest), nil
+}
+
+func (d layoutDestination) writeBlob(_ context.Context, descriptor registry.Descriptor, content io.Reader) error {
+	return d.writer.WriteBlob(descriptor.Digest, descriptor.Size, content)
+}
+
+func (d layoutDestination) writeManifest(_ context.Context, descriptor registry.Descriptor, content []byte) error {
+	return d.writer.WriteBlob(descriptor.Digest, int64(len(content)), bytes.NewReader(content))
+}
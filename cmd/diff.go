@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+func newDiffCmd() *cobra.Command {
+	var sources sourceOptions
+	var git bool
+	var format string
+	var diffCmd = &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Show container images added, removed and changed between two sets of Kubernetes resources",
+		Example: `skim diff old/manifests/ new/manifests/
+helm template chart | skim diff deployed.yaml -
+skim diff --git v1.0.0 v1.1.0 -- deploy/`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if git {
+				if dash := cmd.ArgsLenAtDash(); dash != -1 && dash != 2 {
+					return fmt.Errorf("accepts 2 git refs before --, received %d", dash)
+				}
+				return cobra.MinimumNArgs(2)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			if args[0] == "-" && args[1] == "-" {
+				return fmt.Errorf("only one of OLD and NEW can be stdin")
+			}
+			sides := make([]map[string]struct{}, 2)
+			for i, arg := range args[:2] {
+				sides[i] = make(map[string]struct{})
+				side := sources
+				paths := []string{arg}
+				if git {
+					side.gitRef = arg
+					paths = args[2:]
+				}
+				err = side.extract(cmd, extractor, paths, sides[i])
+				if err != nil {
+					return err
+				}
+			}
+			diff := images.DiffImages(sides[0], sides[1])
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeDiffText(cmd.OutOrStdout(), diff)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(diff)
+			case "markdown":
+				err = writeDiffMarkdown(cmd.OutOrStdout(), diff)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(diffCmd, "fail")
+	sources.addGitRepoFlag(diffCmd, "Path to the git repository (can be bare) used with --git.")
+	diffCmd.Flags().BoolVar(&git, "git", false, "Treat OLD and NEW as git revisions, arguments after -- are paths in their trees.")
+	diffCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json, markdown).")
+	return diffCmd
+}
+
+// changeTarget returns the part of the new reference worth showing next to the old one, usually just the new tag.
+func changeTarget(change images.Change) string {
+	ref, err := reference.Parse(change.To)
+	if err != nil || ref.Version() == "" {
+		return change.To
+	}
+	return ref.Version()
+}
+
+func writeDiffText(w io.Writer, diff images.Diff) error {
+	var sb strings.Builder
+	for _, change := range diff.Changed {
+		fmt.Fprintf(&sb, "~ %s → %s\n", change.From, changeTarget(change))
+	}
+	for _, image := range diff.Added {
+		fmt.Fprintf(&sb, "+ %s\n", image)
+	}
+	for _, image := range diff.Removed {
+		fmt.Fprintf(&sb, "- %s\n", image)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeDiffMarkdown(w io.Writer, diff images.Diff) error {
+	sections := []string{"## Image changes\n"}
+	if diff.Empty() {
+		sections = append(sections, "No image changes.\n")
+	}
+	if len(diff.Changed) > 0 {
+		var sb strings.Builder
+		sb.WriteString("### Changed\n\n| Repository | From | To |\n| --- | --- | --- |\n")
+		for _, change := range diff.Changed {
+			fmt.Fprintf(&sb, "| `%s` | `%s` | `%s` |\n", change.Repository, change.From, change.To)
+		}
+		sections = append(sections, sb.String())
+	}
+	if len(diff.Added) > 0 {
+		sections = append(sections, markdownList("Added", diff.Added))
+	}
+	if len(diff.Removed) > 0 {
+		sections = append(sections, markdownList("Removed", diff.Removed))
+	}
+	_, err := io.WriteString(w, strings.Join(sections, "\n"))
+	return err
+}
+
+func markdownList(title string, items []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n\n", title)
+	for _, item := range items {
+		fmt.Fprintf(&sb, "- `%s`\n", item)
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+func writeDiffFixtures(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.yaml")
+	newPath := filepath.Join(dir, "new.yaml")
+	require.NoError(t, os.WriteFile(oldPath, []byte(`apiVersion: v1
+kind: Pod
+spec:
+  containers:
+    - image: nginx:1.21
+    - image: memcached:1.6
+    - image: busybox:1.35
+`), 0o600))
+	require.NoError(t, os.WriteFile(newPath, []byte(`apiVersion: v1
+kind: Pod
+spec:
+  containers:
+    - image: nginx:1.25
+    - image: redis:7.2
+    - image: busybox:1.35
+`), 0o600))
+	return oldPath, newPath
+}
+
+func TestDiffCmd(t *testing.T) {
+	t.Parallel()
+	oldPath, newPath := writeDiffFixtures(t)
+	diffCmd := newDiffCmd()
+	var stdout bytes.Buffer
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(io.Discard)
+	diffCmd.SetArgs([]string{oldPath, newPath})
+	require.NoError(t, diffCmd.Execute())
+	require.Equal(t, "~ nginx:1.21 → 1.25\n+ redis:7.2\n- memcached:1.6\n", stdout.String())
+}
+
+func TestDiffCmdJSONFromStdin(t *testing.T) {
+	t.Parallel()
+	oldPath, newPath := writeDiffFixtures(t)
+	file, err := os.Open(newPath)
+	require.NoError(t, err)
+	defer file.Close()
+	diffCmd := newDiffCmd()
+	var stdout bytes.Buffer
+	diffCmd.SetIn(file)
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(io.Discard)
+	diffCmd.SetArgs([]string{"-o", "json", oldPath, "-"})
+	require.NoError(t, diffCmd.Execute())
+	var diff images.Diff
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &diff))
+	require.Equal(t, images.Diff{
+		Added:   []string{"redis:7.2"},
+		Removed: []string{"memcached:1.6"},
+		Changed: []images.Change{{Repository: "docker.io/library/nginx", From: "nginx:1.21", To: "nginx:1.25"}},
+	}, diff)
+}
+
+func TestDiffCmdMarkdown(t *testing.T) {
+	t.Parallel()
+	oldPath, newPath := writeDiffFixtures(t)
+	diffCmd := newDiffCmd()
+	var stdout bytes.Buffer
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(io.Discard)
+	diffCmd.SetArgs([]string{"--format", "markdown", oldPath, newPath})
+	require.NoError(t, diffCmd.Execute())
+	require.Equal(t, "## Image changes\n\n"+
+		"### Changed\n\n| Repository | From | To |\n| --- | --- | --- |\n| `docker.io/library/nginx` | `nginx:1.21` | `nginx:1.25` |\n\n"+
+		"### Added\n\n- `redis:7.2`\n\n"+
+		"### Removed\n\n- `memcached:1.6`\n", stdout.String())
+}
+
+func TestDiffCmdGit(t *testing.T) {
+	t.Parallel()
+	repo := newGitRepo(t)
+	diffCmd := newDiffCmd()
+	var stdout bytes.Buffer
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(io.Discard)
+	diffCmd.SetArgs([]string{"--git", "--git-repo", repo, "v1", "v2"})
+	require.NoError(t, diffCmd.Execute())
+	require.Equal(t, "~ example.com/processor:1.2.3 → 1.3.0\n+ busybox:1.35\n+ nginx:1.21.0\n", stdout.String())
+
+	diffCmd = newDiffCmd()
+	stdout.Reset()
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(io.Discard)
+	diffCmd.SetArgs([]string{"--git", "--git-repo", repo, "v1", "v2", "--", "deploy"})
+	require.NoError(t, diffCmd.Execute())
+	require.Equal(t, "~ example.com/processor:1.2.3 → 1.3.0\n", stdout.String())
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+const (
+	// resourceListAPIVersion is the apiVersion of the ResourceList KRM functions read and write.
+	resourceListAPIVersion = "config.kubernetes.io/v1"
+	// imagesAnnotation lists the images of an object, it is set by skim fn with annotate.
+	imagesAnnotation = "skim.io/images"
+)
+
+// resourceList is the part of a KRM function's input skim fn decodes, the rest is passed through as is.
+type resourceList struct {
+	APIVersion     string           `json:"apiVersion"`
+	Kind           string           `json:"kind"`
+	Items          []map[string]any `json:"items"`
+	FunctionConfig *functionConfig  `json:"functionConfig"`
+}
+
+// functionConfig configures skim fn from the ResourceList, e.g. kind: ImageConfig with apiVersion: skim.io/v1alpha1.
+type functionConfig struct {
+	Spec struct {
+		// Rules map image references to mirrors, see [reference.Rules].
+		Rules reference.Rules `json:"rules"`
+		// Pins pin image references to digests, see [reference.Pins].
+		Pins reference.Pins `json:"pins"`
+		// Annotate sets the skim.io/images annotation on every item with images.
+		Annotate bool `json:"annotate"`
+		// Results adds a result for every image to the ResourceList.
+		Results bool `json:"results"`
+	} `json:"spec"`
+}
+
+// fnResult is a result of a KRM function as defined by the ResourceList specification.
+type fnResult struct {
+	Message     string        `json:"message"`
+	Severity    string        `json:"severity"`
+	ResourceRef images.Object `json:"resourceRef"`
+	Field       fnResultField `json:"field"`
+	File        *fnResultFile `json:"file,omitempty"`
+}
+
+type fnResultField struct {
+	Path         string `json:"path"`
+	CurrentValue string `json:"currentValue"`
+}
+
+type fnResultFile struct {
+	Path string `json:"path"`
+}
+
+func newFnCmd() *cobra.Command {
+	var sources sourceOptions
+	var rewrites rewriteOptions
+	var annotate, results bool
+	var fnCmd = &cobra.Command{
+		Use:   "fn",
+		Short: "Run as a KRM function for kustomize and kpt",
+		Long: `Run as a KRM function for kustomize and kpt.
+
+A ResourceList is read from stdin and written to stdout with the images of its items rewritten by the configured rules and pins,
+the skim.io/images annotation set on every item with images (annotate) and a result per image added (results).
+Besides the flags, the function is configured by the ResourceList's functionConfig:
+
+  apiVersion: skim.io/v1alpha1
+  kind: ImageConfig
+  metadata:
+    name: skim
+  spec:
+    rules:
+      - from: docker.io/
+        to: mirror.example.com/dockerhub/
+    pins:
+      - image: nginx:1.25
+        digest: sha256:...
+    annotate: true
+    results: true
+
+Items of unknown kinds are passed through.`,
+		Example: `kpt fn eval --exec "skim fn" --fn-config skim-config.yaml
+kustomize build --enable-alpha-plugins --enable-exec .
+skim fn --annotate < resource-list.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			extractor.GVKMappings = map[string]func(map[string]any, map[string]struct{}) error{
+				resourceListAPIVersion + ".ResourceList": resourceListMapping(cmd, extractor),
+			}
+			content, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			var list resourceList
+			err = yaml.Unmarshal(content, &list)
+			if err != nil {
+				return fmt.Errorf("failed to decode resource list: %w", err)
+			}
+			if list.APIVersion != resourceListAPIVersion || list.Kind != "ResourceList" {
+				return fmt.Errorf("expected a %s ResourceList, got %s %s", resourceListAPIVersion, list.APIVersion, list.Kind)
+			}
+			config, err := rewrites.load()
+			if err != nil {
+				return err
+			}
+			if list.FunctionConfig != nil {
+				config.Rules = append(config.Rules, list.FunctionConfig.Spec.Rules...)
+				config.Pins = append(config.Pins, list.FunctionConfig.Spec.Pins...)
+				annotate = annotate || list.FunctionConfig.Spec.Annotate
+				results = results || list.FunctionConfig.Spec.Results
+			}
+			rewrite := len(config.Rules) > 0 || len(config.Pins) > 0 || config.To != ""
+			if !rewrite && !annotate && !results {
+				return errors.New("no rewrite rules, pins, annotate or results configured")
+			}
+			if rewrite {
+				err := config.validate()
+				if err != nil {
+					return err
+				}
+				content, err = extractor.RewriteManifests(cmd.Context(), content, "stdin", loggingRewriteFunc(logger, config.rewriteFunc()))
+				if err != nil {
+					return fmt.Errorf("failed to rewrite images: %w", err)
+				}
+			}
+			if annotate || results {
+				content, err = reportImages(cmd, extractor, content, annotate, results)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = cmd.OutOrStdout().Write(content)
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(fnCmd, "skip")
+	rewrites.addFlags(fnCmd)
+	fnCmd.Flags().BoolVar(&annotate, "annotate", false, "Set the "+imagesAnnotation+" annotation to the comma separated images of every item with images.")
+	fnCmd.Flags().BoolVar(&results, "results", false, "Add a result with the object and field of every image to the ResourceList.")
+	return fnCmd
+}
+
+// resourceListMapping extracts the images of a ResourceList's items, its functionConfig is not a resource and is ignored.
+// Items of unknown kinds are skipped unless the extractor is configured to fail on them.
+func resourceListMapping(cmd *cobra.Command, extractor *images.Extractor) func(map[string]any, map[string]struct{}) error {
+	return func(manifest map[string]any, output map[string]struct{}) error {
+		items, _ := manifest["items"].([]any)
+		for _, item := range items {
+			item, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			err := extractor.ExtractFromManifest(item, output)
+			if unknownGVKError, ok := errors.AsType[*images.UnknownGVKError](err); ok && extractor.UnknownGVKBehavior != images.UnknownGVKFail {
+				extractor.Logger.WarnContext(cmd.Context(), "Skipping unknown GVK", "group-version-kind", unknownGVKError.GVK)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// reportImages sets the images annotation on the items of a ResourceList and adds results for their images.
+// The ResourceList is edited as a YAML document so comments and formatting are kept.
+func reportImages(cmd *cobra.Command, extractor *images.Extractor, content []byte, annotate bool, results bool) ([]byte, error) {
+	var list resourceList
+	err := yaml.Unmarshal(content, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode resource list: %w", err)
+	}
+	file, err := parser.ParseBytes(content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse resource list: %w", err)
+	}
+	if annotate {
+		for i, item := range list.Items {
+			itemImages := make(map[string]struct{})
+			err := extractor.ExtractFromManifest(item, itemImages)
+			if _, ok := errors.AsType[*images.UnknownGVKError](err); ok && extractor.UnknownGVKBehavior != images.UnknownGVKFail {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract images from item %d: %w", i, err)
+			}
+			if len(itemImages) == 0 {
+				continue
+			}
+			err = setImagesAnnotation(file, i, strings.Join(slices.Sorted(maps.Keys(itemImages)), ","))
+			if err != nil {
+				return nil, fmt.Errorf("failed to annotate item %d: %w", i, err)
+			}
+		}
+	}
+	if results {
+		occurrences, err := extractor.ExtractOccurrencesFromManifests(cmd.Context(), strings.NewReader(string(content)), "stdin")
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract images: %w", err)
+		}
+		var fnResults []fnResult
+		for _, occurrence := range occurrences {
+			index, field, ok := itemField(occurrence.Path)
+			if !ok || index >= len(list.Items) {
+				continue
+			}
+			item := list.Items[index]
+			result := fnResult{
+				Message:     "image " + occurrence.Image,
+				Severity:    "info",
+				ResourceRef: images.Object{Kind: stringField(item, "kind"), APIVersion: stringField(item, "apiVersion")},
+				Field:       fnResultField{Path: field, CurrentValue: occurrence.Image},
+			}
+			metadata, _ := item["metadata"].(map[string]any)
+			result.ResourceRef.Name = stringField(metadata, "name")
+			result.ResourceRef.Namespace = stringField(metadata, "namespace")
+			annotations, _ := metadata["annotations"].(map[string]any)
+			if path := cmp.Or(stringField(annotations, "internal.config.kubernetes.io/path"), stringField(annotations, "config.kubernetes.io/path")); path != "" {
+				result.File = &fnResultFile{Path: path}
+			}
+			fnResults = append(fnResults, result)
+		}
+		if len(fnResults) > 0 {
+			err := addResults(file, fnResults)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	output := file.String()
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return []byte(output), nil
+}
+
+// itemField splits the path of a field in a ResourceList into the index of the item and the path within the item.
+func itemField(path string) (int, string, bool) {
+	rest, ok := strings.CutPrefix(path, "$.items[")
+	if !ok {
+		return 0, "", false
+	}
+	index, field, ok := strings.Cut(rest, "].")
+	if !ok {
+		return 0, "", false
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return 0, "", false
+	}
+	return i, field, true
+}
+
+func stringField(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// setImagesAnnotation sets the images annotation of the i-th item, creating the annotations and metadata when they are missing.
+func setImagesAnnotation(file *ast.File, i int, value string) error {
+	node, err := itemPath(i, "metadata", "annotations", imagesAnnotation).FilterFile(file)
+	if err == nil {
+		annotation, ok := node.(*ast.StringNode)
+		if !ok {
+			return fmt.Errorf("annotation %s is not a string", imagesAnnotation)
+		}
+		// a plain scalar of comma separated images would end a flow mapping, e.g. {skim.io/images: a,b}
+		annotation.Value = value
+		annotation.Token.Type = token.DoubleQuoteType
+		annotation.Token.Value = value
+		annotation.Token.Origin = images.QuoteScalar(value, token.DoubleQuoteType)
+		return nil
+	}
+	var merge any = map[string]string{imagesAnnotation: value}
+	for _, keys := range [][]string{{"metadata", "annotations"}, {"metadata"}} {
+		if _, err := itemPath(i, keys...).FilterFile(file); err == nil {
+			return mergeInto(file, itemPath(i, keys...), merge)
+		}
+		merge = map[string]any{keys[len(keys)-1]: merge}
+	}
+	return mergeInto(file, itemPath(i), merge)
+}
+
+// itemPath returns the path of a field of the i-th item of a ResourceList.
+func itemPath(i int, keys ...string) *yaml.Path {
+	builder := (&yaml.PathBuilder{}).Root().Child("items").Index(uint(i))
+	for _, key := range keys {
+		builder = builder.Child(key)
+	}
+	return builder.Build()
+}
+
+// addResults appends results to the results of the ResourceList, creating them when they are missing.
+func addResults(file *ast.File, results []fnResult) error {
+	path := (&yaml.PathBuilder{}).Root().Child("results").Build()
+	if _, err := path.FilterFile(file); err == nil {
+		return mergeInto(file, path, results)
+	}
+	return mergeInto(file, (&yaml.PathBuilder{}).Root().Build(), map[string]any{"results": results})
+}
+
+// mergeInto merges value into the node at path, in flow style when the node is written in flow style.
+func mergeInto(file *ast.File, path *yaml.Path, value any) error {
+	node, err := path.FilterFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", path, err)
+	}
+	option := yaml.IndentSequence(true)
+	switch node := node.(type) {
+	case *ast.MappingNode:
+		if node.IsFlowStyle {
+			option = yaml.Flow(true)
+		}
+	case *ast.SequenceNode:
+		if node.IsFlowStyle {
+			option = yaml.Flow(true)
+		}
+	}
+	snippet, err := yaml.MarshalWithOptions(value, option)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	err = path.MergeFromReader(file, strings.NewReader(string(snippet)))
+	if err != nil {
+		return fmt.Errorf("failed to merge into %s: %w", path, err)
+	}
+	return nil
+}
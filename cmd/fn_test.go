@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+const fnResourceList = `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  # the web server
+  - apiVersion: v1
+    kind: Pod
+    metadata:
+      name: web
+      namespace: default
+      annotations:
+        config.kubernetes.io/path: pod.yaml
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.21 # pinned by skim
+        - name: sidecar
+          image: "busybox:1.35"
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata: {name: api}
+    spec:
+      template:
+        spec:
+          containers:
+            - name: api
+              image: quay.io/example/api:v1
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata: {name: config}
+    data:
+      image: nginx:1.21
+  - apiVersion: example.com/v1
+    kind: Widget
+    metadata:
+      name: unknown
+    spec:
+      image: nginx:1.21
+`
+
+func TestFnCmdRewrite(t *testing.T) {
+	t.Parallel()
+	input := fnResourceList + `functionConfig:
+  apiVersion: skim.io/v1alpha1
+  kind: ImageConfig
+  metadata:
+    name: skim
+  spec:
+    rules:
+      - from: docker.io/
+        to: mirror.example.com/dockerhub/
+    pins:
+      - image: nginx:1.21
+        digest: ` + postRenderDigest + `
+`
+	fnCmd := newFnCmd()
+	var stdout bytes.Buffer
+	fnCmd.SetIn(strings.NewReader(input))
+	fnCmd.SetOut(&stdout)
+	fnCmd.SetErr(io.Discard)
+	fnCmd.SetArgs([]string{"--rule", "quay.io/=mirror.example.com/quay/"})
+	require.NoError(t, fnCmd.Execute())
+	expected := strings.NewReplacer(
+		"image: nginx:1.21 #", "image: mirror.example.com/dockerhub/library/nginx:1.21@"+postRenderDigest+" #",
+		`image: "busybox:1.35"`, `image: "mirror.example.com/dockerhub/library/busybox:1.35"`,
+		"image: quay.io/example/api:v1", "image: mirror.example.com/quay/example/api:v1",
+	).Replace(input)
+	require.Equal(t, expected, stdout.String())
+}
+
+func TestFnCmdAnnotateAndResults(t *testing.T) {
+	t.Parallel()
+	fnCmd := newFnCmd()
+	var stdout bytes.Buffer
+	fnCmd.SetIn(strings.NewReader(fnResourceList))
+	fnCmd.SetOut(&stdout)
+	fnCmd.SetErr(io.Discard)
+	fnCmd.SetArgs([]string{"--annotate", "--results"})
+	require.NoError(t, fnCmd.Execute())
+	expected := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  # the web server
+  - apiVersion: v1
+    kind: Pod
+    metadata:
+      name: web
+      namespace: default
+      annotations:
+        config.kubernetes.io/path: pod.yaml
+        skim.io/images: busybox:1.35,nginx:1.21
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.21 # pinned by skim
+        - name: sidecar
+          image: "busybox:1.35"
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata: {name: api, annotations: {skim.io/images: "quay.io/example/api:v1"}}
+    spec:
+      template:
+        spec:
+          containers:
+            - name: api
+              image: quay.io/example/api:v1
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata: {name: config}
+    data:
+      image: nginx:1.21
+  - apiVersion: example.com/v1
+    kind: Widget
+    metadata:
+      name: unknown
+    spec:
+      image: nginx:1.21
+results:
+  - message: image nginx:1.21
+    severity: info
+    resourceRef:
+      apiVersion: v1
+      kind: Pod
+      namespace: default
+      name: web
+    field:
+      path: spec.containers[0].image
+      currentValue: nginx:1.21
+    file:
+      path: pod.yaml
+  - message: image busybox:1.35
+    severity: info
+    resourceRef:
+      apiVersion: v1
+      kind: Pod
+      namespace: default
+      name: web
+    field:
+      path: spec.containers[1].image
+      currentValue: busybox:1.35
+    file:
+      path: pod.yaml
+  - message: image quay.io/example/api:v1
+    severity: info
+    resourceRef:
+      apiVersion: apps/v1
+      kind: Deployment
+      name: api
+    field:
+      path: spec.template.spec.containers[0].image
+      currentValue: quay.io/example/api:v1
+`
+	require.Equal(t, expected, stdout.String())
+
+	// annotating again updates the annotation in place
+	fnCmd = newFnCmd()
+	stdout.Reset()
+	fnCmd.SetIn(strings.NewReader(strings.Replace(expected, "image: nginx:1.21 #", "image: nginx:1.25 #", 1)))
+	fnCmd.SetOut(&stdout)
+	fnCmd.SetErr(io.Discard)
+	fnCmd.SetArgs([]string{"--annotate"})
+	require.NoError(t, fnCmd.Execute())
+	require.Contains(t, stdout.String(), "skim.io/images: \"busybox:1.35,nginx:1.25\"\n")
+	require.Equal(t, 2, strings.Count(stdout.String(), "skim.io/images:"))
+}
+
+func TestFnCmdAnnotateFlowMapping(t *testing.T) {
+	t.Parallel()
+	input := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: v1
+    kind: Pod
+    metadata: {name: web, annotations: {skim.io/images: old, team: x}}
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.21
+        - name: sidecar
+          image: busybox:1.35
+`
+	fnCmd := newFnCmd()
+	var stdout bytes.Buffer
+	fnCmd.SetIn(strings.NewReader(input))
+	fnCmd.SetOut(&stdout)
+	fnCmd.SetErr(io.Discard)
+	fnCmd.SetArgs([]string{"--annotate"})
+	require.NoError(t, fnCmd.Execute())
+	require.Contains(t, stdout.String(), `metadata: {name: web, annotations: {skim.io/images: "busybox:1.35,nginx:1.21", team: x}}`)
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		} `yaml:"items"`
+	}
+	require.NoError(t, yaml.Unmarshal(stdout.Bytes(), &list))
+	require.Equal(t, map[string]string{"skim.io/images": "busybox:1.35,nginx:1.21", "team": "x"}, list.Items[0].Metadata.Annotations)
+}
+
+func TestFnCmdErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		err   string
+	}{
+		{
+			name:  "not a resource list",
+			input: "apiVersion: v1\nkind: Pod\n",
+			args:  []string{"--annotate"},
+			err:   "expected a config.kubernetes.io/v1 ResourceList",
+		},
+		{
+			name:  "nothing to do",
+			input: fnResourceList,
+			err:   "no rewrite rules, pins, annotate or results configured",
+		},
+		{
+			name:  "invalid rule",
+			input: fnResourceList + "functionConfig:\n  spec:\n    rules:\n      - from: docker.io/\n",
+			err:   "both sides must be set",
+		},
+		{
+			name:  "unknown kind",
+			input: fnResourceList,
+			args:  []string{"--annotate", "-u", "fail"},
+			err:   "example.com/v1.Widget",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fnCmd := newFnCmd()
+			fnCmd.SetIn(strings.NewReader(tt.input))
+			fnCmd.SetOut(io.Discard)
+			fnCmd.SetErr(io.Discard)
+			fnCmd.SetArgs(tt.args)
+			require.ErrorContains(t, fnCmd.Execute(), tt.err)
+		})
+	}
+}
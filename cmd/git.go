@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// gitBlob is a file of a revision's tree.
+type gitBlob struct {
+	object string
+	path   string
+}
+
+// walkGit calls fn for every manifest file of a revision in a local git repository: the paths themselves are always
+// read, the files under them only when they are YAML or JSON files like the entries of archives.
+// The revision's tree is listed with git ls-tree and its files are read with git cat-file, so it works in bare clones,
+// never touches the working tree and reads the files as they were committed, unlike git archive which applies the
+// export-ignore and export-subst attributes.
+// paths limit the walk to parts of the revision's tree, an empty list means the entire tree.
+func walkGit(ctx context.Context, repo string, ref string, paths []string, fn images.ManifestFunc) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid git ref: %s", ref)
+	}
+	blobs, err := listGitTree(ctx, repo, ref, paths)
+	if err != nil {
+		return err
+	}
+	err = readGitBlobs(ctx, repo, blobs, func(blob gitBlob, content []byte) error {
+		return images.WalkFile(bytes.NewReader(content), ref+"!/"+blob.path, fn)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to process git ref %s: %w", ref, err)
+	}
+	return nil
+}
+
+// listGitTree lists the files of a revision's tree under paths that should be read.
+func listGitTree(ctx context.Context, repo string, ref string, paths []string) ([]gitBlob, error) {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		if p == "" {
+			// the root of the tree, every file is under it
+			return listGitTree(ctx, repo, ref, nil)
+		}
+		cleaned = append(cleaned, p)
+	}
+	args := append([]string{"-C", repo, "ls-tree", "-r", "-z", "--full-tree", ref, "--"}, cleaned...)
+	gitCmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	gitCmd.Stderr = &stderr
+	output, err := gitCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git ref %s: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	found := make(map[string]bool)
+	var blobs []gitBlob
+	for record := range strings.SplitSeq(strings.TrimSuffix(string(output), "\x00"), "\x00") {
+		if record == "" {
+			continue
+		}
+		// <mode> SP <type> SP <object> TAB <path>
+		info, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("failed to read git ref %s: unexpected git ls-tree output %q", ref, record)
+		}
+		explicit := false
+		for _, p := range cleaned {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				found[p] = true
+				explicit = explicit || name == p
+			}
+		}
+		// submodules are commits and symbolic links have mode 120000, neither is a file of the tree
+		if fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		if explicit || images.IsManifest(name) || images.IsArchive(name) {
+			blobs = append(blobs, gitBlob{object: fields[2], path: name})
+		}
+	}
+	for _, p := range cleaned {
+		if !found[p] {
+			return nil, fmt.Errorf("failed to process %s in git ref %s: %w", p, ref, fs.ErrNotExist)
+		}
+	}
+	return blobs, nil
+}
+
+// readGitBlobs reads the content of every blob with a single git cat-file process and calls fn with it.
+func readGitBlobs(ctx context.Context, repo string, blobs []gitBlob, fn func(blob gitBlob, content []byte) error) error {
+	if len(blobs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	gitCmd := exec.CommandContext(ctx, "git", "-C", repo, "cat-file", "--batch")
+	var stderr bytes.Buffer
+	gitCmd.Stderr = &stderr
+	stdin, err := gitCmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create git pipe: %w", err)
+	}
+	stdout, err := gitCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create git pipe: %w", err)
+	}
+	err = gitCmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to run git: %w", err)
+	}
+	go func() {
+		defer stdin.Close()
+		for _, blob := range blobs {
+			if _, err := io.WriteString(stdin, blob.object+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+	readErr := func() error {
+		reader := bufio.NewReader(stdout)
+		for _, blob := range blobs {
+			content, err := readGitBlob(reader, blob)
+			if err != nil {
+				return err
+			}
+			err = fn(blob, content)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if readErr != nil {
+		// stop git, it may be blocked writing the rest of the blobs
+		cancel()
+	}
+	waitErr := gitCmd.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("failed to read git objects: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// readGitBlob reads the next object git cat-file --batch writes, "<object> <type> <size>\n<content>\n".
+func readGitBlob(reader *bufio.Reader, blob gitBlob) ([]byte, error) {
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", blob.path, err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 || fields[1] != "blob" {
+		return nil, fmt.Errorf("failed to read %s: unexpected git cat-file output %q", blob.path, strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: invalid size %q", blob.path, fields[2])
+	}
+	content := make([]byte, size+1)
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", blob.path, err)
+	}
+	return content[:size], nil
+}
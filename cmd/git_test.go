@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runGit runs a git command in dir failing the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	gitCmd := exec.CommandContext(t.Context(), "git", append([]string{"-C", dir, "-c", "user.name=skim", "-c", "user.email=skim@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	output, err := gitCmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+// newGitRepo creates a repository with two commits tagged v1 and v2 and returns a bare clone of it.
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet")
+	deployment, err := os.ReadFile("../testdata/deployment.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "deploy"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy", "deployment.yaml"), deployment, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# not a manifest\n"), 0o600))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "v1")
+	runGit(t, dir, "tag", "v1")
+	pod, err := os.ReadFile("../testdata/pod.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy", "deployment.yaml"), bytes.ReplaceAll(deployment, []byte("1.2.3"), []byte("1.3.0")), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pod.yaml"), pod, 0o600))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "v2")
+	runGit(t, dir, "tag", "v2")
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, dir, "clone", "--quiet", "--bare", dir, bare)
+	return bare
+}
+
+func TestListCmdGitRef(t *testing.T) {
+	t.Parallel()
+	repo := newGitRepo(t)
+
+	listCmd := newListCmd()
+	var stdout bytes.Buffer
+	listCmd.SetOut(&stdout)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--git-repo", repo, "--git-ref", "v1"})
+	require.NoError(t, listCmd.Execute())
+	require.Equal(t, "example.com/processor:1.2.3\n", stdout.String())
+
+	listCmd = newListCmd()
+	stdout.Reset()
+	listCmd.SetOut(&stdout)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--git-repo", repo, "--git-ref", "v2", "--", "deploy/"})
+	require.NoError(t, listCmd.Execute())
+	require.Equal(t, "example.com/processor:1.3.0\n", stdout.String())
+}
+
+func TestListCmdGitRefNotFound(t *testing.T) {
+	t.Parallel()
+	repo := newGitRepo(t)
+
+	listCmd := newListCmd()
+	listCmd.SetOut(io.Discard)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--git-repo", repo, "--git-ref", "v3"})
+	require.ErrorContains(t, listCmd.Execute(), "failed to read git ref v3")
+
+	listCmd = newListCmd()
+	listCmd.SetOut(io.Discard)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--git-repo", repo, "--git-ref", "--output=/tmp/x"})
+	require.ErrorContains(t, listCmd.Execute(), "invalid git ref")
+}
+
+func TestListCmdGitRefReadsTreeAsCommitted(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet")
+	pod := func(image string) []byte {
+		return []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: app\nspec:\n  containers:\n    - name: app\n      image: " + image + "\n")
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "deploy"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("deploy/ignored.yaml export-ignore\ndeploy/subst.yaml export-subst\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy", "ignored.yaml"), pod("example.com/ignored:1.0"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy", "subst.yaml"), pod("example.com/subst:$Format:%h$"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy", "NOTES.txt"), pod("example.com/notes:1.0"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rendered"), pod("example.com/rendered:1.0"), 0o600))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "v1")
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args:     nil,
+			expected: "example.com/ignored:1.0\nexample.com/subst:$Format:%h$\n",
+		},
+		{
+			// paths are always read, the files under them only when they are manifests
+			args:     []string{"deploy", "rendered"},
+			expected: "example.com/ignored:1.0\nexample.com/rendered:1.0\nexample.com/subst:$Format:%h$\n",
+		},
+	}
+	for _, test := range tests {
+		listCmd := newListCmd()
+		var stdout bytes.Buffer
+		listCmd.SetOut(&stdout)
+		listCmd.SetErr(io.Discard)
+		listCmd.SetArgs(append([]string{"--git-repo", dir, "--git-ref", "HEAD", "--"}, test.args...))
+		require.NoError(t, listCmd.Execute())
+		require.Equal(t, test.expected, stdout.String())
+	}
+
+	listCmd := newListCmd()
+	listCmd.SetOut(io.Discard)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--git-repo", dir, "--git-ref", "HEAD", "--", "missing/"})
+	require.ErrorIs(t, listCmd.Execute(), fs.ErrNotExist)
+}
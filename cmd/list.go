@@ -1,101 +1,151 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yardenshoham/skim/pkg/images"
 )
 
 func newListCmd() *cobra.Command {
-	var unknownGVKBehavior string
+	var sources sourceOptions
+	var watch bool
+	var interval time.Duration
+	var diff bool
+	var format string
+	var script scriptOptions
 	var listCmd = &cobra.Command{
-		Use:     "list PATH [PATH...]",
-		Short:   "List container images from Kubernetes resources",
-		Example: "skim list path/to/k8s-manifest.yaml",
-		Args:    cobra.MinimumNArgs(1),
+		Use:   "list PATH [PATH...]",
+		Short: "List container images from Kubernetes resources",
+		Long: `List container images from Kubernetes resources.
+
+With --format script the output is a POSIX shell script getting every image into a local environment with --tool:
+docker and podman pull the images, kind and k3d pull them with docker and load them into the cluster, e.g. for offline
+demos. crane and skopeo copy every platform of the images to the names rewrite rules map them to, e.g. a local
+registry the cluster pulls from, see skim rewrite --help. With the other tools the images are pulled from the names
+the rules map them to, e.g. a mirror, kind and k3d then tag them back to the names the manifests use before loading
+them.`,
+		Example: `skim list path/to/k8s-manifest.yaml
+skim list --git-ref origin/main -- path/in/repo/
+skim list --watch --diff path/to/chart/rendered/
+skim list --format script --tool kind --cluster demo path/to/manifests/ > preload.sh
+skim list --format script --tool crane --rule docker.io/=localhost:5001/ path/to/manifests/ | sh`,
+		Args: sources.validateArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := cmd.Context()
 			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
 			outputStream := cmd.OutOrStdout()
 			imagesOutput := make(map[string]struct{})
-			extractor := &images.Extractor{
-				Logger: logger,
-			}
-			switch strings.ToLower(unknownGVKBehavior) {
-			case "fail":
-				extractor.UnknownGVKBehavior = images.UnknownGVKFail
-			case "skip":
-				extractor.UnknownGVKBehavior = images.UnknownGVKSkip
-			case "freetext":
-				extractor.UnknownGVKBehavior = images.UnknownGVKFreeText
-			default:
-				return fmt.Errorf("unknown value for unknown-gvk-behavior: %s", unknownGVKBehavior)
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
 			}
-			filePaths := make([]string, 0, len(args))
-
-			// Process each argument - can be files or stdin (-)
-			for _, arg := range args {
-				if arg == "-" {
-					// Process stdin
-					logger.Info("Processing stdin")
-					inputStream := cmd.InOrStdin()
-					err := extractor.ExtractFromManifests(ctx, inputStream, imagesOutput)
-					if err != nil {
-						return fmt.Errorf("failed to extract images from stdin: %w", err)
-					}
-					continue
-				}
-
-				// Process file path(s) - could be files or directories
-				err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
-					if err != nil {
-						return err
-					}
-					if d.Type().IsRegular() {
-						filePaths = append(filePaths, path)
-					}
-					return nil
-				})
+			var config rewriteConfig
+			switch strings.ToLower(format) {
+			case "text":
+				config, err = script.rewrites.load()
 				if err != nil {
-					return fmt.Errorf("failed to walk path %s: %w", arg, err)
+					return err
 				}
-			}
-			for _, path := range filePaths {
-				logger.Info("Processing file", "path", path)
-				file, err := os.Open(path)
-				if err != nil {
-					return fmt.Errorf("failed to open file %s: %w", path, err)
+				if len(config.Rules) > 0 || len(config.Pins) > 0 || config.To != "" {
+					return errors.New("rewrite rules, pins and --to only apply to --format script")
 				}
-				defer file.Close()
-				err = extractor.ExtractFromManifests(ctx, file, imagesOutput)
+			case "script":
+				if watch {
+					return errors.New("--watch only prints images, it can't be used with --format script")
+				}
+				config, err = script.validate()
 				if err != nil {
-					return fmt.Errorf("failed to extract images from file %s: %w", path, err)
+					return err
 				}
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if watch {
+				return watchList(cmd, logger, extractor, args, interval, diff)
 			}
-			outputSlice := make([]string, 0, len(imagesOutput))
-			for image := range imagesOutput {
-				outputSlice = append(outputSlice, image)
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
 			}
-			if len(outputSlice) == 0 {
+			if len(imagesOutput) == 0 {
 				logger.Warn("No images found")
 				return nil
 			}
-			slices.Sort(outputSlice)
-			resultList := strings.Join(outputSlice, "\n")
-			_, err := fmt.Fprintln(outputStream, resultList)
+			if strings.ToLower(format) == "script" {
+				return writeScript(outputStream, script, config.rewriteFunc(), imagesOutput)
+			}
+			err = writeList(outputStream, imagesOutput)
 			if err != nil {
 				return fmt.Errorf("failed to write output: %w", err)
 			}
 			return nil
 		},
 	}
-	listCmd.Flags().StringVarP(&unknownGVKBehavior, "unknown-gvk-behavior", "u", "fail", "Behavior when encountering unknown Group-Version-Kind (options: fail, skip, freetext). Defaults to fail.")
+	sources.addFlags(listCmd)
+	listCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Keep watching the files and print the images again whenever they change, until interrupted.")
+	listCmd.Flags().DurationVar(&interval, "interval", time.Second, "How often files are checked for changes with --watch.")
+	listCmd.Flags().BoolVar(&diff, "diff", false, "Print the images that were added, removed or changed instead of every image when they change with --watch.")
+	listCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, script).")
+	listCmd.Flags().StringVar(&script.tool, "tool", "docker", fmt.Sprintf("Tool the script of --format script gets the images with (options: %s).", strings.Join(scriptTools, ", ")))
+	listCmd.Flags().StringVar(&script.cluster, "cluster", "", "Name of the kind or k3d cluster the script loads the images into, the tool's default cluster when empty.")
+	script.rewrites.addFlags(listCmd)
 	return listCmd
 }
+
+// watchList prints the images of the files and prints them again, or how they changed with diff, whenever they change.
+// Only the files that changed are extracted again.
+func watchList(cmd *cobra.Command, logger *slog.Logger, extractor *images.Extractor, args []string, interval time.Duration, diff bool) error {
+	if slices.Contains(args, "-") || cmd.Flags().Changed("git-ref") {
+		return errors.New("--watch only watches files, it can't be used with stdin or --git-ref")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s, it must be positive", interval)
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	w := newWatcher(extractor, args)
+	_, err := w.scan(ctx)
+	if err != nil {
+		return err
+	}
+	err = writeList(cmd.OutOrStdout(), w.images())
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	logger.Info("Watching for changes", "interval", interval)
+	return w.watch(ctx, logger, interval, func(before map[string]struct{}, after map[string]struct{}) error {
+		var err error
+		if diff {
+			err = writeDiffText(cmd.OutOrStdout(), images.DiffImages(before, after))
+		} else {
+			_, err = io.WriteString(cmd.OutOrStdout(), "\n")
+			if err == nil {
+				err = writeList(cmd.OutOrStdout(), after)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	})
+}
+
+// writeList writes the images sorted, one per line.
+func writeList(w io.Writer, imagesOutput map[string]struct{}) error {
+	var sb strings.Builder
+	for _, image := range slices.Sorted(maps.Keys(imagesOutput)) {
+		sb.WriteString(image + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
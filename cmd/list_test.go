@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -42,3 +46,47 @@ func TestListCmdMixedFilesAndStdin(t *testing.T) {
 	require.Contains(t, stdout.String(), "nginx:1.21.0")                // from pod.yaml via stdin
 	require.Contains(t, stdout.String(), "busybox:1.35")                // from pod.yaml via stdin
 }
+
+func TestListCmdDirectory(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	deployment, err := os.ReadFile("../testdata/deployment.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "deployment.yaml"), deployment, 0o600))
+
+	listCmd := newListCmd()
+	var stdout bytes.Buffer
+	listCmd.SetOut(&stdout)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{dir})
+	err = listCmd.Execute()
+	require.NoError(t, err)
+	require.Equal(t, "example.com/processor:1.2.3\n", stdout.String())
+}
+
+func TestListCmdArchive(t *testing.T) {
+	t.Parallel()
+	deployment, err := os.ReadFile("../testdata/deployment.yaml")
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "templates/deploy.yaml", Mode: 0o644, Size: int64(len(deployment))}))
+	_, err = tarWriter.Write(deployment)
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	archivePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, archive.Bytes(), 0o600))
+
+	listCmd := newListCmd()
+	var stdout, stderr bytes.Buffer
+	listCmd.SetOut(&stdout)
+	listCmd.SetErr(&stderr)
+	listCmd.SetArgs([]string{archivePath})
+	err = listCmd.Execute()
+	require.NoError(t, err)
+	require.Equal(t, "example.com/processor:1.2.3\n", stdout.String())
+	require.Contains(t, stderr.String(), "bundle.tar.gz!/templates/deploy.yaml")
+}
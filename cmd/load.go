@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// loadResult is the outcome of pushing an image of a bundle.
+type loadResult struct {
+	Image  string `json:"image"`
+	Target string `json:"target,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newLoadCmd() *cobra.Command {
+	var registries registryOptions
+	var rewrites rewriteOptions
+	var format string
+	var loadCmd = &cobra.Command{
+		Use:   "load BUNDLE",
+		Short: "Push the images of an OCI image layout to a registry with rewritten names",
+		Long: `Push the images of an OCI image layout, a tarball written by skim save or a directory, to a registry.
+
+Images are named by the org.opencontainers.image.ref.name or io.containerd.image.name annotation of the index and
+mapped to their new names with the same rules as skim rewrite, so the manifests can be rewritten to match with the same
+flags or config file. Every image must be mapped to a new name.
+
+Blobs the registry already has are skipped. Registries reject an index whose manifests they don't have, an index that
+was saved with only some platforms is pushed with only those, its digest then differs from the original.`,
+		Example: `skim load --to registry.example.com/mirror bundle.tar
+skim load --rule 'docker.io/=registry.example.com/dockerhub/' --rule 'quay.io/=registry.example.com/quay/' bundle.tar`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			config, err := rewrites.config()
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			layout, err := ocilayout.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open bundle: %w", err)
+			}
+			defer layout.Close()
+			fn := config.rewriteFunc()
+			results := concurrently(layout.Index.Manifests, registries.concurrency, func(descriptor registry.Descriptor) loadResult {
+				// the OCI annotation may be only a tag when the layout wasn't written by skim
+				result := loadResult{Image: cmp.Or(descriptor.Annotations[ocilayout.AnnotationImageName], descriptor.Annotations[ocilayout.AnnotationRefName])}
+				var err error
+				result.Target, result.Digest, err = loadImage(cmd.Context(), logger, client, layout, descriptor, result.Image, fn)
+				if err != nil {
+					result.Error = err.Error()
+				}
+				return result
+			})
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeLoadText(cmd.OutOrStdout(), results)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+			}
+			logger.Info("Loaded images", "images", len(results), "failed", failed)
+			if failed > 0 {
+				return fmt.Errorf("failed to load %d of %d images", failed, len(results))
+			}
+			return nil
+		},
+	}
+	registries.addFlags(loadCmd)
+	registries.addConcurrencyFlag(loadCmd)
+	rewrites.addFlags(loadCmd)
+	loadCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json).")
+	return loadCmd
+}
+
+// loadImage pushes the image of an index entry of a layout to the name fn maps it to and returns the name and the digest pushed.
+func loadImage(ctx context.Context, logger *slog.Logger, client *registry.Client, layout *ocilayout.Layout, descriptor registry.Descriptor, image string, fn images.RewriteFunc) (string, string, error) {
+	if _, err := reference.Parse(image); err != nil {
+		return "", "", fmt.Errorf("image %s has no valid name annotation", descriptor.Digest)
+	}
+	target, ref, err := copyTarget(fn, image)
+	if err != nil {
+		return target, "", err
+	}
+	content, err := layout.ReadBlob(descriptor.Digest, maxManifestSize)
+	if err != nil {
+		return target, "", err
+	}
+	original := descriptor.Digest
+	descriptor, content, err = copyImage(ctx, layoutSource{layout: layout}, registryDestination{client: client, ref: ref}, descriptor, content, nil)
+	if err != nil {
+		return target, "", err
+	}
+	if descriptor.Digest != original {
+		logger.Warn("Pushing a partial index, its digest differs from the original", "image", image, "digest", descriptor.Digest)
+	}
+	// a reference pinned only to the original digest is pushed by the digest of what is pushed
+	digest, err := client.PushManifest(ctx, pushReference(ref), descriptor.MediaType, content)
+	return target, digest, err
+}
+
+// writeLoadText writes the new name of every image pushed and the reason the others failed.
+func writeLoadText(w io.Writer, results []loadResult) error {
+	var sb strings.Builder
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(&sb, "failed: %s: %s\n", result.Image, result.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s -> %s@%s\n", result.Image, strings.SplitN(result.Target, "@", 2)[0], result.Digest)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func TestLoadCmd(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	bundle, index := saveBundle(t, source)
+	target := registrytest.New(t)
+
+	loadCmd := newLoadCmd()
+	var stdout bytes.Buffer
+	loadCmd.SetOut(&stdout)
+	loadCmd.SetErr(io.Discard)
+	loadCmd.SetArgs([]string{"--plain-http", "--to", target.Host() + "/mirror", bundle})
+	require.NoError(t, loadCmd.Execute())
+	app, _ := reference.Mirror(source.Host()+"/app:v1", target.Host()+"/mirror")
+	require.Contains(t, stdout.String(), source.Host()+"/app:v1 -> "+app+"@"+index+"\n")
+
+	// the multi-platform image keeps its digest
+	client := &registry.Client{PlainHTTP: true}
+	descriptor, err := client.Head(t.Context(), mustParseReference(t, app))
+	require.NoError(t, err)
+	require.Equal(t, index, descriptor.Digest)
+	platforms, err := client.Platforms(t.Context(), mustParseReference(t, app))
+	require.NoError(t, err)
+	require.Equal(t, []registry.Platform{linuxAMD64, linuxARM64}, platforms)
+}
+
+func TestLoadCmdPartialIndex(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	bundle, index := saveBundle(t, source, "--platform", "linux/amd64")
+	target := registrytest.New(t)
+
+	loadCmd := newLoadCmd()
+	var stdout bytes.Buffer
+	loadCmd.SetOut(&stdout)
+	loadCmd.SetErr(io.Discard)
+	loadCmd.SetArgs([]string{"--plain-http", "-o", "json", "--rule", source.Host() + "/=" + target.Host() + "/", bundle})
+	require.NoError(t, loadCmd.Execute())
+	var results []loadResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 2)
+	require.Equal(t, target.Host()+"/app:v1", results[0].Target)
+	// registries reject an index without its manifests, it is pushed with only the saved platform
+	require.NotEqual(t, index, results[0].Digest)
+	platforms, err := (&registry.Client{PlainHTTP: true}).Platforms(t.Context(), mustParseReference(t, target.Host()+"/app:v1"))
+	require.NoError(t, err)
+	require.Equal(t, []registry.Platform{linuxAMD64}, platforms)
+	require.Equal(t, target.Host()+"/sidecar:v1", results[1].Target)
+}
+
+func TestLoadCmdErrors(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	bundle, _ := saveBundle(t, source)
+
+	loadCmd := newLoadCmd()
+	loadCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	loadCmd.SetOut(&stdout)
+	loadCmd.SetErr(io.Discard)
+	loadCmd.SetArgs([]string{"--plain-http", "--rule", source.Host() + "/app=" + source.Host() + "/copy", bundle})
+	require.EqualError(t, loadCmd.Execute(), "failed to load 1 of 2 images")
+	require.Contains(t, stdout.String(), "failed: "+source.Host()+"/sidecar:v1: no rule maps "+source.Host()+"/sidecar:v1 to a new name\n")
+
+	loadCmd = newLoadCmd()
+	loadCmd.SetOut(io.Discard)
+	loadCmd.SetErr(io.Discard)
+	loadCmd.SetArgs([]string{bundle})
+	require.EqualError(t, loadCmd.Execute(), "no rewrite rules, pins or to configured")
+}
+
+func mustParseReference(t *testing.T, image string) reference.Reference {
+	t.Helper()
+	ref, err := reference.Parse(image)
+	require.NoError(t, err)
+	return ref
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/lockfile"
+)
+
+func newLockCmd() *cobra.Command {
+	var sources sourceOptions
+	var lockfilePath string
+	var check bool
+	var lockCmd = &cobra.Command{
+		Use:   "lock PATH [PATH...]",
+		Short: "Write a lockfile of the container images referenced by Kubernetes resources or check that it is up to date",
+		Example: `skim lock deploy/
+skim lock --check --lockfile deploy/images.lock deploy/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if check && lockfilePath == "-" {
+				return errors.New("--check needs a lockfile path")
+			}
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			occurrences, err := sources.extractOccurrences(cmd, extractor, args)
+			if err != nil {
+				return err
+			}
+			current := lockfile.New(occurrences)
+			content, err := current.Marshal()
+			if err != nil {
+				return err
+			}
+			if !check {
+				if lockfilePath == "-" {
+					_, err = cmd.OutOrStdout().Write(content)
+				} else {
+					err = os.WriteFile(lockfilePath, content, 0o644)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to write lockfile: %w", err)
+				}
+				return nil
+			}
+			lockedContent, err := os.ReadFile(lockfilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read lockfile: %w", err)
+			}
+			locked, err := lockfile.Parse(lockedContent)
+			if err != nil {
+				return err
+			}
+			diff := images.DiffImages(locked.References(), current.References())
+			if !diff.Empty() {
+				err = writeDiffText(cmd.OutOrStdout(), diff)
+				if err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+				return fmt.Errorf("images differ from lockfile %s, run skim lock to update it", lockfilePath)
+			}
+			if !bytes.Equal(lockedContent, content) {
+				logger.Warn("Lockfile is out of date but locks the same images, run skim lock to update it", "lockfile", lockfilePath)
+			}
+			return nil
+		},
+	}
+	sources.addFlags(lockCmd)
+	lockCmd.Flags().StringVarP(&lockfilePath, "lockfile", "f", "images.lock", "Path to the lockfile, - writes it to stdout.")
+	lockCmd.Flags().BoolVar(&check, "check", false, "Do not write the lockfile, fail if the images differ from the ones it locks.")
+	return lockCmd
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockCmd(t *testing.T) {
+	t.Parallel()
+	lockfilePath := filepath.Join(t.TempDir(), "images.lock")
+	lockCmd := newLockCmd()
+	lockCmd.SetOut(io.Discard)
+	lockCmd.SetErr(io.Discard)
+	lockCmd.SetArgs([]string{"--lockfile", lockfilePath, "../testdata/multi_object.yaml"})
+	require.NoError(t, lockCmd.Execute())
+	content, err := os.ReadFile(lockfilePath)
+	require.NoError(t, err)
+	require.Equal(t, `# This file is generated by skim lock, do not edit it by hand.
+version: 1
+images:
+  - image: docker.io/library/nginx:1.21.0
+    objects:
+      - apiVersion: v1
+        kind: Pod
+        name: first-pod
+  - image: docker.io/library/redis:7.0
+    objects:
+      - apiVersion: apps/v1
+        kind: Deployment
+        name: my-deployment
+`, string(content))
+
+	lockCmd = newLockCmd()
+	lockCmd.SetOut(io.Discard)
+	lockCmd.SetErr(io.Discard)
+	lockCmd.SetArgs([]string{"--check", "--lockfile", lockfilePath, "../testdata/multi_object.yaml"})
+	require.NoError(t, lockCmd.Execute())
+}
+
+func TestLockCmdCheckDrift(t *testing.T) {
+	t.Parallel()
+	lockfilePath := filepath.Join(t.TempDir(), "images.lock")
+	lockCmd := newLockCmd()
+	lockCmd.SetOut(io.Discard)
+	lockCmd.SetErr(io.Discard)
+	lockCmd.SetArgs([]string{"--lockfile", lockfilePath, "../testdata/pod.yaml"})
+	require.NoError(t, lockCmd.Execute())
+
+	lockCmd = newLockCmd()
+	lockCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	lockCmd.SetOut(&stdout)
+	lockCmd.SetErr(io.Discard)
+	lockCmd.SetArgs([]string{"--check", "--lockfile", lockfilePath, "../testdata/multi_object.yaml"})
+	require.ErrorContains(t, lockCmd.Execute(), "images differ from lockfile")
+	require.Equal(t, "+ docker.io/library/redis:7.0\n- docker.io/library/busybox:1.35\n", stdout.String())
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// Statuses of mirrored images.
+const (
+	mirrorCopied = "copied"
+	mirrorExists = "exists"
+	mirrorFailed = "failed"
+)
+
+// mirrorResult is the outcome of mirroring an image.
+type mirrorResult struct {
+	Image  string `json:"image"`
+	Target string `json:"target,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newMirrorCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var rewrites rewriteOptions
+	var format string
+	var mirrorCmd = &cobra.Command{
+		Use:   "mirror PATH [PATH...]",
+		Short: "Copy the container images of Kubernetes resources to another registry",
+		Long: `Copy the container images of Kubernetes resources from their registries to another registry.
+
+Images are mapped to their new names with the same rules as skim rewrite, so the manifests can be rewritten to match
+with the same flags or config file. Every image must be mapped to a new name.
+
+Manifests are copied as they are, so digests are kept and multi-platform images keep their index with every platform.
+Images whose new name already points to the same digest are skipped, as are blobs the target registry already has.
+The same credentials are used for both registries.`,
+		Example: `skim mirror --to registry.example.com/mirror path/to/manifests/
+skim mirror --rule 'docker.io/=registry.example.com/dockerhub/' --rule 'quay.io/=registry.example.com/quay/' path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			config, err := rewrites.config()
+			if err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			fn := config.rewriteFunc()
+			results := concurrently(slices.Sorted(maps.Keys(imagesOutput)), registries.concurrency, func(image string) mirrorResult {
+				return mirrorImage(cmd.Context(), logger, client, image, fn)
+			})
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeMirrorText(cmd.OutOrStdout(), results)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Status == mirrorFailed {
+					failed++
+				}
+			}
+			logger.Info("Mirrored images", "images", len(results), "failed", failed)
+			if failed > 0 {
+				return fmt.Errorf("failed to mirror %d of %d images", failed, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(mirrorCmd)
+	registries.addFlags(mirrorCmd)
+	registries.addConcurrencyFlag(mirrorCmd)
+	rewrites.addFlags(mirrorCmd)
+	mirrorCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json).")
+	return mirrorCmd
+}
+
+// mirrorImage copies an image to the name fn maps it to unless it is there already.
+func mirrorImage(ctx context.Context, logger *slog.Logger, client *registry.Client, image string, fn images.RewriteFunc) mirrorResult {
+	result := mirrorResult{Image: image, Status: mirrorFailed}
+	fail := func(err error) mirrorResult {
+		result.Error = err.Error()
+		return result
+	}
+	source, err := reference.Parse(image)
+	if err != nil {
+		return fail(err)
+	}
+	var target reference.Reference
+	result.Target, target, err = copyTarget(fn, image)
+	if err != nil {
+		return fail(err)
+	}
+	descriptor, content, err := client.Manifest(ctx, source)
+	if err != nil {
+		return fail(err)
+	}
+	result.Digest = descriptor.Digest
+	target = pushReference(target)
+	existing, err := client.Head(ctx, target)
+	switch {
+	case err == nil && existing.Digest == descriptor.Digest:
+		result.Status = mirrorExists
+		return result
+	case err != nil && !errors.Is(err, registry.ErrNotFound):
+		return fail(err)
+	}
+	logger.InfoContext(ctx, "Mirroring image", "image", image, "target", result.Target)
+	_, _, err = copyImage(ctx, registrySource{client: client, ref: source}, registryDestination{client: client, ref: target}, descriptor, content, nil)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = client.PushManifest(ctx, target, descriptor.MediaType, content)
+	if err != nil {
+		return fail(err)
+	}
+	result.Status = mirrorCopied
+	return result
+}
+
+// writeMirrorText writes the status of every image, e.g. "copied: nginx:1.25 -> registry.example.com/mirror/docker.io/library/nginx:1.25".
+func writeMirrorText(w io.Writer, results []mirrorResult) error {
+	var sb strings.Builder
+	for _, result := range results {
+		if result.Status == mirrorFailed {
+			fmt.Fprintf(&sb, "%s: %s: %s\n", result.Status, result.Image, result.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s -> %s\n", result.Status, result.Image, result.Target)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+// mirrorManifests writes a deployment using an app image of two platforms and a sidecar image and returns its path and
+// the digest of the app image.
+func mirrorManifests(t *testing.T, r *registrytest.Registry, sidecar string) (string, string) {
+	t.Helper()
+	index := r.PushImage("app", "v1", linuxAMD64, linuxARM64)
+	r.PushImage("sidecar", "v1")
+	manifests := filepath.Join(t.TempDir(), "deployment.yaml")
+	require.NoError(t, os.WriteFile(manifests, []byte(imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", sidecar)), 0o600))
+	return manifests, index
+}
+
+func TestMirrorCmd(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	manifests, index := mirrorManifests(t, source, source.Host()+"/sidecar:v1")
+	target := registrytest.New(t)
+
+	mirrorCmd := newMirrorCmd()
+	var stdout bytes.Buffer
+	mirrorCmd.SetOut(&stdout)
+	mirrorCmd.SetErr(io.Discard)
+	mirrorCmd.SetArgs([]string{"--plain-http", "--to", target.Host() + "/mirror", manifests})
+	require.NoError(t, mirrorCmd.Execute())
+	app, _ := reference.Mirror(source.Host()+"/app:v1", target.Host()+"/mirror")
+	sidecar, _ := reference.Mirror(source.Host()+"/sidecar:v1", target.Host()+"/mirror")
+	require.Equal(t, "copied: "+source.Host()+"/app:v1 -> "+app+"\ncopied: "+source.Host()+"/sidecar:v1 -> "+sidecar+"\n", stdout.String())
+
+	// the multi-platform image keeps its digest and every platform
+	client := &registry.Client{PlainHTTP: true}
+	descriptor, err := client.Head(t.Context(), mustParseReference(t, app))
+	require.NoError(t, err)
+	require.Equal(t, index, descriptor.Digest)
+	platforms, err := client.Platforms(t.Context(), mustParseReference(t, app))
+	require.NoError(t, err)
+	require.Equal(t, []registry.Platform{linuxAMD64, linuxARM64}, platforms)
+
+	// images that are already there are skipped
+	mirrorCmd = newMirrorCmd()
+	stdout.Reset()
+	mirrorCmd.SetOut(&stdout)
+	mirrorCmd.SetErr(io.Discard)
+	mirrorCmd.SetArgs([]string{"--plain-http", "--to", target.Host() + "/mirror", "-o", "json", manifests})
+	require.NoError(t, mirrorCmd.Execute())
+	var results []mirrorResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 2)
+	require.Equal(t, mirrorResult{Image: source.Host() + "/app:v1", Target: app, Digest: index, Status: mirrorExists}, results[0])
+	require.Equal(t, mirrorExists, results[1].Status)
+}
+
+func TestMirrorCmdDigest(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	digest := source.PushImage("pinned", "v1")
+	manifests, _ := mirrorManifests(t, source, source.Host()+"/pinned@"+digest)
+	target := registrytest.New(t)
+
+	mirrorCmd := newMirrorCmd()
+	var stdout bytes.Buffer
+	mirrorCmd.SetOut(&stdout)
+	mirrorCmd.SetErr(io.Discard)
+	mirrorCmd.SetArgs([]string{"--plain-http", "-o", "json", "--rule", source.Host() + "/=" + target.Host() + "/", manifests})
+	require.NoError(t, mirrorCmd.Execute())
+	var results []mirrorResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 2)
+	require.Equal(t, mirrorResult{Image: source.Host() + "/pinned@" + digest, Target: target.Host() + "/pinned@" + digest, Digest: digest, Status: mirrorCopied}, results[1])
+	// an image pinned to a digest is pushed by its digest
+	descriptor, err := (&registry.Client{PlainHTTP: true}).Head(t.Context(), mustParseReference(t, target.Host()+"/pinned@"+digest))
+	require.NoError(t, err)
+	require.Equal(t, digest, descriptor.Digest)
+}
+
+func TestMirrorCmdErrors(t *testing.T) {
+	t.Parallel()
+	source := registrytest.New(t)
+	manifests, _ := mirrorManifests(t, source, source.Host()+"/missing:v1")
+	target := registrytest.New(t)
+
+	mirrorCmd := newMirrorCmd()
+	mirrorCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	mirrorCmd.SetOut(&stdout)
+	mirrorCmd.SetErr(io.Discard)
+	mirrorCmd.SetArgs([]string{"--plain-http", "--rule", source.Host() + "/=" + target.Host() + "/", manifests})
+	require.EqualError(t, mirrorCmd.Execute(), "failed to mirror 1 of 2 images")
+	require.Contains(t, stdout.String(), "copied: "+source.Host()+"/app:v1 -> "+target.Host()+"/app:v1\n")
+	require.Contains(t, stdout.String(), "failed: "+source.Host()+"/missing:v1: ")
+
+	mirrorCmd = newMirrorCmd()
+	mirrorCmd.SetOut(io.Discard)
+	mirrorCmd.SetErr(io.Discard)
+	mirrorCmd.SetArgs([]string{manifests})
+	require.EqualError(t, mirrorCmd.Execute(), "no rewrite rules, pins or to configured")
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/semver"
+)
+
+// Update levels of --max-update, from the most to the least conservative.
+const (
+	updatePatch = "patch"
+	updateMinor = "minor"
+	updateMajor = "major"
+)
+
+// outdatedResult lists the newer versions of an image with a semver-like tag.
+type outdatedResult struct {
+	Image string `json:"image"`
+	semver.Updates
+	Error string `json:"error,omitempty"`
+}
+
+func newOutdatedCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var checker outdatedChecker
+	var include, exclude, tagRegexes []string
+	var write bool
+	var maxUpdate string
+	var format string
+	var outdatedCmd = &cobra.Command{
+		Use:   "outdated PATH [PATH...]",
+		Short: "Find newer tags of the container images of Kubernetes resources",
+		Long: `Find newer tags of the container images of Kubernetes resources.
+
+The tags of the repository of every image with a semver-like tag, e.g. 1.25, v1.2.3 or 1.25.3-alpine, are listed and
+the newest patch, minor and major versions are reported. Only tags of the same scheme are considered: the same v prefix,
+number of segments and variant suffix, so 1.25.3-alpine is only updated to newer -alpine tags. Pre-releases such as
+2.0.0-rc.1 are left out unless --include-prerelease is set.
+
+With --write the tags are bumped in place to the newest version --max-update allows, keeping the formatting of the
+manifests. Images pinned to a digest are pinned to the digest of their new tag.`,
+		Example: `skim outdated path/to/manifests/
+skim outdated --exclude '^docker.io/library/' --tag-regex 'quay.io/example/app=^v\d+\.\d+\.\d+$' path/to/manifests/
+skim outdated --write --max-update minor path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			if !slices.Contains([]string{updatePatch, updateMinor, updateMajor}, maxUpdate) {
+				return fmt.Errorf("unknown value for max-update: %s", maxUpdate)
+			}
+			if write && sources.gitRef != "" {
+				return errors.New("--write can't be used with --git-ref")
+			}
+			var err error
+			checker.include, err = compileRegexps(include)
+			if err != nil {
+				return err
+			}
+			checker.exclude, err = compileRegexps(exclude)
+			if err != nil {
+				return err
+			}
+			checker.tagRegexes, err = parseTagRegexes(tagRegexes)
+			if err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			checker.client, err = registries.newClient()
+			if err != nil {
+				return err
+			}
+			checker.logger = logger
+			if write {
+				return rewriteFiles(cmd, extractor, args, func(occurrence images.Occurrence) (string, error) {
+					return checker.bump(cmd.Context(), occurrence.Image, maxUpdate)
+				})
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			var results []outdatedResult
+			for _, result := range concurrently(slices.Sorted(maps.Keys(imagesOutput)), registries.concurrency, func(image string) *outdatedResult {
+				return checker.check(cmd.Context(), image)
+			}) {
+				if result != nil {
+					results = append(results, *result)
+				}
+			}
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeOutdatedText(cmd.OutOrStdout(), results)
+			case "json":
+				if results == nil {
+					results = []outdatedResult{}
+				}
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to list the tags of %d of %d images", failed, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(outdatedCmd)
+	registries.addFlags(outdatedCmd)
+	registries.addConcurrencyFlag(outdatedCmd)
+	outdatedCmd.Flags().StringArrayVar(&include, "include", nil, "Only check images whose normalized repository (e.g. docker.io/library/nginx) matches this regular expression. Can be repeated.")
+	outdatedCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "Don't check images whose normalized repository matches this regular expression. Can be repeated.")
+	outdatedCmd.Flags().StringArrayVar(&tagRegexes, "tag-regex", nil, "Only consider tags matching a regular expression, in the form [REPOSITORY=]REGEX where REPOSITORY (e.g. nginx) limits it to a repository. Can be repeated.")
+	outdatedCmd.Flags().BoolVar(&checker.includePrereleases, "include-prerelease", false, "Consider pre-release tags such as 2.0.0-rc.1.")
+	outdatedCmd.Flags().BoolVarP(&write, "write", "w", false, "Bump the tags in the manifests in place instead of reporting the newer versions, stdin (-) is written to stdout.")
+	outdatedCmd.Flags().StringVar(&maxUpdate, "max-update", updateMajor, "The biggest update --write makes (options: patch, minor, major).")
+	outdatedCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json). text only lists the images that are outdated or failed.")
+	return outdatedCmd
+}
+
+// tagRegex restricts the tags considered for a repository, or for every repository when it is empty.
+type tagRegex struct {
+	repository string
+	regexp     *regexp.Regexp
+}
+
+// parseTagRegexes parses tag regexes in the form [REPOSITORY=]REGEX.
+func parseTagRegexes(values []string) ([]tagRegex, error) {
+	parsed := make([]tagRegex, 0, len(values))
+	for _, value := range values {
+		var repository string
+		expression := value
+		if before, after, ok := strings.Cut(value, "="); ok {
+			ref, err := reference.Parse(before)
+			if err != nil || ref.Tag != "" || ref.Digest != "" {
+				return nil, fmt.Errorf("invalid repository in tag regex %q", value)
+			}
+			repository, expression = ref.Name(), after
+		}
+		compiled, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex %q: %w", value, err)
+		}
+		parsed = append(parsed, tagRegex{repository: repository, regexp: compiled})
+	}
+	return parsed, nil
+}
+
+func compileRegexps(expressions []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(expressions))
+	for _, expression := range expressions {
+		r, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", expression, err)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// outdatedChecker finds the updates of images, the tags of every repository are listed once.
+type outdatedChecker struct {
+	client             *registry.Client
+	logger             *slog.Logger
+	include            []*regexp.Regexp
+	exclude            []*regexp.Regexp
+	tagRegexes         []tagRegex
+	includePrereleases bool
+
+	mu   sync.Mutex
+	tags map[string]*tagList
+}
+
+// tagList is the result of listing the tags of a repository.
+type tagList struct {
+	once sync.Once
+	tags []string
+	err  error
+}
+
+// check returns the updates of an image, or nil when the image isn't checked because it has no semver-like tag or is filtered out.
+func (c *outdatedChecker) check(ctx context.Context, image string) *outdatedResult {
+	ref, current, ok := c.parse(ctx, image)
+	if !ok {
+		return nil
+	}
+	result := &outdatedResult{Image: image}
+	tags, err := c.listTags(ctx, ref)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Updates = semver.FindUpdates(current, c.filterTags(ref, tags), c.includePrereleases)
+	return result
+}
+
+// bump returns the image with its tag bumped to the newest version maxUpdate allows, pinned to the new tag's digest when it had a digest.
+func (c *outdatedChecker) bump(ctx context.Context, image string, maxUpdate string) (string, error) {
+	result := c.check(ctx, image)
+	if result == nil {
+		return image, nil
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("failed to list the tags of %s: %s", image, result.Error)
+	}
+	var tag string
+	switch maxUpdate {
+	case updatePatch:
+		tag = result.Patch
+	case updateMinor:
+		tag = cmp.Or(result.Minor, result.Patch)
+	case updateMajor:
+		tag = result.Newest()
+	}
+	if tag == "" {
+		return image, nil
+	}
+	name, digest, pinned := strings.Cut(image, "@")
+	name = name[:strings.LastIndex(name, ":")+1] + tag
+	if !pinned {
+		return name, nil
+	}
+	ref, err := reference.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	descriptor, err := c.client.Head(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image %s: %w", name, err)
+	}
+	c.logger.DebugContext(ctx, "Pinned bumped image", "image", name, "previous-digest", digest, "digest", descriptor.Digest)
+	return name + "@" + descriptor.Digest, nil
+}
+
+// parse parses an image and its tag, it returns false for images that aren't checked.
+func (c *outdatedChecker) parse(ctx context.Context, image string) (reference.Reference, semver.Version, bool) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		c.logger.WarnContext(ctx, "Skipping invalid image reference", "image", image, "error", err)
+		return reference.Reference{}, semver.Version{}, false
+	}
+	current, ok := semver.Parse(ref.Tag)
+	if !ok {
+		c.logger.DebugContext(ctx, "Skipping image without a semver-like tag", "image", image)
+		return reference.Reference{}, semver.Version{}, false
+	}
+	name := ref.Name()
+	if len(c.include) > 0 && !slices.ContainsFunc(c.include, func(r *regexp.Regexp) bool { return r.MatchString(name) }) {
+		return reference.Reference{}, semver.Version{}, false
+	}
+	if slices.ContainsFunc(c.exclude, func(r *regexp.Regexp) bool { return r.MatchString(name) }) {
+		return reference.Reference{}, semver.Version{}, false
+	}
+	return ref, current, true
+}
+
+func (c *outdatedChecker) listTags(ctx context.Context, ref reference.Reference) ([]string, error) {
+	c.mu.Lock()
+	if c.tags == nil {
+		c.tags = make(map[string]*tagList)
+	}
+	list, ok := c.tags[ref.Name()]
+	if !ok {
+		list = &tagList{}
+		c.tags[ref.Name()] = list
+	}
+	c.mu.Unlock()
+	list.once.Do(func() {
+		c.logger.InfoContext(ctx, "Listing tags", "repository", ref.Name())
+		list.tags, list.err = c.client.Tags(ctx, ref)
+	})
+	return list.tags, list.err
+}
+
+// filterTags returns the tags matching every tag regex that applies to the repository of ref.
+func (c *outdatedChecker) filterTags(ref reference.Reference, tags []string) []string {
+	var filtered []string
+	for _, tag := range tags {
+		matches := true
+		for _, tagRegex := range c.tagRegexes {
+			if (tagRegex.repository == "" || tagRegex.repository == ref.Name()) && !tagRegex.regexp.MatchString(tag) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered
+}
+
+// writeOutdatedText writes a line per outdated image, e.g. "nginx:1.25.3: patch 1.25.5, minor 1.27.2, major 2.0.0".
+func writeOutdatedText(w io.Writer, results []outdatedResult) error {
+	var sb strings.Builder
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(&sb, "%s: error: %s\n", result.Image, result.Error)
+			continue
+		}
+		var updates []string
+		for _, update := range []struct{ level, tag string }{
+			{updatePatch, result.Patch},
+			{updateMinor, result.Minor},
+			{updateMajor, result.Major},
+		} {
+			if update.tag != "" {
+				updates = append(updates, update.level+" "+update.tag)
+			}
+		}
+		if len(updates) > 0 {
+			fmt.Fprintf(&sb, "%s: %s\n", result.Image, strings.Join(updates, ", "))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+	"github.com/yardenshoham/skim/pkg/semver"
+)
+
+func TestOutdatedCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	for _, tag := range []string{"1.25.2", "1.25.3", "1.26.0", "2.0.0", "3.0.0-rc.1", "latest"} {
+		r.PushImage("team/app", tag)
+	}
+	for _, tag := range []string{"16.1-alpine", "16.2-alpine", "16.3", "17.0-alpine"} {
+		r.PushImage("postgres", tag)
+	}
+	r.PaginateTags(2)
+	path := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_cluster.yaml", r.Host()+"/team/app:1.25.2", r.Host()+"/postgres:16.1-alpine")), 0o600))
+
+	outdatedCmd := newOutdatedCmd()
+	var stdout bytes.Buffer
+	outdatedCmd.SetOut(&stdout)
+	outdatedCmd.SetErr(io.Discard)
+	outdatedCmd.SetArgs([]string{"--plain-http", path})
+	require.NoError(t, outdatedCmd.Execute())
+	require.Equal(t, r.Host()+"/postgres:16.1-alpine: minor 16.2-alpine, major 17.0-alpine\n"+
+		r.Host()+"/team/app:1.25.2: patch 1.25.3, minor 1.26.0, major 2.0.0\n", stdout.String())
+
+	outdatedCmd = newOutdatedCmd()
+	stdout.Reset()
+	outdatedCmd.SetOut(&stdout)
+	outdatedCmd.SetErr(io.Discard)
+	outdatedCmd.SetArgs([]string{"--plain-http", "-o", "json", "--include-prerelease", "--exclude", "/postgres$", "--tag-regex", r.Host() + `/team/app=^\d+\.\d+\.\d+(-rc\.\d+)?$`, path})
+	require.NoError(t, outdatedCmd.Execute())
+	var results []outdatedResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Equal(t, []outdatedResult{{
+		Image:   r.Host() + "/team/app:1.25.2",
+		Updates: semver.Updates{Patch: "1.25.3", Minor: "1.26.0", Major: "3.0.0-rc.1"},
+	}}, results)
+}
+
+func TestOutdatedCmdWrite(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	for _, tag := range []string{"1.25.2", "1.25.3", "1.26.0", "2.0.0"} {
+		r.PushImage("team/app", tag)
+	}
+	current := r.PushImage("postgres", "16.1")
+	r.PushImage("postgres", "16.2")
+	bumped := r.PushImage("postgres", "16.3")
+	path := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_cluster.yaml", r.Host()+"/team/app:1.25.2", r.Host()+"/postgres:16.1@"+current)), 0o600))
+
+	outdatedCmd := newOutdatedCmd()
+	outdatedCmd.SetOut(io.Discard)
+	outdatedCmd.SetErr(io.Discard)
+	outdatedCmd.SetArgs([]string{"--plain-http", "--write", "--max-update", "minor", path})
+	require.NoError(t, outdatedCmd.Execute())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, imagesFixture(t, "images_cluster.yaml", r.Host()+"/team/app:1.26.0", r.Host()+"/postgres:16.3@"+bumped), string(content))
+}
+
+func TestOutdatedCmdErrors(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	path := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_cluster.yaml", r.Host()+"/team/app:1.25.2", "postgres:latest")), 0o600))
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{name: "missing repository", args: []string{"--plain-http", path}, err: "failed to list the tags of 1 of 1 images"},
+		{name: "max update", args: []string{"--max-update", "huge", path}, err: "unknown value for max-update: huge"},
+		{name: "tag regex", args: []string{"--tag-regex", "nginx=(", path}, err: `invalid tag regex "nginx=("`},
+		{name: "tag regex repository", args: []string{"--tag-regex", "nginx:1=.*", path}, err: `invalid repository in tag regex "nginx:1=.*"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			outdatedCmd := newOutdatedCmd()
+			outdatedCmd.SetOut(io.Discard)
+			outdatedCmd.SetErr(io.Discard)
+			outdatedCmd.SetArgs(tt.args)
+			require.ErrorContains(t, outdatedCmd.Execute(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+func newPinCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var printPins bool
+	var pinCmd = &cobra.Command{
+		Use:   "pin PATH [PATH...]",
+		Short: "Pin container image references in Kubernetes resources to their digests",
+		Long: `Pin container image references in Kubernetes resources to their digests.
+
+The tag of every image is resolved to the digest of its manifest in its registry and the references are rewritten
+in place to IMAGE:TAG@DIGEST. Images that already have a digest are left untouched. With --print the manifests are
+left untouched and the digests are printed as IMAGE=DIGEST, the format of the --pin flag of skim rewrite.`,
+		Example: `skim pin path/to/manifests/
+skim pin --print path/to/manifests/ > pins.txt`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			resolver := &digestResolver{client: client, logger: logger, digests: make(map[string]string)}
+			if !printPins {
+				return rewriteFiles(cmd, extractor, args, func(occurrence images.Occurrence) (string, error) {
+					pin, ok, err := resolver.resolve(cmd.Context(), occurrence.Image)
+					if err != nil || !ok {
+						return occurrence.Image, err
+					}
+					image, _ := reference.Pins{pin}.Apply(occurrence.Image)
+					return image, nil
+				})
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			for _, image := range slices.Sorted(maps.Keys(imagesOutput)) {
+				pin, ok, err := resolver.resolve(cmd.Context(), image)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", image, pin.Digest)
+				if err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(pinCmd, "fail")
+	registries.addFlags(pinCmd)
+	pinCmd.Flags().BoolVar(&printPins, "print", false, "Print the digests as IMAGE=DIGEST instead of pinning the images in the manifests.")
+	return pinCmd
+}
+
+// digestResolver resolves the tags of images to the digests of their manifests, every reference is resolved once.
+type digestResolver struct {
+	client  *registry.Client
+	logger  *slog.Logger
+	digests map[string]string
+}
+
+// resolve returns the pin of an image to the digest its tag points to.
+// It returns false for images that already have a digest and for references that can't be parsed.
+func (r *digestResolver) resolve(ctx context.Context, image string) (reference.Pin, bool, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		r.logger.WarnContext(ctx, "Skipping invalid image reference", "image", image, "error", err)
+		return reference.Pin{}, false, nil
+	}
+	if ref.Digest != "" {
+		return reference.Pin{}, false, nil
+	}
+	digest, ok := r.digests[ref.String()]
+	if !ok {
+		r.logger.InfoContext(ctx, "Resolving image", "image", ref.String())
+		descriptor, err := r.client.Head(ctx, ref)
+		if err != nil {
+			return reference.Pin{}, false, fmt.Errorf("failed to resolve image %s: %w", image, err)
+		}
+		digest = descriptor.Digest
+		r.digests[ref.String()] = digest
+	}
+	return reference.Pin{Image: ref.String(), Digest: digest}, true, nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+const pinManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`
+
+func TestPinCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.RequireCredentials("user", "secret")
+	app := r.PushManifest("team/app", "v1", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	sidecar := r.PushManifest("sidecar", "", registry.MediaTypeOCIManifest, []byte(pinManifest+"\n"))
+	dir := t.TempDir()
+	dockerConfig := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(dockerConfig, []byte(`{"auths":{"`+r.Host()+`":{"username":"user","password":"secret"}}}`), 0o600))
+	manifests := filepath.Join(dir, "manifests")
+	require.NoError(t, os.Mkdir(manifests, 0o755))
+	path := filepath.Join(manifests, "pod.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_pod.yaml", r.Host()+"/team/app:v1", r.Host()+"/sidecar@"+sidecar)), 0o600))
+
+	pinCmd := newPinCmd()
+	var stdout bytes.Buffer
+	pinCmd.SetOut(&stdout)
+	pinCmd.SetErr(io.Discard)
+	pinCmd.SetArgs([]string{"--plain-http", "--docker-config", dockerConfig, "--print", manifests})
+	require.NoError(t, pinCmd.Execute())
+	require.Equal(t, r.Host()+"/team/app:v1="+app+"\n", stdout.String())
+
+	pinCmd = newPinCmd()
+	pinCmd.SetOut(io.Discard)
+	pinCmd.SetErr(io.Discard)
+	pinCmd.SetArgs([]string{"--plain-http", "--docker-config", dockerConfig, manifests})
+	require.NoError(t, pinCmd.Execute())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, imagesFixture(t, "images_pod.yaml", r.Host()+"/team/app:v1@"+app, r.Host()+"/sidecar@"+sidecar), string(content))
+}
+
+func TestPinCmdImplicitLatest(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	latest := r.PushManifest("app", "latest", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	path := filepath.Join(t.TempDir(), "pod.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_pod.yaml", r.Host()+"/app", r.Host()+"/app:latest")), 0o600))
+
+	pinCmd := newPinCmd()
+	pinCmd.SetOut(io.Discard)
+	pinCmd.SetErr(io.Discard)
+	pinCmd.SetArgs([]string{"--plain-http", path})
+	require.NoError(t, pinCmd.Execute())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, imagesFixture(t, "images_pod.yaml", r.Host()+"/app:latest@"+latest, r.Host()+"/app:latest@"+latest), string(content))
+}
+
+func TestPinCmdErrors(t *testing.T) {
+	t.Parallel()
+	public := registrytest.New(t)
+	public.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	private := registrytest.New(t)
+	private.RequireCredentials("user", "secret")
+	private.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	tests := []struct {
+		name string
+		app  string
+		err  error
+	}{
+		{name: "missing tag", app: public.Host() + "/app:v2", err: registry.ErrNotFound},
+		{name: "no credentials", app: private.Host() + "/app:v1", err: registry.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pod.yaml")
+			content := imagesFixture(t, "images_pod.yaml", tt.app, public.Host()+"/app:v1")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			pinCmd := newPinCmd()
+			pinCmd.SetOut(io.Discard)
+			pinCmd.SetErr(io.Discard)
+			pinCmd.SetArgs([]string{"--plain-http", "--docker-config", filepath.Join(dir, "config.json"), path})
+			require.ErrorIs(t, pinCmd.Execute(), tt.err)
+			result, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, content, string(result))
+		})
+	}
+}
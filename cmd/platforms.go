@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// platformsResult lists the platforms an image provides.
+type platformsResult struct {
+	Image     string   `json:"image"`
+	Platforms []string `json:"platforms"`
+	// Missing are the required platforms the image doesn't provide.
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func newPlatformsCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var requiredPlatforms []string
+	var format string
+	var platformsCmd = &cobra.Command{
+		Use:   "platforms PATH [PATH...]",
+		Short: "List the platforms the container images of Kubernetes resources provide",
+		Long: `List the platforms the container images of Kubernetes resources provide.
+
+The platforms are read from the image index (manifest list) of multi-platform images and from the image config of
+single-platform images. With --require-platform the command fails when an image doesn't provide a required platform,
+a required platform without a variant, e.g. linux/arm64, is provided by every variant.`,
+		Example: `skim platforms path/to/manifests/
+skim platforms --require-platform linux/arm64 path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			required := make([]registry.Platform, 0, len(requiredPlatforms))
+			for _, s := range requiredPlatforms {
+				platform, err := registry.ParsePlatform(s)
+				if err != nil {
+					return err
+				}
+				required = append(required, platform)
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			results := concurrently(slices.Sorted(maps.Keys(imagesOutput)), registries.concurrency, func(image string) platformsResult {
+				result := platformsResult{Image: image, Platforms: []string{}}
+				ref, err := reference.Parse(image)
+				if err != nil {
+					result.Error = err.Error()
+					return result
+				}
+				platforms, err := client.Platforms(cmd.Context(), ref)
+				if err != nil {
+					result.Error = err.Error()
+					return result
+				}
+				for _, platform := range platforms {
+					result.Platforms = append(result.Platforms, platform.String())
+				}
+				for _, platform := range required {
+					if !slices.ContainsFunc(platforms, func(p registry.Platform) bool { return p.Matches(platform) }) {
+						result.Missing = append(result.Missing, platform.String())
+					}
+				}
+				return result
+			})
+			switch strings.ToLower(format) {
+			case "text":
+				err = writePlatformsText(cmd.OutOrStdout(), results)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			var failed, missing int
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+				if len(result.Missing) > 0 {
+					missing++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to look up the platforms of %d of %d images", failed, len(results))
+			}
+			if missing > 0 {
+				return fmt.Errorf("%d of %d images lack a required platform", missing, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(platformsCmd)
+	registries.addFlags(platformsCmd)
+	registries.addConcurrencyFlag(platformsCmd)
+	platformsCmd.Flags().StringArrayVar(&requiredPlatforms, "require-platform", nil, "Fail when an image doesn't provide this platform, in the form os/architecture[/variant]. Can be repeated.")
+	platformsCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json).")
+	return platformsCmd
+}
+
+// writePlatformsText writes a line per image, e.g. "nginx:1.25: linux/amd64, linux/arm64/v8 (missing linux/s390x)".
+func writePlatformsText(w io.Writer, results []platformsResult) error {
+	var sb strings.Builder
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(&sb, "%s: error: %s\n", result.Image, result.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s", result.Image, strings.Join(result.Platforms, ", "))
+		if len(result.Missing) > 0 {
+			fmt.Fprintf(&sb, " (missing %s)", strings.Join(result.Missing, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
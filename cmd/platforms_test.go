@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func TestPlatformsCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.PushImage("app", "v1", registry.Platform{OS: "linux", Architecture: "amd64"}, registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"})
+	r.PushImage("legacy", "v1")
+	path := filepath.Join(t.TempDir(), "pod.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_pod.yaml", r.Host()+"/app:v1", r.Host()+"/legacy:v1")), 0o600))
+
+	platformsCmd := newPlatformsCmd()
+	var stdout bytes.Buffer
+	platformsCmd.SetOut(&stdout)
+	platformsCmd.SetErr(io.Discard)
+	platformsCmd.SetArgs([]string{"--plain-http", path})
+	require.NoError(t, platformsCmd.Execute())
+	expected := r.Host() + "/app:v1: linux/amd64, linux/arm64/v8\n" + r.Host() + "/legacy:v1: linux/amd64\n"
+	require.Equal(t, expected, stdout.String())
+
+	platformsCmd = newPlatformsCmd()
+	platformsCmd.SilenceUsage = true
+	stdout.Reset()
+	platformsCmd.SetOut(&stdout)
+	platformsCmd.SetErr(io.Discard)
+	platformsCmd.SetArgs([]string{"--plain-http", "--require-platform", "linux/arm64", path})
+	require.EqualError(t, platformsCmd.Execute(), "1 of 2 images lack a required platform")
+	expected = r.Host() + "/app:v1: linux/amd64, linux/arm64/v8\n" + r.Host() + "/legacy:v1: linux/amd64 (missing linux/arm64)\n"
+	require.Equal(t, expected, stdout.String())
+
+	platformsCmd = newPlatformsCmd()
+	platformsCmd.SetOut(io.Discard)
+	platformsCmd.SetErr(io.Discard)
+	platformsCmd.SetArgs([]string{"--plain-http", "--require-platform", "arm64", path})
+	require.ErrorContains(t, platformsCmd.Execute(), "invalid platform")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+func newPostRenderCmd() *cobra.Command {
+	var sources sourceOptions
+	var rewrites rewriteOptions
+	var postRenderCmd = &cobra.Command{
+		Use:   "post-render",
+		Short: "Rewrite container image references in rendered manifests as a Helm post-renderer",
+		Long: `Rewrite container image references in rendered manifests as a Helm post-renderer.
+
+The manifests are read from stdin and written to stdout with the image fields skim knows about rewritten
+by the configured rules and pins, everything else is left untouched. Objects of unknown kinds are passed through.`,
+		Example: `helm install my-release chart --post-renderer skim --post-renderer-args post-render --post-renderer-args --config=skim.yaml
+helm template chart | skim post-render --rule 'docker.io/=mirror.example.com/dockerhub/'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			fn, err := rewrites.rewriteFunc()
+			if err != nil {
+				return err
+			}
+			content, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			result, err := extractor.RewriteManifests(cmd.Context(), content, "stdin", loggingRewriteFunc(logger, fn))
+			if err != nil {
+				return fmt.Errorf("failed to rewrite images: %w", err)
+			}
+			_, err = cmd.OutOrStdout().Write(result)
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(postRenderCmd, "skip")
+	rewrites.addFlags(postRenderCmd)
+	return postRenderCmd
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const postRenderDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestPostRenderCmd(t *testing.T) {
+	t.Parallel()
+	configPath := filepath.Join(t.TempDir(), "skim.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`rules:
+  - from: docker.io/
+    to: mirror.example.com/dockerhub/
+pins:
+  - image: nginx:1.21
+    digest: `+postRenderDigest+`
+`), 0o600))
+	rendered, err := os.ReadFile("../testdata/rewrite.yaml")
+	require.NoError(t, err)
+	crd := "---\napiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: unknown\nspec:\n  image: nginx:1.21\n"
+
+	postRenderCmd := newPostRenderCmd()
+	var stdout bytes.Buffer
+	postRenderCmd.SetIn(strings.NewReader(string(rendered) + crd))
+	postRenderCmd.SetOut(&stdout)
+	postRenderCmd.SetErr(io.Discard)
+	postRenderCmd.SetArgs([]string{"--config", configPath, "--rule", "quay.io/=mirror.example.com/quay/"})
+	require.NoError(t, postRenderCmd.Execute())
+	expected := strings.NewReplacer(
+		"image: 'busybox:1.35'", "image: 'mirror.example.com/dockerhub/library/busybox:1.35'",
+		`image: "nginx:1.21"`, `image: "mirror.example.com/dockerhub/library/nginx:1.21@`+postRenderDigest+`"`,
+		"image: quay.io/prometheus/node-exporter:v1.6.0", "image: mirror.example.com/quay/prometheus/node-exporter:v1.6.0",
+	).Replace(string(rendered)) + crd
+	require.Equal(t, expected, stdout.String())
+}
+
+func TestPostRenderCmdInvalidConfig(t *testing.T) {
+	t.Parallel()
+	configPath := filepath.Join(t.TempDir(), "skim.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("rules:\n  - from: docker.io/\n"), 0o600))
+	postRenderCmd := newPostRenderCmd()
+	postRenderCmd.SetIn(strings.NewReader(""))
+	postRenderCmd.SetOut(io.Discard)
+	postRenderCmd.SetErr(io.Discard)
+	postRenderCmd.SetArgs([]string{"--config", configPath})
+	require.ErrorContains(t, postRenderCmd.Execute(), "both sides must be set")
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// prepullHeader is written at the top of every DaemonSet skim prepull generates.
+const prepullHeader = "# This DaemonSet is generated by skim prepull, its init containers pull the images on every node.\n"
+
+// prepullNameLabel is the label selecting the pods of the DaemonSet.
+const prepullNameLabel = "app.kubernetes.io/name"
+
+const (
+	// prepullNoopVolume is the emptyDir volume the no-op binary is installed in.
+	prepullNoopVolume = "skim-noop"
+	// prepullNoopPath is where the no-op binary is installed, every pull container runs it.
+	prepullNoopPath = "/skim-noop/true"
+)
+
+// The parts of a DaemonSet skim prepull writes, in the order of the Kubernetes API.
+type (
+	prepullDaemonSet struct {
+		APIVersion string               `yaml:"apiVersion"`
+		Kind       string               `yaml:"kind"`
+		Metadata   prepullObjectMeta    `yaml:"metadata"`
+		Spec       prepullDaemonSetSpec `yaml:"spec"`
+	}
+	prepullObjectMeta struct {
+		Name      string            `yaml:"name,omitempty"`
+		Namespace string            `yaml:"namespace,omitempty"`
+		Labels    map[string]string `yaml:"labels"`
+	}
+	prepullDaemonSetSpec struct {
+		Selector struct {
+			MatchLabels map[string]string `yaml:"matchLabels"`
+		} `yaml:"selector"`
+		Template struct {
+			Metadata prepullObjectMeta `yaml:"metadata"`
+			Spec     prepullPodSpec    `yaml:"spec"`
+		} `yaml:"template"`
+	}
+	prepullPodSpec struct {
+		NodeSelector                  map[string]string       `yaml:"nodeSelector,omitempty"`
+		Tolerations                   []prepullToleration     `yaml:"tolerations,omitempty"`
+		ImagePullSecrets              []prepullLocalObjectRef `yaml:"imagePullSecrets,omitempty"`
+		TerminationGracePeriodSeconds int                     `yaml:"terminationGracePeriodSeconds"`
+		Volumes                       []prepullVolume         `yaml:"volumes"`
+		InitContainers                []prepullContainer      `yaml:"initContainers"`
+		Containers                    []prepullContainer      `yaml:"containers"`
+	}
+	prepullVolume struct {
+		Name     string   `yaml:"name"`
+		EmptyDir struct{} `yaml:"emptyDir"`
+	}
+	prepullContainer struct {
+		Name            string               `yaml:"name"`
+		Image           string               `yaml:"image"`
+		ImagePullPolicy string               `yaml:"imagePullPolicy"`
+		Command         []string             `yaml:"command,omitempty"`
+		VolumeMounts    []prepullVolumeMount `yaml:"volumeMounts,omitempty"`
+	}
+	prepullVolumeMount struct {
+		Name      string `yaml:"name"`
+		MountPath string `yaml:"mountPath"`
+		ReadOnly  bool   `yaml:"readOnly,omitempty"`
+	}
+	prepullToleration struct {
+		Key      string `yaml:"key,omitempty"`
+		Operator string `yaml:"operator"`
+		Value    string `yaml:"value,omitempty"`
+		Effect   string `yaml:"effect,omitempty"`
+	}
+	prepullLocalObjectRef struct {
+		Name string `yaml:"name"`
+	}
+)
+
+func newPrepullCmd() *cobra.Command {
+	var sources sourceOptions
+	var rewrites rewriteOptions
+	var output string
+	var name string
+	var namespace string
+	var nodeSelector map[string]string
+	var tolerationFlags []string
+	var pullSecrets []string
+	var noopImage string
+	var pauseImage string
+	var prepullCmd = &cobra.Command{
+		Use:   "prepull PATH [PATH...]",
+		Short: "Generate a DaemonSet pulling the container images of Kubernetes resources on every node",
+		Long: `Generate a DaemonSet pulling the container images of Kubernetes resources on every node.
+
+Every image is pulled by an init container that exits right away, the pod then sleeps in a pause container. Apply it
+before a rollout or a cluster upgrade so nodes have the images warm, and delete it afterwards.
+
+Init containers run one after another and the pulled images may have no shell or binaries at all, e.g. distroless
+images, so they don't run anything of their own: a first init container copies the static busybox of --noop-image to
+an emptyDir volume and every pull container runs it as true from there.
+
+Images are mapped with the same rules as skim rewrite when they are configured, e.g. to pull them from a mirror.
+Nodes are chosen with --node-selector and --toleration, the latter in the form KEY[=VALUE][:EFFECT] or * to tolerate
+every taint.`,
+		Example: `skim prepull -o prepull.yaml path/to/manifests/
+skim prepull --namespace kube-system --node-selector node-role.kubernetes.io/worker= --toleration dedicated=gpu:NoSchedule path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			tolerations := make([]prepullToleration, 0, len(tolerationFlags))
+			for _, s := range tolerationFlags {
+				toleration, err := parseToleration(s)
+				if err != nil {
+					return err
+				}
+				tolerations = append(tolerations, toleration)
+			}
+			config, err := rewrites.load()
+			if err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			if len(imagesOutput) == 0 {
+				return errors.New("no images found")
+			}
+			fn := config.rewriteFunc()
+			pulled := make(map[string]struct{})
+			for image := range imagesOutput {
+				target, err := fn(images.Occurrence{Image: image})
+				if err != nil {
+					return err
+				}
+				pulled[target] = struct{}{}
+			}
+			daemonSet := newPrepullDaemonSet(name, namespace, slices.Sorted(maps.Keys(pulled)), noopImage, pauseImage)
+			spec := &daemonSet.Spec.Template.Spec
+			spec.NodeSelector = nodeSelector
+			spec.Tolerations = tolerations
+			for _, secret := range pullSecrets {
+				spec.ImagePullSecrets = append(spec.ImagePullSecrets, prepullLocalObjectRef{Name: secret})
+			}
+			content, err := yaml.MarshalWithOptions(daemonSet, yaml.IndentSequence(true))
+			if err != nil {
+				return err
+			}
+			content = append([]byte(prepullHeader), content...)
+			if output == "-" {
+				_, err = cmd.OutOrStdout().Write(content)
+			} else {
+				err = os.WriteFile(output, content, 0o644)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			logger.Info("Generated DaemonSet", "images", len(pulled))
+			return nil
+		},
+	}
+	sources.addFlags(prepullCmd)
+	rewrites.addFlags(prepullCmd)
+	prepullCmd.Flags().StringVarP(&output, "output", "o", "-", "Path of the DaemonSet manifest to write, - writes it to stdout.")
+	prepullCmd.Flags().StringVar(&name, "name", "skim-prepull", "Name of the DaemonSet.")
+	prepullCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace of the DaemonSet, the namespace it is applied to when empty.")
+	prepullCmd.Flags().StringToStringVar(&nodeSelector, "node-selector", nil, "Node label the pods are scheduled on, in the form KEY=VALUE. Can be repeated.")
+	prepullCmd.Flags().StringArrayVar(&tolerationFlags, "toleration", nil, "Taint the pods tolerate, in the form KEY[=VALUE][:EFFECT] or * for every taint. Can be repeated.")
+	prepullCmd.Flags().StringArrayVar(&pullSecrets, "image-pull-secret", nil, "Name of a secret in the namespace of the DaemonSet to pull the images with. Can be repeated.")
+	prepullCmd.Flags().StringVar(&noopImage, "noop-image", "busybox:1.36.1-musl", "Image whose statically linked /bin/busybox the pull containers run as true.")
+	prepullCmd.Flags().StringVar(&pauseImage, "pause-image", "registry.k8s.io/pause:3.10", "Image of the container that keeps the pods running once the images are pulled.")
+	return prepullCmd
+}
+
+// newPrepullDaemonSet returns a DaemonSet with an init container pulling each image. A first init container installs
+// busybox from noopImage in a volume, the pull containers mount it and run it as true.
+func newPrepullDaemonSet(name string, namespace string, imagesToPull []string, noopImage string, pauseImage string) *prepullDaemonSet {
+	labels := map[string]string{prepullNameLabel: name}
+	daemonSet := &prepullDaemonSet{
+		APIVersion: "apps/v1",
+		Kind:       "DaemonSet",
+		Metadata:   prepullObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+	}
+	daemonSet.Spec.Selector.MatchLabels = labels
+	daemonSet.Spec.Template.Metadata.Labels = labels
+	spec := &daemonSet.Spec.Template.Spec
+	noopDir := path.Dir(prepullNoopPath)
+	spec.Volumes = []prepullVolume{{Name: prepullNoopVolume}}
+	spec.InitContainers = append(spec.InitContainers, prepullContainer{
+		Name:            "install-noop",
+		Image:           noopImage,
+		ImagePullPolicy: "IfNotPresent",
+		Command:         []string{"/bin/busybox", "cp", "/bin/busybox", prepullNoopPath},
+		VolumeMounts:    []prepullVolumeMount{{Name: prepullNoopVolume, MountPath: noopDir}},
+	})
+	for i, image := range imagesToPull {
+		spec.InitContainers = append(spec.InitContainers, prepullContainer{
+			Name:            prepullContainerName(i, image),
+			Image:           image,
+			ImagePullPolicy: "IfNotPresent",
+			Command:         []string{prepullNoopPath},
+			VolumeMounts:    []prepullVolumeMount{{Name: prepullNoopVolume, MountPath: noopDir, ReadOnly: true}},
+		})
+	}
+	spec.Containers = []prepullContainer{{Name: "pause", Image: pauseImage, ImagePullPolicy: "IfNotPresent"}}
+	return daemonSet
+}
+
+// invalidNameChars are the characters container names can't have.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// prepullContainerName names the init container pulling the i-th image after its repository, e.g. pull-0-nginx.
+// Container names are DNS labels of at most 63 characters.
+func prepullContainerName(i int, image string) string {
+	base, _, _ := strings.Cut(image, "@")
+	base = base[strings.LastIndex(base, "/")+1:]
+	base, _, _ = strings.Cut(base, ":")
+	name := fmt.Sprintf("pull-%d-%s", i, invalidNameChars.ReplaceAllString(strings.ToLower(base), "-"))
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// parseToleration parses a toleration in the form KEY[=VALUE][:EFFECT], or * to tolerate every taint.
+func parseToleration(s string) (prepullToleration, error) {
+	if s == "*" {
+		return prepullToleration{Operator: "Exists"}, nil
+	}
+	rest, effect, _ := strings.Cut(s, ":")
+	key, value, hasValue := strings.Cut(rest, "=")
+	if key == "" {
+		return prepullToleration{}, fmt.Errorf("invalid toleration %q, expected KEY[=VALUE][:EFFECT] or *", s)
+	}
+	switch effect {
+	case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+	default:
+		return prepullToleration{}, fmt.Errorf("invalid toleration %q, the effect must be NoSchedule, PreferNoSchedule or NoExecute", s)
+	}
+	toleration := prepullToleration{Key: key, Operator: "Exists", Effect: effect}
+	if hasValue {
+		toleration.Operator, toleration.Value = "Equal", value
+	}
+	return toleration, nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepullCmd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests.yaml")
+	require.NoError(t, os.WriteFile(manifests, []byte(checkManifests), 0o600))
+	output := filepath.Join(dir, "prepull.yaml")
+
+	prepullCmd := newPrepullCmd()
+	prepullCmd.SetOut(io.Discard)
+	prepullCmd.SetErr(io.Discard)
+	prepullCmd.SetArgs([]string{"-o", output, "-n", "kube-system", "--node-selector", "kubernetes.io/os=linux",
+		"--toleration", "dedicated=gpu:NoSchedule", "--toleration", "*", "--rule", "docker.io/=mirror.example.com/", manifests})
+	require.NoError(t, prepullCmd.Execute())
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), prepullHeader))
+
+	var daemonSet prepullDaemonSet
+	require.NoError(t, yaml.Unmarshal(content, &daemonSet))
+	require.Equal(t, "DaemonSet", daemonSet.Kind)
+	require.Equal(t, prepullObjectMeta{Name: "skim-prepull", Namespace: "kube-system", Labels: map[string]string{prepullNameLabel: "skim-prepull"}}, daemonSet.Metadata)
+	require.Equal(t, daemonSet.Metadata.Labels, daemonSet.Spec.Selector.MatchLabels)
+	spec := daemonSet.Spec.Template.Spec
+	require.Equal(t, map[string]string{"kubernetes.io/os": "linux"}, spec.NodeSelector)
+	require.Equal(t, []prepullToleration{{Key: "dedicated", Operator: "Equal", Value: "gpu", Effect: "NoSchedule"}, {Operator: "Exists"}}, spec.Tolerations)
+	require.Len(t, spec.InitContainers, 4)
+	var pulled []string
+	for _, container := range spec.InitContainers[1:] {
+		pulled = append(pulled, container.Name+" "+container.Image)
+	}
+	require.Equal(t, []string{
+		"pull-0-busybox mirror.example.com/library/busybox",
+		"pull-1-nginx mirror.example.com/library/nginx:latest",
+		"pull-2-sidecar registry.example.com/sidecar:v1.0.0",
+	}, pulled)
+	require.Equal(t, []prepullContainer{{Name: "pause", Image: "registry.k8s.io/pause:3.10", ImagePullPolicy: "IfNotPresent"}}, spec.Containers)
+}
+
+func TestPrepullCmdStdout(t *testing.T) {
+	t.Parallel()
+	prepullCmd := newPrepullCmd()
+	var stdout bytes.Buffer
+	prepullCmd.SetIn(strings.NewReader(imagesFixture(t, "images_pod.yaml", "nginx:1.25", "nginx:1.25")))
+	prepullCmd.SetOut(&stdout)
+	prepullCmd.SetErr(io.Discard)
+	prepullCmd.SetArgs([]string{"--image-pull-secret", "regcred", "-"})
+	require.NoError(t, prepullCmd.Execute())
+	var daemonSet prepullDaemonSet
+	require.NoError(t, yaml.Unmarshal(stdout.Bytes(), &daemonSet))
+	require.Empty(t, daemonSet.Metadata.Namespace)
+	require.Equal(t, []prepullLocalObjectRef{{Name: "regcred"}}, daemonSet.Spec.Template.Spec.ImagePullSecrets)
+	require.Len(t, daemonSet.Spec.Template.Spec.InitContainers, 2)
+	require.Equal(t, "nginx:1.25", daemonSet.Spec.Template.Spec.InitContainers[1].Image)
+}
+
+func TestPrepullCmdNoopVolume(t *testing.T) {
+	t.Parallel()
+	prepullCmd := newPrepullCmd()
+	var stdout bytes.Buffer
+	prepullCmd.SetIn(strings.NewReader(imagesFixture(t, "images_pod.yaml", "gcr.io/distroless/static:nonroot", "nginx:1.25")))
+	prepullCmd.SetOut(&stdout)
+	prepullCmd.SetErr(io.Discard)
+	prepullCmd.SetArgs([]string{"--noop-image", "mirror.example.com/busybox:musl", "-"})
+	require.NoError(t, prepullCmd.Execute())
+	var daemonSet prepullDaemonSet
+	require.NoError(t, yaml.Unmarshal(stdout.Bytes(), &daemonSet))
+	spec := daemonSet.Spec.Template.Spec
+	require.Equal(t, []prepullVolume{{Name: prepullNoopVolume}}, spec.Volumes)
+	require.Contains(t, stdout.String(), "emptyDir: {}")
+	require.Equal(t, prepullContainer{
+		Name:            "install-noop",
+		Image:           "mirror.example.com/busybox:musl",
+		ImagePullPolicy: "IfNotPresent",
+		Command:         []string{"/bin/busybox", "cp", "/bin/busybox", prepullNoopPath},
+		VolumeMounts:    []prepullVolumeMount{{Name: prepullNoopVolume, MountPath: "/skim-noop"}},
+	}, spec.InitContainers[0])
+	// the pulled images run the installed binary, not anything of their own
+	require.Equal(t, []prepullContainer{
+		{
+			Name:            "pull-0-static",
+			Image:           "gcr.io/distroless/static:nonroot",
+			ImagePullPolicy: "IfNotPresent",
+			Command:         []string{"/skim-noop/true"},
+			VolumeMounts:    []prepullVolumeMount{{Name: prepullNoopVolume, MountPath: "/skim-noop", ReadOnly: true}},
+		},
+		{
+			Name:            "pull-1-nginx",
+			Image:           "nginx:1.25",
+			ImagePullPolicy: "IfNotPresent",
+			Command:         []string{"/skim-noop/true"},
+			VolumeMounts:    []prepullVolumeMount{{Name: prepullNoopVolume, MountPath: "/skim-noop", ReadOnly: true}},
+		},
+	}, spec.InitContainers[1:])
+}
+
+func TestPrepullContainerName(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "pull-3-my-app", prepullContainerName(3, "quay.io/Example/my_app@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	require.Equal(t, "pull-0-app", prepullContainerName(0, "localhost:5000/app:v1"))
+	require.Len(t, prepullContainerName(10, "example.com/"+strings.Repeat("a", 100)), 63)
+}
+
+func TestParseToleration(t *testing.T) {
+	t.Parallel()
+	tests := map[string]prepullToleration{
+		"*":                       {Operator: "Exists"},
+		"dedicated":               {Key: "dedicated", Operator: "Exists"},
+		"dedicated:NoExecute":     {Key: "dedicated", Operator: "Exists", Effect: "NoExecute"},
+		"dedicated=gpu":           {Key: "dedicated", Operator: "Equal", Value: "gpu"},
+		"dedicated=gpu:NoExecute": {Key: "dedicated", Operator: "Equal", Value: "gpu", Effect: "NoExecute"},
+	}
+	for s, expected := range tests {
+		toleration, err := parseToleration(s)
+		require.NoError(t, err, s)
+		require.Equal(t, expected, toleration, s)
+	}
+	_, err := parseToleration("=gpu")
+	require.EqualError(t, err, `invalid toleration "=gpu", expected KEY[=VALUE][:EFFECT] or *`)
+	_, err = parseToleration("dedicated:Never")
+	require.EqualError(t, err, `invalid toleration "dedicated:Never", the effect must be NoSchedule, PreferNoSchedule or NoExecute`)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// registryOptions holds the flags that control how registries are accessed.
+type registryOptions struct {
+	dockerConfig string
+	plainHTTP    bool
+	retries      int
+	concurrency  int
+}
+
+func (o *registryOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.dockerConfig, "docker-config", "", "Path to the docker config.json with registry credentials. Defaults to config.json in $DOCKER_CONFIG or ~/.docker.")
+	cmd.Flags().BoolVar(&o.plainHTTP, "plain-http", false, "Talk to registries over HTTP instead of HTTPS, e.g. for a local registry.")
+	cmd.Flags().IntVar(&o.retries, "retries", 3, "How many times a registry request is retried after a network error or a 429 or 5xx response.")
+}
+
+// addConcurrencyFlag adds the flag limiting how many images are looked up at the same time, for commands looking up many images.
+func (o *registryOptions) addConcurrencyFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&o.concurrency, "concurrency", 8, "How many images are looked up in registries at the same time.")
+}
+
+// validateConcurrency rejects a --concurrency of less than one, no image would ever be looked up.
+func (o *registryOptions) validateConcurrency() error {
+	if o.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", o.concurrency)
+	}
+	return nil
+}
+
+// newClient creates a registry client configured by the flags.
+func (o *registryOptions) newClient() (*registry.Client, error) {
+	path := o.dockerConfig
+	if path == "" {
+		path = registry.DefaultDockerConfigPath()
+	}
+	config, err := registry.LoadDockerConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &registry.Client{
+		Keychain:  config,
+		PlainHTTP: o.plainHTTP,
+		Retries:   o.retries,
+	}, nil
+}
+
+// concurrently calls fn for every item with at most n calls running at the same time, n must be at least one.
+// The results are in the order of the items.
+func concurrently[T any, R any](items []T, n int, fn func(T) R) []R {
+	results := make([]R, len(items))
+	semaphore := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Go(func() {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i] = fn(item)
+		})
+	}
+	wg.Wait()
+	return results
+}
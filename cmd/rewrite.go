@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+func newRewriteCmd() *cobra.Command {
+	var sources sourceOptions
+	var rewrites rewriteOptions
+	var rewriteCmd = &cobra.Command{
+		Use:   "rewrite PATH [PATH...]",
+		Short: "Rewrite container image references in Kubernetes resources in place",
+		Example: `skim rewrite --rule 'docker.io/=mirror.example.com/dockerhub/' --rule 'quay.io/=mirror.example.com/quay/' path/to/manifests/
+helm template chart | skim rewrite --rule 'docker.io/=mirror.example.com/dockerhub/' -`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			fn, err := rewrites.rewriteFunc()
+			if err != nil {
+				return err
+			}
+			return rewriteFiles(cmd, extractor, args, fn)
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(rewriteCmd, "fail")
+	rewrites.addFlags(rewriteCmd)
+	return rewriteCmd
+}
+
+// rewriteConfig configures how images are rewritten, it is read from the file passed with --config.
+type rewriteConfig struct {
+	// Rules map image references to mirrors, see [reference.Rules].
+	Rules reference.Rules `json:"rules"`
+	// Pins pin image references to digests, see [reference.Pins].
+	Pins reference.Pins `json:"pins"`
+	// To is the prefix images no rule maps are mirrored under, see [reference.Mirror].
+	To string `json:"to"`
+}
+
+// validate checks the rules and pins and that there is at least one of them.
+func (c rewriteConfig) validate() error {
+	if len(c.Rules) == 0 && len(c.Pins) == 0 && c.To == "" {
+		return errors.New("no rewrite rules, pins or to configured")
+	}
+	for _, rule := range c.Rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, pin := range c.Pins {
+		if err := pin.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteFunc pins images and then maps them with the rules, so pins always match the original references.
+// Images no rule maps are mirrored under To when it is set.
+func (c rewriteConfig) rewriteFunc() images.RewriteFunc {
+	return func(occurrence images.Occurrence) (string, error) {
+		image, _ := c.Pins.Apply(occurrence.Image)
+		image, ok := c.Rules.Apply(image)
+		if !ok && c.To != "" {
+			image, _ = reference.Mirror(image, c.To)
+		}
+		return image, nil
+	}
+}
+
+// rewriteOptions holds the flags that configure how images are rewritten.
+type rewriteOptions struct {
+	rules      []string
+	pins       []string
+	configPath string
+	to         string
+}
+
+func (o *rewriteOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&o.rules, "rule", "r", nil, "Rewrite rule in the form FROM=TO, image references whose normalized form (e.g. docker.io/library/nginx:1.25) starts with FROM get it replaced by TO, FROM matches whole path components, tags and digests. Can be repeated, the first matching rule wins.")
+	cmd.Flags().StringArrayVar(&o.pins, "pin", nil, "Pin in the form IMAGE=DIGEST, image references matching IMAGE (e.g. nginx:1.25) get pinned to DIGEST. Can be repeated.")
+	cmd.Flags().StringVar(&o.to, "to", "", "Registry and repository prefix images no rule maps are mirrored under, named after their registry and repository, e.g. registry.example.com/mirror maps nginx:1.25 to registry.example.com/mirror/docker.io/library/nginx:1.25.")
+	cmd.Flags().StringVarP(&o.configPath, "config", "c", "", "Path to a YAML file with rules (list of from and to), pins (list of image and digest) and to, used in addition to --rule, --pin and --to.")
+}
+
+// config merges the configuration file with the rules and pins from the flags, the file's come first.
+func (o *rewriteOptions) config() (rewriteConfig, error) {
+	config, err := o.load()
+	if err != nil {
+		return rewriteConfig{}, err
+	}
+	return config, config.validate()
+}
+
+// load is like config but doesn't validate the merged configuration, e.g. because it is merged with more rules and pins.
+func (o *rewriteOptions) load() (rewriteConfig, error) {
+	var config rewriteConfig
+	if o.configPath != "" {
+		content, err := os.ReadFile(o.configPath)
+		if err != nil {
+			return rewriteConfig{}, fmt.Errorf("failed to read config: %w", err)
+		}
+		err = yaml.UnmarshalWithOptions(content, &config, yaml.Strict())
+		if err != nil {
+			return rewriteConfig{}, fmt.Errorf("failed to parse config %s: %w", o.configPath, err)
+		}
+	}
+	rules, err := reference.ParseRules(o.rules)
+	if err != nil {
+		return rewriteConfig{}, err
+	}
+	pins, err := reference.ParsePins(o.pins)
+	if err != nil {
+		return rewriteConfig{}, err
+	}
+	config.Rules = append(config.Rules, rules...)
+	config.Pins = append(config.Pins, pins...)
+	if o.to != "" {
+		config.To = o.to
+	}
+	return config, nil
+}
+
+func (o *rewriteOptions) rewriteFunc() (images.RewriteFunc, error) {
+	config, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	return config.rewriteFunc(), nil
+}
+
+// rewrittenFile is the rewritten content of a file, it is written once every file was rewritten.
+type rewrittenFile struct {
+	path    string
+	mode    fs.FileMode
+	content []byte
+}
+
+// rewriteFiles rewrites the image references in the manifests the arguments point to, stdin (-) is rewritten to stdout.
+// Files are changed in place and only when an image changed. They are found like when images are extracted, see
+// walkLocalFiles, except for archives which are skipped. Nothing is written until every file was rewritten, so a file
+// that fails leaves the tree as it was.
+func rewriteFiles(cmd *cobra.Command, extractor *images.Extractor, args []string, fn images.RewriteFunc) error {
+	ctx := cmd.Context()
+	logger := extractor.Logger
+	var stdout [][]byte
+	var files []rewrittenFile
+	for _, arg := range args {
+		if arg == "-" {
+			logger.Info("Processing stdin")
+			content, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			result, err := extractor.RewriteManifests(ctx, content, "stdin", loggingRewriteFunc(logger, fn))
+			if err != nil {
+				return fmt.Errorf("failed to rewrite images from stdin: %w", err)
+			}
+			stdout = append(stdout, result)
+			continue
+		}
+		err := walkLocalFiles(arg, func(_ fs.FS, _ string, path string) error {
+			if images.IsArchive(path) {
+				logger.Warn("Skipping archive, images in archives can't be rewritten", "path", path)
+				return nil
+			}
+			file, changed, err := rewriteFile(cmd, extractor, path, fn)
+			if err != nil {
+				return err
+			}
+			if changed {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %w", arg, err)
+		}
+	}
+	for _, result := range stdout {
+		_, err := cmd.OutOrStdout().Write(result)
+		if err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	for _, file := range files {
+		err := os.WriteFile(file.path, file.content, file.mode)
+		if err != nil {
+			return fmt.Errorf("failed to write file %s: %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+// rewriteFile rewrites the image references in a file and reports whether any changed.
+func rewriteFile(cmd *cobra.Command, extractor *images.Extractor, path string, fn images.RewriteFunc) (rewrittenFile, bool, error) {
+	extractor.Logger.Info("Processing file", "path", path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return rewrittenFile{}, false, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return rewrittenFile{}, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	result, err := extractor.RewriteManifests(cmd.Context(), content, path, loggingRewriteFunc(extractor.Logger, fn))
+	if err != nil {
+		return rewrittenFile{}, false, fmt.Errorf("failed to rewrite images in file %s: %w", path, err)
+	}
+	return rewrittenFile{path: path, mode: info.Mode().Perm(), content: result}, !bytes.Equal(content, result), nil
+}
+
+// loggingRewriteFunc wraps fn logging every image it changes.
+func loggingRewriteFunc(logger *slog.Logger, fn images.RewriteFunc) images.RewriteFunc {
+	return func(occurrence images.Occurrence) (string, error) {
+		image, err := fn(occurrence)
+		if err != nil {
+			return "", err
+		}
+		if image != occurrence.Image {
+			logger.Info("Rewriting image", "path", occurrence.Source, "line", occurrence.Line, "from", occurrence.Image, "to", image)
+		}
+		return image, nil
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteCmd(t *testing.T) {
+	t.Parallel()
+	content, err := os.ReadFile("../testdata/rewrite.yaml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "rewrite.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	rewriteCmd := newRewriteCmd()
+	rewriteCmd.SetOut(io.Discard)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"--rule", "docker.io/=mirror.example.com/dockerhub/", "--rule", "quay.io/=mirror.example.com/quay/", dir})
+	require.NoError(t, rewriteCmd.Execute())
+	result, err := os.ReadFile(path)
+	require.NoError(t, err)
+	expected := strings.NewReplacer(
+		"image: 'busybox:1.35'", "image: 'mirror.example.com/dockerhub/library/busybox:1.35'",
+		`image: "nginx:1.21"`, `image: "mirror.example.com/dockerhub/library/nginx:1.21"`,
+		"image: quay.io/prometheus/node-exporter:v1.6.0", "image: mirror.example.com/quay/prometheus/node-exporter:v1.6.0",
+	).Replace(string(content))
+	require.Equal(t, expected, string(result))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestRewriteCmdStdin(t *testing.T) {
+	t.Parallel()
+	file, err := os.Open("../testdata/pod.yaml")
+	require.NoError(t, err)
+	defer file.Close()
+
+	rewriteCmd := newRewriteCmd()
+	var stdout bytes.Buffer
+	rewriteCmd.SetIn(file)
+	rewriteCmd.SetOut(&stdout)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"-r", "docker.io/library/busybox=mirror.example.com/busybox", "-"})
+	require.NoError(t, rewriteCmd.Execute())
+	require.Contains(t, stdout.String(), "image: nginx:1.21.0\n")
+	require.Contains(t, stdout.String(), "image: mirror.example.com/busybox:1.35\n")
+}
+
+func TestRewriteCmdTo(t *testing.T) {
+	t.Parallel()
+	file, err := os.Open("../testdata/pod.yaml")
+	require.NoError(t, err)
+	defer file.Close()
+
+	rewriteCmd := newRewriteCmd()
+	var stdout bytes.Buffer
+	rewriteCmd.SetIn(file)
+	rewriteCmd.SetOut(&stdout)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"-r", "docker.io/library/busybox=mirror.example.com/busybox", "--to", "mirror.example.com/mirror", "-"})
+	require.NoError(t, rewriteCmd.Execute())
+	require.Contains(t, stdout.String(), "image: mirror.example.com/mirror/docker.io/library/nginx:1.21.0\n")
+	require.Contains(t, stdout.String(), "image: mirror.example.com/busybox:1.35\n")
+}
+
+func TestRewriteCmdRequiresRules(t *testing.T) {
+	t.Parallel()
+	rewriteCmd := newRewriteCmd()
+	rewriteCmd.SetOut(io.Discard)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"../testdata/pod.yaml"})
+	require.Error(t, rewriteCmd.Execute())
+}
+
+func TestRewriteCmdAllOrNothing(t *testing.T) {
+	t.Parallel()
+	pod, err := os.ReadFile("../testdata/pod.yaml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	// files are walked in lexical order, a.yaml is rewritten before b.yaml fails
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), pod, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("apiVersion: v1\nkind: Podonkadonk\n"), 0o600))
+
+	rewriteCmd := newRewriteCmd()
+	rewriteCmd.SetOut(io.Discard)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"--rule", "docker.io/=mirror.example.com/", dir})
+	require.ErrorContains(t, rewriteCmd.Execute(), "b.yaml")
+	result, err := os.ReadFile(filepath.Join(dir, "a.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, string(pod), string(result))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.yaml")))
+	rewriteCmd = newRewriteCmd()
+	rewriteCmd.SetOut(io.Discard)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"--rule", "docker.io/=mirror.example.com/", dir})
+	require.NoError(t, rewriteCmd.Execute())
+	result, err = os.ReadFile(filepath.Join(dir, "a.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(result), "image: mirror.example.com/library/nginx:1.21.0\n")
+}
+
+func TestRewriteCmdSymlink(t *testing.T) {
+	t.Parallel()
+	pod, err := os.ReadFile("../testdata/pod.yaml")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "pod.yaml")
+	require.NoError(t, os.WriteFile(target, pod, 0o600))
+	link := filepath.Join(dir, "link.yaml")
+	require.NoError(t, os.Symlink(target, link))
+
+	// a symbolic link passed as a path is followed like skim list follows it
+	rewriteCmd := newRewriteCmd()
+	rewriteCmd.SetOut(io.Discard)
+	rewriteCmd.SetErr(io.Discard)
+	rewriteCmd.SetArgs([]string{"--rule", "docker.io/=mirror.example.com/", link})
+	require.NoError(t, rewriteCmd.Execute())
+	result, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Contains(t, string(result), "image: mirror.example.com/library/nginx:1.21.0\n")
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	require.Equal(t, os.ModeSymlink, info.Mode().Type())
+}
@@ -18,6 +18,25 @@ func Execute() {
 	rootCmd := newRootCmd()
 	rootCmd.AddCommand(newVersionCmd())
 	rootCmd.AddCommand(newListCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newLockCmd())
+	rootCmd.AddCommand(newRewriteCmd())
+	rootCmd.AddCommand(newPostRenderCmd())
+	rootCmd.AddCommand(newFnCmd())
+	rootCmd.AddCommand(newPinCmd())
+	rootCmd.AddCommand(newVerifyCmd())
+	rootCmd.AddCommand(newPlatformsCmd())
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newOutdatedCmd())
+	rootCmd.AddCommand(newVerifySignaturesCmd())
+	rootCmd.AddCommand(newSBOMCmd())
+	rootCmd.AddCommand(newSaveCmd())
+	rootCmd.AddCommand(newLoadCmd())
+	rootCmd.AddCommand(newMirrorCmd())
+	rootCmd.AddCommand(newSizeCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newWebhookCmd())
+	rootCmd.AddCommand(newPrepullCmd())
 	rootCmd.SilenceUsage = true
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 )
 
@@ -11,3 +16,31 @@ func TestRootCmd(t *testing.T) {
 	rootCmd := newRootCmd()
 	require.NoError(t, rootCmd.Execute())
 }
+
+func TestConcurrencyFlag(t *testing.T) {
+	t.Parallel()
+	for _, newCmd := range []func() *cobra.Command{
+		newVerifyCmd, newPlatformsCmd, newOutdatedCmd, newVerifySignaturesCmd,
+		newSBOMCmd, newLoadCmd, newMirrorCmd, newSizeCmd,
+	} {
+		cmd := newCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs([]string{"--concurrency", "0", "testdata"})
+		require.EqualError(t, cmd.Execute(), "concurrency must be at least 1, got 0", cmd.Name())
+	}
+}
+
+// imagesFixture reads a manifest of testdata with its images set, ${1} is replaced by the first image and so on, e.g. to
+// use the images of a test registry.
+func imagesFixture(t *testing.T, name string, images ...string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("..", "testdata", name))
+	require.NoError(t, err)
+	return os.Expand(string(content), func(key string) string {
+		i, err := strconv.Atoi(key)
+		require.NoError(t, err)
+		require.True(t, i >= 1 && i <= len(images), "no image for ${%s} of %s", key, name)
+		return images[i-1]
+	})
+}
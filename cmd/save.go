@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+func newSaveCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var output string
+	var platformFlags []string
+	var saveCmd = &cobra.Command{
+		Use:   "save -o FILE PATH [PATH...]",
+		Short: "Save the container images of Kubernetes resources to an OCI image layout tarball",
+		Long: `Save the container images of Kubernetes resources to an OCI image layout tarball, e.g. for air-gapped installs.
+
+Every image is pulled from its registry into a single tarball, blobs shared by images are stored once. The index of
+the tarball names every image by its full reference, e.g. docker.io/library/nginx:1.25, in both the
+org.opencontainers.image.ref.name and io.containerd.image.name annotations.
+
+Multi-platform images keep their original index, so their digests don't change, with only the manifests of the
+platforms chosen with --platform. Every platform is saved when there are none, single-platform images must be for one
+of the platforms. Use skim load to push the tarball to another registry.`,
+		Example: `skim save -o bundle.tar path/to/manifests/
+skim save -o bundle.tar --platform linux/amd64 --platform linux/arm64 path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			platforms := make([]registry.Platform, 0, len(platformFlags))
+			for _, s := range platformFlags {
+				platform, err := registry.ParsePlatform(s)
+				if err != nil {
+					return err
+				}
+				platforms = append(platforms, platform)
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			var refs []reference.Reference
+			for _, image := range slices.Sorted(maps.Keys(imagesOutput)) {
+				ref, err := reference.Parse(image)
+				if err != nil {
+					return err
+				}
+				refs = append(refs, ref)
+			}
+
+			out := cmd.OutOrStdout()
+			if output != "-" {
+				// err is assigned rather than declared so the deferred function sees the result of the command
+				var file *os.File
+				file, err = os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", output, err)
+				}
+				defer func() {
+					err = errors.Join(err, file.Close())
+					if err != nil {
+						// a partial bundle would fail the install it is meant for
+						_ = os.Remove(output)
+					}
+				}()
+				out = file
+			}
+			writer, err := ocilayout.NewWriter(out)
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			for _, ref := range refs {
+				logger.Info("Saving image", "image", ref.String())
+				err = saveImage(cmd, client, writer, ref, platforms)
+				if err != nil {
+					return fmt.Errorf("failed to save %s: %w", ref, err)
+				}
+			}
+			err = writer.Close()
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			logger.Info("Saved images", "images", len(refs))
+			return nil
+		},
+	}
+	sources.addFlags(saveCmd)
+	registries.addFlags(saveCmd)
+	saveCmd.Flags().StringVarP(&output, "output", "o", "", "Path of the tarball to write, - writes it to stdout.")
+	_ = saveCmd.MarkFlagRequired("output")
+	saveCmd.Flags().StringArrayVar(&platformFlags, "platform", nil, "Platform of the images to save, in the form os/architecture[/variant]. Can be repeated, every platform is saved when there are none.")
+	return saveCmd
+}
+
+// saveImage writes an image with the manifests of the platforms and their blobs and adds it to the index.
+func saveImage(cmd *cobra.Command, client *registry.Client, writer *ocilayout.Writer, ref reference.Reference, platforms []registry.Platform) error {
+	descriptor, content, err := client.Manifest(cmd.Context(), ref)
+	if err != nil {
+		return err
+	}
+	descriptor, content, err = copyImage(cmd.Context(), registrySource{client: client, ref: ref}, layoutDestination{writer: writer}, descriptor, content, platforms)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteBlob(descriptor.Digest, descriptor.Size, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	writer.AddManifest(descriptor, ref.String())
+	return nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+var (
+	linuxAMD64 = registry.Platform{OS: "linux", Architecture: "amd64"}
+	linuxARM64 = registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+)
+
+// saveBundle saves the images of a deployment using an app image and a sidecar image of two platforms to a tarball.
+func saveBundle(t *testing.T, r *registrytest.Registry, extraArgs ...string) (string, string) {
+	t.Helper()
+	index := r.PushImage("app", "v1", linuxAMD64, linuxARM64)
+	r.PushImage("sidecar", "v1", linuxAMD64, linuxARM64)
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, os.WriteFile(manifests, []byte(imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/sidecar:v1")), 0o600))
+	bundle := filepath.Join(dir, "bundle.tar")
+	saveCmd := newSaveCmd()
+	saveCmd.SetOut(io.Discard)
+	saveCmd.SetErr(io.Discard)
+	saveCmd.SetArgs(append([]string{"--plain-http", "-o", bundle, manifests}, extraArgs...))
+	require.NoError(t, saveCmd.Execute())
+	return bundle, index
+}
+
+func TestSaveCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	bundle, index := saveBundle(t, r, "--platform", "linux/arm64")
+
+	layout, err := ocilayout.Open(bundle)
+	require.NoError(t, err)
+	defer layout.Close()
+	require.Len(t, layout.Index.Manifests, 2)
+	app := layout.Index.Manifests[0]
+	require.Equal(t, index, app.Digest)
+	require.Equal(t, registry.MediaTypeOCIIndex, app.MediaType)
+	require.Equal(t, r.Host()+"/app:v1", app.Annotations[ocilayout.AnnotationRefName])
+	require.Equal(t, r.Host()+"/sidecar:v1", layout.Index.Manifests[1].Annotations[ocilayout.AnnotationImageName])
+
+	content, err := layout.ReadBlob(index, maxManifestSize)
+	require.NoError(t, err)
+	manifest, err := registry.ParseManifest(app, content)
+	require.NoError(t, err)
+	// the index is kept as is, only the manifest of the chosen platform is saved
+	require.Len(t, manifest.Manifests, 2)
+	require.False(t, layout.HasBlob(manifest.Manifests[0].Digest))
+	require.True(t, layout.HasBlob(manifest.Manifests[1].Digest))
+	require.True(t, layout.HasBlob(registry.Digest(registrytest.BaseLayer)))
+}
+
+func TestSaveCmdErrors(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, os.WriteFile(manifests, []byte(imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/missing:v1")), 0o600))
+	r.PushImage("app", "v1")
+	bundle := filepath.Join(dir, "bundle.tar")
+	saveCmd := newSaveCmd()
+	saveCmd.SetOut(io.Discard)
+	saveCmd.SetErr(io.Discard)
+	saveCmd.SetArgs([]string{"--plain-http", "-o", bundle, manifests})
+	require.ErrorContains(t, saveCmd.Execute(), "failed to save "+r.Host()+"/missing:v1")
+	// partial bundles are removed
+	require.NoFileExists(t, bundle)
+
+	// single-platform images are checked against the platforms too
+	r.PushImage("missing", "v1")
+	saveCmd = newSaveCmd()
+	saveCmd.SetOut(io.Discard)
+	saveCmd.SetErr(io.Discard)
+	saveCmd.SetArgs([]string{"--plain-http", "-o", bundle, "--platform", "linux/arm64", manifests})
+	require.ErrorContains(t, saveCmd.Execute(), "is for linux/amd64, none of the platforms")
+	require.NoFileExists(t, bundle)
+
+	saveCmd = newSaveCmd()
+	saveCmd.SetOut(io.Discard)
+	saveCmd.SetErr(io.Discard)
+	saveCmd.SetArgs([]string{"--plain-http", "-o", bundle, "--platform", "linux", manifests})
+	require.EqualError(t, saveCmd.Execute(), `invalid platform "linux", expected os/architecture[/variant]`)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/sbom"
+)
+
+func newSBOMCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var format string
+	var name string
+	var version string
+	var resolve bool
+	var sbomCmd = &cobra.Command{
+		Use:   "sbom PATH [PATH...]",
+		Short: "Generate a CycloneDX or SPDX bill of materials of the container images of Kubernetes resources",
+		Long: `Generate a deployment-level software bill of materials of the container images of Kubernetes resources.
+
+The bill of materials describes the deployment, the objects it consists of and the container images they use. Every
+image is a container component with an OCI package URL, e.g. pkg:oci/nginx?repository_url=docker.io/library/nginx&tag=1.25,
+and every object depends on the images it uses. It can be uploaded to tools like Dependency-Track.
+
+Images pinned to a digest have it in their package URL. Use --resolve-digests to look up the digests of the other
+images in their registries.`,
+		Example: `skim sbom path/to/manifests/ > bom.json
+skim sbom --format spdx --name my-app --version 1.2.0 path/to/manifests/
+helm template my-app chart/ | skim sbom --resolve-digests -`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			format = strings.ToLower(format)
+			if format != "cyclonedx" && format != "spdx" {
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			occurrences, err := sources.extractOccurrences(cmd, extractor, args)
+			if err != nil {
+				return err
+			}
+			inventory, invalid := sbom.NewInventory(occurrences)
+			for _, image := range invalid {
+				logger.Warn("Skipping invalid image reference", "image", image)
+			}
+			if resolve {
+				client, err := registries.newClient()
+				if err != nil {
+					return err
+				}
+				unresolved := make([]*sbom.Image, 0, len(inventory.Images))
+				for i := range inventory.Images {
+					unresolved = append(unresolved, &inventory.Images[i])
+				}
+				failed := 0
+				for _, err := range concurrently(unresolved, registries.concurrency, func(image *sbom.Image) error {
+					if image.Digest != "" {
+						return nil
+					}
+					descriptor, err := client.Head(cmd.Context(), image.Reference)
+					if err != nil {
+						return err
+					}
+					image.Digest = descriptor.Digest
+					return nil
+				}) {
+					if err != nil {
+						logger.Error("Failed to resolve digest", "error", err)
+						failed++
+					}
+				}
+				if failed > 0 {
+					return fmt.Errorf("failed to resolve the digests of %d of %d images", failed, len(inventory.Images))
+				}
+			}
+			inventory.Name = name
+			if inventory.Name == "" {
+				inventory.Name = defaultSBOMName(args, sources.gitRepo, sources.gitRef)
+			}
+			inventory.Version = version
+			inventory.Tool = sbom.Tool{Name: "skim"}
+			if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+				inventory.Tool.Version = info.Main.Version
+			}
+			inventory.Created = time.Now()
+			var document any
+			if format == "spdx" {
+				document = inventory.SPDX()
+			} else {
+				document = inventory.CycloneDX()
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(document)
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			logger.Info("Generated SBOM", "images", len(inventory.Images), "objects", len(inventory.Workloads))
+			return nil
+		},
+	}
+	sources.addFlags(sbomCmd)
+	registries.addFlags(sbomCmd)
+	registries.addConcurrencyFlag(sbomCmd)
+	sbomCmd.Flags().StringVarP(&format, "format", "o", "cyclonedx", "Output format (options: cyclonedx, spdx). Both are JSON.")
+	sbomCmd.Flags().StringVar(&name, "name", "", "Name of the deployment the bill of materials describes (default the base name of the first PATH).")
+	sbomCmd.Flags().StringVar(&version, "version", "", "Version of the deployment the bill of materials describes, e.g. the version of the release.")
+	sbomCmd.Flags().BoolVar(&resolve, "resolve-digests", false, "Resolve the tags of images that aren't pinned to a digest in their registries.")
+	return sbomCmd
+}
+
+// defaultSBOMName names the deployment after the first path, e.g. manifests for path/to/manifests/. Without paths,
+// the entire tree of a git revision is read, it is named after the repository, e.g. skim for path/to/skim.git, or the
+// revision when the repository has no name.
+func defaultSBOMName(args []string, gitRepo string, gitRef string) string {
+	if len(args) == 0 {
+		repo, err := filepath.Abs(gitRepo)
+		if err != nil {
+			return gitRef
+		}
+		name := strings.TrimSuffix(filepath.Base(repo), ".git")
+		if name == "" || name == string(filepath.Separator) {
+			return gitRef
+		}
+		return name
+	}
+	if args[0] == "-" {
+		return "stdin"
+	}
+	return filepath.Base(filepath.Clean(args[0]))
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+	"github.com/yardenshoham/skim/pkg/sbom"
+)
+
+func TestSBOMCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	pinned := r.PushImage("app", "v1")
+	resolved := r.PushImage("sidecar", "v1")
+	dir := filepath.Join(t.TempDir(), "release")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	manifests := imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1@"+pinned, r.Host()+"/sidecar:v1")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(manifests), 0o600))
+
+	sbomCmd := newSBOMCmd()
+	var stdout bytes.Buffer
+	sbomCmd.SetOut(&stdout)
+	sbomCmd.SetErr(io.Discard)
+	sbomCmd.SetArgs([]string{dir})
+	require.NoError(t, sbomCmd.Execute())
+	var bom sbom.CycloneDX
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &bom))
+	require.Equal(t, "release", bom.Metadata.Component.Name)
+	require.Equal(t, "skim", bom.Metadata.Tools.Components[0].Name)
+	require.Len(t, bom.Components, 3)
+	require.Equal(t, "Deployment", bom.Components[0].Properties[1].Value)
+	app := "pkg:oci/app@sha256%3A" + pinned[7:] + "?repository_url=" + sbomEscapeHost(r.Host()) + "/app&tag=v1"
+	sidecar := "pkg:oci/sidecar?repository_url=" + sbomEscapeHost(r.Host()) + "/sidecar&tag=v1"
+	require.Equal(t, app, bom.Components[1].PURL)
+	require.Equal(t, sidecar, bom.Components[2].PURL)
+	require.Equal(t, []sbom.CycloneDXDependency{
+		{Ref: "release", DependsOn: []string{"apps/v1/Deployment/production/web"}},
+		{Ref: "apps/v1/Deployment/production/web", DependsOn: []string{app, sidecar}},
+		{Ref: app, DependsOn: []string{}},
+		{Ref: sidecar, DependsOn: []string{}},
+	}, bom.Dependencies)
+
+	sbomCmd = newSBOMCmd()
+	stdout.Reset()
+	sbomCmd.SetOut(&stdout)
+	sbomCmd.SetErr(io.Discard)
+	sbomCmd.SetArgs([]string{"--plain-http", "--resolve-digests", "--format", "spdx", "--name", "my-app", "--version", "1.2.0", dir})
+	require.NoError(t, sbomCmd.Execute())
+	var document sbom.SPDX
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &document))
+	require.Equal(t, "my-app", document.Name)
+	require.Len(t, document.Packages, 4)
+	require.Equal(t, "1.2.0", document.Packages[0].VersionInfo)
+	require.Equal(t, []sbom.SPDXChecksum{{Algorithm: "SHA256", Value: resolved[7:]}}, document.Packages[2].Checksums)
+	require.Equal(t, "pkg:oci/sidecar@sha256%3A"+resolved[7:]+"?repository_url="+sbomEscapeHost(r.Host())+"/sidecar&tag=v1",
+		document.Packages[2].ExternalRefs[0].Locator)
+	require.Len(t, document.Relationships, 4)
+}
+
+func TestSBOMCmdErrors(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	path := filepath.Join(t.TempDir(), "deployment.yaml")
+	manifests := imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/sidecar:v1")
+	require.NoError(t, os.WriteFile(path, []byte(manifests), 0o600))
+	tests := map[string][]string{
+		"unknown value for format: yaml":                 {"--format", "yaml", path},
+		"failed to resolve the digests of 2 of 2 images": {"--plain-http", "--resolve-digests", path},
+	}
+	for expected, args := range tests {
+		sbomCmd := newSBOMCmd()
+		sbomCmd.SetOut(io.Discard)
+		sbomCmd.SetErr(io.Discard)
+		sbomCmd.SetArgs(args)
+		require.EqualError(t, sbomCmd.Execute(), expected)
+	}
+}
+
+func TestSBOMCmdGitRef(t *testing.T) {
+	t.Parallel()
+	repo := newGitRepo(t)
+
+	sbomCmd := newSBOMCmd()
+	var stdout bytes.Buffer
+	sbomCmd.SetOut(&stdout)
+	sbomCmd.SetErr(io.Discard)
+	sbomCmd.SetArgs([]string{"--git-repo", repo, "--git-ref", "v2"})
+	require.NoError(t, sbomCmd.Execute())
+	var bom sbom.CycloneDX
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &bom))
+	// the bare clone is repo.git
+	require.Equal(t, "repo", bom.Metadata.Component.Name)
+	require.NotEmpty(t, bom.Components)
+}
+
+// sbomEscapeHost escapes the port separator of a registry host the way package URLs do.
+func sbomEscapeHost(host string) string {
+	return strings.Replace(host, ":", "%3A", 1)
+}
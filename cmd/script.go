@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// scriptTools are the tools skim list --format script writes scripts for.
+var scriptTools = []string{"docker", "podman", "crane", "skopeo", "kind", "k3d"}
+
+// scriptOptions configure the script skim list --format script writes.
+type scriptOptions struct {
+	tool string
+	// cluster is the kind or k3d cluster images are loaded into, the tool's default cluster when empty.
+	cluster string
+	// rewrites map the images, they are pulled from the new name or copied to it by crane and skopeo.
+	rewrites rewriteOptions
+}
+
+// validate checks the tool and that crane and skopeo, which copy images, have somewhere to copy them to.
+func (o *scriptOptions) validate() (rewriteConfig, error) {
+	if !slices.Contains(scriptTools, o.tool) {
+		return rewriteConfig{}, fmt.Errorf("unknown value for tool: %s", o.tool)
+	}
+	if o.cluster != "" && o.tool != "kind" && o.tool != "k3d" {
+		return rewriteConfig{}, fmt.Errorf("--cluster only applies to kind and k3d, not %s", o.tool)
+	}
+	if o.tool == "crane" || o.tool == "skopeo" {
+		config, err := o.rewrites.config()
+		if err != nil {
+			return rewriteConfig{}, fmt.Errorf("%s copies images, it requires rewrite rules: %w", o.tool, err)
+		}
+		return config, nil
+	}
+	return o.rewrites.load()
+}
+
+// writeScript writes a POSIX shell script getting every image into a local environment with the tool of o.
+// docker and podman pull the images, kind and k3d pull them with docker and load them into the cluster under the names
+// the manifests use, crane and skopeo copy every platform of the images to the names fn maps them to, e.g. a registry
+// the cluster pulls from.
+// Nothing is written when an image can't be mapped.
+func writeScript(w io.Writer, o scriptOptions, fn images.RewriteFunc, imagesOutput map[string]struct{}) error {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&sb, "# This script is generated by skim list, it gets %d images with %s.\n", len(imagesOutput), o.tool)
+	sb.WriteString("set -eu\n")
+	for _, image := range slices.Sorted(maps.Keys(imagesOutput)) {
+		switch o.tool {
+		case "crane", "skopeo":
+			_, ref, err := copyTarget(fn, image)
+			if err != nil {
+				return err
+			}
+			target := pushReference(ref).String()
+			if o.tool == "crane" {
+				writeCommand(&sb, "crane", "copy", image, target)
+			} else {
+				writeCommand(&sb, "skopeo", "copy", "--all", "docker://"+image, "docker://"+target)
+			}
+			continue
+		}
+		pulled, err := fn(images.Occurrence{Image: image})
+		if err != nil {
+			return err
+		}
+		if o.tool == "docker" || o.tool == "podman" {
+			writeCommand(&sb, o.tool, "pull", pulled)
+			continue
+		}
+		// pods pull the images by the names in the manifests, images pulled from another name are loaded under them
+		writeCommand(&sb, "docker", "pull", pulled)
+		if pulled != image {
+			if strings.Contains(image, "@") {
+				return fmt.Errorf("can't load %s into %s under its name, docker can't tag images by digest", image, o.tool)
+			}
+			writeCommand(&sb, "docker", "tag", pulled, image)
+		}
+		if o.tool == "kind" {
+			writeCommand(&sb, withCluster([]string{"kind", "load", "docker-image", image}, "--name", o.cluster)...)
+		} else {
+			writeCommand(&sb, withCluster([]string{"k3d", "image", "import", image}, "--cluster", o.cluster)...)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// withCluster adds the flag naming the cluster to a command when there is one.
+func withCluster(command []string, flag string, cluster string) []string {
+	if cluster == "" {
+		return command
+	}
+	return append(command, flag, cluster)
+}
+
+// writeCommand writes a command line with its arguments quoted for the shell.
+func writeCommand(sb *strings.Builder, command ...string) {
+	for i, arg := range command {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(shellQuote(arg))
+	}
+	sb.WriteByte('\n')
+}
+
+// shellSafe matches arguments the shell reads as they are.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// shellQuote quotes s for a POSIX shell, in single quotes unless it is safe as it is.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scriptPod is a pod whose images are listed as a script.
+const scriptPod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+    - name: app
+      image: nginx:1.25
+    - name: sidecar
+      image: ` + scriptSidecar + `
+`
+
+// scriptSidecar is the image of the sidecar of scriptPod, it has a digest.
+const scriptSidecar = "registry.example.com/sidecar:v1@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestListCmdScript(t *testing.T) {
+	t.Parallel()
+	const header = "#!/bin/sh\n# This script is generated by skim list, it gets 2 images with %s.\nset -eu\n"
+	const sidecar = scriptSidecar
+	tests := []struct {
+		tool     string
+		args     []string
+		expected string
+	}{
+		{
+			tool:     "docker",
+			expected: "docker pull nginx:1.25\ndocker pull " + sidecar + "\n",
+		},
+		{
+			tool:     "podman",
+			args:     []string{"--rule", "docker.io/=mirror.example.com/"},
+			expected: "podman pull mirror.example.com/library/nginx:1.25\npodman pull " + sidecar + "\n",
+		},
+		{
+			tool:     "kind",
+			args:     []string{"--cluster", "demo"},
+			expected: "docker pull nginx:1.25\nkind load docker-image nginx:1.25 --name demo\ndocker pull " + sidecar + "\nkind load docker-image " + sidecar + " --name demo\n",
+		},
+		{
+			tool:     "k3d",
+			expected: "docker pull nginx:1.25\nk3d image import nginx:1.25\ndocker pull " + sidecar + "\nk3d image import " + sidecar + "\n",
+		},
+		{
+			// the image pulled from the mirror is loaded under the name the pod uses
+			tool:     "k3d",
+			args:     []string{"--rule", "docker.io/=mirror.example.com/"},
+			expected: "docker pull mirror.example.com/library/nginx:1.25\ndocker tag mirror.example.com/library/nginx:1.25 nginx:1.25\nk3d image import nginx:1.25\ndocker pull " + sidecar + "\nk3d image import " + sidecar + "\n",
+		},
+		{
+			tool:     "crane",
+			args:     []string{"--to", "localhost:5001"},
+			expected: "crane copy nginx:1.25 localhost:5001/docker.io/library/nginx:1.25\ncrane copy " + sidecar + " localhost:5001/registry.example.com/sidecar:v1\n",
+		},
+		{
+			tool:     "skopeo",
+			args:     []string{"--to", "localhost:5001"},
+			expected: "skopeo copy --all docker://nginx:1.25 docker://localhost:5001/docker.io/library/nginx:1.25\nskopeo copy --all docker://" + sidecar + " docker://localhost:5001/registry.example.com/sidecar:v1\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.tool, func(t *testing.T) {
+			t.Parallel()
+			listCmd := newListCmd()
+			var stdout bytes.Buffer
+			listCmd.SetIn(strings.NewReader(scriptPod))
+			listCmd.SetOut(&stdout)
+			listCmd.SetErr(io.Discard)
+			listCmd.SetArgs(append([]string{"--format", "script", "--tool", test.tool, "-"}, test.args...))
+			require.NoError(t, listCmd.Execute())
+			require.Equal(t, strings.Replace(header, "%s", test.tool, 1)+test.expected, stdout.String())
+		})
+	}
+}
+
+func TestListCmdScriptErrors(t *testing.T) {
+	t.Parallel()
+	tests := map[string][]string{
+		"unknown value for tool: helm": {"-o", "script", "--tool", "helm"},
+		"can't load " + scriptSidecar + " into kind under its name, docker can't tag images by digest": {"-o", "script", "--tool", "kind", "--to", "localhost:5001"},
+		"unknown value for format: yaml":                                                                                                     {"-o", "yaml"},
+		"--cluster only applies to kind and k3d, not docker":                                                                                 {"-o", "script", "--cluster", "demo"},
+		"--watch only prints images, it can't be used with --format script":                                                                  {"-o", "script", "--watch"},
+		"rewrite rules, pins and --to only apply to --format script":                                                                         {"--to", "localhost:5001"},
+		"crane copies images, it requires rewrite rules: no rewrite rules, pins or to configured":                                            {"-o", "script", "--tool", "crane"},
+		"no rule maps registry.example.com/sidecar:v1@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef to a new name": {"-o", "script", "--tool", "skopeo", "--rule", "docker.io/=localhost:5001/"},
+	}
+	for expected, args := range tests {
+		listCmd := newListCmd()
+		var stdout bytes.Buffer
+		listCmd.SetIn(strings.NewReader(scriptPod))
+		listCmd.SetOut(&stdout)
+		listCmd.SetErr(io.Discard)
+		listCmd.SilenceUsage = true
+		listCmd.SetArgs(append(args, "-"))
+		require.EqualError(t, listCmd.Execute(), expected)
+		require.Empty(t, stdout.String(), expected)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "docker.io/library/nginx:1.25", shellQuote("docker.io/library/nginx:1.25"))
+	require.Equal(t, "'$(reboot)'", shellQuote("$(reboot)"))
+	require.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	require.Equal(t, "''", shellQuote(""))
+}
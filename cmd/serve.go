@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+const (
+	// maxRequestSize limits the size of request bodies the server reads.
+	maxRequestSize = 32 << 20
+	// shutdownTimeout is how long the server waits for requests in flight when it is stopped.
+	shutdownTimeout = 10 * time.Second
+	// readTimeout limits how long reading a request, its body included, may take so slow clients can't hold the server.
+	readTimeout = 30 * time.Second
+	// writeTimeout limits how long handling a request and writing its response may take.
+	writeTimeout = time.Minute
+	// idleTimeout limits how long a keep-alive connection waits for the next request.
+	idleTimeout = 2 * time.Minute
+)
+
+// serverOptions holds the flags of commands that serve HTTP.
+type serverOptions struct {
+	addr    string
+	tlsCert string
+	tlsKey  string
+}
+
+func (o *serverOptions) addFlags(cmd *cobra.Command, defaultAddr string) {
+	cmd.Flags().StringVar(&o.addr, "addr", defaultAddr, "Address to listen on.")
+	cmd.Flags().StringVar(&o.tlsCert, "tls-cert", "", "Path to a PEM certificate to serve HTTPS with, requires --tls-key.")
+	cmd.Flags().StringVar(&o.tlsKey, "tls-key", "", "Path to the PEM private key of --tls-cert.")
+}
+
+// serve serves handler until the command is interrupted or terminated, requests in flight are then given time to finish.
+func (o *serverOptions) serve(cmd *cobra.Command, logger *slog.Logger, handler http.Handler) error {
+	if (o.tlsCert == "") != (o.tlsKey == "") {
+		return errors.New("--tls-cert and --tls-key must be used together")
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	listener, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	server := newHTTPServer(logger, handler)
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Listening", "addr", listener.Addr().String(), "tls", o.tlsCert != "")
+		if o.tlsCert != "" {
+			errs <- server.ServeTLS(listener, o.tlsCert, o.tlsKey)
+			return
+		}
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// newHTTPServer returns a server for handler whose timeouts keep slow or idle clients from holding it.
+func newHTTPServer(logger *slog.Logger, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+}
+
+// extractRequest is the JSON envelope POST /v1/extract accepts, its options override the query parameters.
+type extractRequest struct {
+	// Manifests is a YAML or JSON stream of manifests.
+	Manifests          string `json:"manifests"`
+	UnknownGVKBehavior string `json:"unknownGVKBehavior,omitempty"`
+	Normalize          *bool  `json:"normalize,omitempty"`
+}
+
+// extractResponse is the response of POST /v1/extract.
+type extractResponse struct {
+	Images []extractedImage `json:"images"`
+	// UnknownGVKs are the manifests with an unknown GVK that were skipped or read as free text.
+	UnknownGVKs []images.UnknownGVK `json:"unknownGVKs"`
+}
+
+// extractedImage is an image reference and the objects it was found in.
+type extractedImage struct {
+	// Image is the reference as written in the manifests, or normalized when normalization is requested.
+	Image      string          `json:"image"`
+	Registry   string          `json:"registry,omitempty"`
+	Repository string          `json:"repository,omitempty"`
+	Tag        string          `json:"tag,omitempty"`
+	Digest     string          `json:"digest,omitempty"`
+	Objects    []images.Object `json:"objects"`
+	// Error is set when the reference isn't valid, its parts are then empty.
+	Error string `json:"error,omitempty"`
+}
+
+// errorResponse is the response of requests that failed.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newServeCmd() *cobra.Command {
+	var server serverOptions
+	var serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve image extraction as an HTTP API",
+		Long: `Serve image extraction as an HTTP API.
+
+POST /v1/extract accepts a YAML or JSON stream of manifests and responds with the image references found in them as
+JSON, each split into its parts and listed with the objects using it. The options are query parameters:
+
+  unknownGVKBehavior  fail (the default, responds with 422 Unprocessable Entity), skip or freetext
+  normalize           true to merge references that normalize to the same image, e.g. nginx and docker.io/library/nginx
+
+They can also be sent in a JSON envelope, {"manifests": "...", "unknownGVKBehavior": "skip", "normalize": true}, whose
+options override the query parameters. GET /healthz responds with 200 OK.`,
+		Example: `skim serve --addr :8080
+curl --data-binary @deployment.yaml 'http://localhost:8080/v1/extract?unknownGVKBehavior=skip&normalize=true'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			return server.serve(cmd, logger, newServeHandler(logger))
+		},
+	}
+	server.addFlags(serveCmd, ":8080")
+	return serveCmd
+}
+
+// newServeHandler returns the handler of the HTTP API of skim serve.
+func newServeHandler(logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/extract", func(w http.ResponseWriter, r *http.Request) {
+		status, response := extract(w, r, logger)
+		writeJSON(w, logger, status, response)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// extract handles an extraction request and returns the status and body of the response.
+func extract(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int, any) {
+	// the response writer lets the server close the connection of a request that is too large
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		if _, ok := errors.AsType[*http.MaxBytesError](err); ok {
+			return http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("request body is larger than %d bytes", maxRequestSize)}
+		}
+		return http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to read request body: %s", err)}
+	}
+	query := r.URL.Query()
+	request := extractRequest{Manifests: string(body), UnknownGVKBehavior: query.Get("unknownGVKBehavior")}
+	if s := query.Get("normalize"); s != "" {
+		normalize, err := strconv.ParseBool(s)
+		if err != nil {
+			return http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid value for normalize: %s", s)}
+		}
+		request.Normalize = &normalize
+	}
+	if isExtractEnvelope(body) {
+		var envelope extractRequest
+		err := json.Unmarshal(body, &envelope)
+		if err != nil {
+			return http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to decode request: %s", err)}
+		}
+		request.Manifests = envelope.Manifests
+		request.UnknownGVKBehavior = cmp.Or(envelope.UnknownGVKBehavior, request.UnknownGVKBehavior)
+		request.Normalize = cmp.Or(envelope.Normalize, request.Normalize)
+	}
+	sources := sourceOptions{unknownGVKBehavior: cmp.Or(request.UnknownGVKBehavior, "fail")}
+	extractor, err := sources.newExtractor(logger)
+	if err != nil {
+		return http.StatusBadRequest, errorResponse{Error: err.Error()}
+	}
+	response := extractResponse{Images: []extractedImage{}, UnknownGVKs: []images.UnknownGVK{}}
+	extractor.UnknownGVKFound = func(unknownGVK images.UnknownGVK) {
+		response.UnknownGVKs = append(response.UnknownGVKs, unknownGVK)
+	}
+	occurrences, err := extractor.ExtractOccurrencesFromManifests(r.Context(), strings.NewReader(request.Manifests), "")
+	if err != nil {
+		return http.StatusUnprocessableEntity, errorResponse{Error: err.Error()}
+	}
+	response.Images = extractedImages(occurrences, request.Normalize != nil && *request.Normalize)
+	logger.InfoContext(r.Context(), "Extracted images", "images", len(response.Images), "unknownGVKs", len(response.UnknownGVKs))
+	return http.StatusOK, response
+}
+
+// isExtractEnvelope reports whether a request body is the JSON envelope rather than a JSON manifest, manifests have a kind.
+func isExtractEnvelope(body []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return false
+	}
+	_, manifests := fields["manifests"]
+	_, kind := fields["kind"]
+	return manifests && !kind
+}
+
+// extractedImages groups occurrences by image, by normalized image when normalize is set, sorted by image.
+func extractedImages(occurrences []images.Occurrence, normalize bool) []extractedImage {
+	var extracted []extractedImage
+	indexes := make(map[string]int)
+	for _, occurrence := range occurrences {
+		image := extractedImage{Image: occurrence.Image}
+		ref, err := reference.Parse(occurrence.Image)
+		if err != nil {
+			image.Error = err.Error()
+		} else {
+			image.Registry, image.Repository, image.Tag, image.Digest = ref.Registry, ref.Repository, ref.Tag, ref.Digest
+			if normalize {
+				image.Image = ref.String()
+			}
+		}
+		i, ok := indexes[image.Image]
+		if !ok {
+			i = len(extracted)
+			indexes[image.Image] = i
+			extracted = append(extracted, image)
+		}
+		if !slices.Contains(extracted[i].Objects, occurrence.Object) {
+			extracted[i].Objects = append(extracted[i].Objects, occurrence.Object)
+		}
+	}
+	slices.SortFunc(extracted, func(a, b extractedImage) int {
+		return strings.Compare(a.Image, b.Image)
+	})
+	return append([]extractedImage{}, extracted...)
+}
+
+// writeJSON writes a JSON response with status.
+func writeJSON(w http.ResponseWriter, logger *slog.Logger, status int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Error("Failed to write response", "error", err)
+	}
+}
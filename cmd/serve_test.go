@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// postExtract posts body to the extract endpoint of a test server and returns the status code and the decoded response.
+func postExtract(t *testing.T, server *httptest.Server, query string, body string, response any) int {
+	t.Helper()
+	request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/v1/extract"+query, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := server.Client().Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(response))
+	return resp.StatusCode
+}
+
+func TestServeExtract(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(newServeHandler(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(server.Close)
+	manifests := `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+    - name: app
+      image: nginx:1.25
+    - name: sidecar
+      image: docker.io/library/nginx:1.25
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  image: quay.io/example/widget:v1
+`
+	pod := images.Object{APIVersion: "v1", Kind: "Pod", Name: "app"}
+
+	var response extractResponse
+	require.Equal(t, http.StatusOK, postExtract(t, server, "?unknownGVKBehavior=skip", manifests, &response))
+	require.Equal(t, []extractedImage{
+		{Image: "docker.io/library/nginx:1.25", Registry: "docker.io", Repository: "library/nginx", Tag: "1.25", Objects: []images.Object{pod}},
+		{Image: "nginx:1.25", Registry: "docker.io", Repository: "library/nginx", Tag: "1.25", Objects: []images.Object{pod}},
+	}, response.Images)
+	require.Len(t, response.UnknownGVKs, 1)
+	require.Equal(t, "example.com/v1.Widget", response.UnknownGVKs[0].GVK)
+
+	// the envelope overrides the query parameters
+	envelope, err := json.Marshal(map[string]any{"manifests": manifests, "unknownGVKBehavior": "freetext", "normalize": true})
+	require.NoError(t, err)
+	response = extractResponse{}
+	require.Equal(t, http.StatusOK, postExtract(t, server, "?unknownGVKBehavior=skip", string(envelope), &response))
+	require.Len(t, response.Images, 2)
+	// both references of nginx are merged, free text finds the image of the widget
+	require.Equal(t, "docker.io/library/nginx:1.25", response.Images[0].Image)
+	require.Contains(t, response.Images[0].Objects, pod)
+	require.Equal(t, "quay.io/example/widget:v1", response.Images[1].Image)
+
+	// a JSON manifest isn't mistaken for the envelope
+	response = extractResponse{}
+	require.Equal(t, http.StatusOK, postExtract(t, server, "?normalize=1", `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"app"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`, &response))
+	require.Equal(t, []extractedImage{{Image: "docker.io/library/nginx", Registry: "docker.io", Repository: "library/nginx", Objects: []images.Object{pod}}}, response.Images)
+}
+
+func TestServeExtractErrors(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(newServeHandler(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(server.Close)
+	widget := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: widget\n"
+
+	var response errorResponse
+	require.Equal(t, http.StatusUnprocessableEntity, postExtract(t, server, "", widget, &response))
+	require.Contains(t, response.Error, "example.com/v1.Widget")
+	require.Equal(t, http.StatusBadRequest, postExtract(t, server, "?unknownGVKBehavior=ignore", widget, &response))
+	require.Equal(t, "unknown value for unknown-gvk-behavior: ignore", response.Error)
+	require.Equal(t, http.StatusBadRequest, postExtract(t, server, "?normalize=maybe", widget, &response))
+	require.Equal(t, "invalid value for normalize: maybe", response.Error)
+	require.Equal(t, http.StatusBadRequest, postExtract(t, server, "", `{"manifests": 1}`, &response))
+	require.Contains(t, response.Error, "failed to decode request")
+
+	resp, err := server.Client().Get(server.URL + "/v1/extract")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp, err = server.Client().Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServeCmdErrors(t *testing.T) {
+	t.Parallel()
+	serveCmd := newServeCmd()
+	serveCmd.SetOut(io.Discard)
+	serveCmd.SetErr(io.Discard)
+	serveCmd.SetArgs([]string{"--tls-cert", "tls.crt"})
+	require.EqualError(t, serveCmd.Execute(), "--tls-cert and --tls-key must be used together")
+}
+
+func TestServeExtractTooLarge(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(newServeHandler(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(server.Close)
+	request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/v1/extract", strings.NewReader(strings.Repeat("#", maxRequestSize+1)))
+	require.NoError(t, err)
+	resp, err := server.Client().Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	// the server closes the connection instead of reading the rest of the body
+	require.True(t, resp.Close)
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	t.Parallel()
+	server := newHTTPServer(slog.New(slog.NewTextHandler(io.Discard, nil)), http.NotFoundHandler())
+	require.Positive(t, server.ReadHeaderTimeout)
+	require.Equal(t, readTimeout, server.ReadTimeout)
+	require.Equal(t, writeTimeout, server.WriteTimeout)
+	require.Equal(t, idleTimeout, server.IdleTimeout)
+}
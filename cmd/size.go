@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// sizeResult is the compressed size of an image for every platform it provides.
+type sizeResult struct {
+	Image     string         `json:"image"`
+	Platforms []platformSize `json:"platforms"`
+	// Size is the size of the largest platform.
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+
+	sizes []registry.PlatformSize
+}
+
+// platformSize is the compressed size of the image of a platform.
+type platformSize struct {
+	Platform string `json:"platform"`
+	Digest   string `json:"digest"`
+	Size     int64  `json:"size"`
+}
+
+// workloadSize is the size of the images an object pulls.
+type workloadSize struct {
+	Object string   `json:"object"`
+	Images []string `json:"images"`
+	// Size is the size of the largest platform of each image, layers shared by the images are counted once.
+	Size int64 `json:"size"`
+}
+
+// sizeReport is the output of the size command.
+type sizeReport struct {
+	Images []sizeResult `json:"images"`
+	// Workloads are sorted from the heaviest to the lightest.
+	Workloads []workloadSize `json:"workloads"`
+	// Total is the size of every platform of every image, blobs shared by images and platforms are counted once.
+	Total int64 `json:"total"`
+	// Blobs is the number of distinct blobs counted in the total.
+	Blobs int `json:"blobs"`
+}
+
+func newSizeCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var platformFlags []string
+	var top int
+	var format string
+	var sizeCmd = &cobra.Command{
+		Use:   "size PATH [PATH...]",
+		Short: "Report the compressed sizes of the container images of Kubernetes resources",
+		Long: `Report the compressed sizes of the container images of Kubernetes resources.
+
+The size of an image is the size of its config and layers as they are pulled, read from the manifests in its registry
+for every platform it provides, or only the platforms chosen with --platform.
+
+The total is what the whole set weighs, e.g. in a bundle written by skim save, with blobs shared by images counted once.
+The size of a workload is what a node pulls to start it from scratch: the largest platform of each of its images, with
+layers shared by its images counted once. The heaviest workloads are listed first.`,
+		Example: `skim size path/to/manifests/
+skim size --platform linux/amd64 --top 10 path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			platforms := make([]registry.Platform, 0, len(platformFlags))
+			for _, s := range platformFlags {
+				platform, err := registry.ParsePlatform(s)
+				if err != nil {
+					return err
+				}
+				platforms = append(platforms, platform)
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			occurrences, err := sources.extractOccurrences(cmd, extractor, args)
+			if err != nil {
+				return err
+			}
+			objects := imageObjects(occurrences)
+			logger.Info("Looking up image sizes", "images", len(objects))
+			results := concurrently(slices.Sorted(maps.Keys(objects)), registries.concurrency, func(image string) sizeResult {
+				return imageSize(cmd.Context(), client, image, platforms)
+			})
+			report := newSizeReport(occurrences, results)
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeSizeText(cmd.OutOrStdout(), report, top)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(report)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to look up the sizes of %d of %d images", failed, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(sizeCmd)
+	registries.addFlags(sizeCmd)
+	registries.addConcurrencyFlag(sizeCmd)
+	sizeCmd.Flags().StringArrayVar(&platformFlags, "platform", nil, "Platform to report, in the form os/architecture[/variant]. Can be repeated, every platform is reported when there are none.")
+	sizeCmd.Flags().IntVar(&top, "top", 5, "Number of the heaviest workloads to list in text output, 0 lists every workload.")
+	sizeCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json).")
+	return sizeCmd
+}
+
+// imageSize looks up the sizes of an image for the platforms, every platform when there are none.
+func imageSize(ctx context.Context, client *registry.Client, image string, platforms []registry.Platform) sizeResult {
+	result := sizeResult{Image: image, Platforms: []platformSize{}}
+	ref, err := reference.Parse(image)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	sizes, err := client.Sizes(ctx, ref, platforms)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, size := range sizes {
+		result.sizes = append(result.sizes, size)
+		result.Platforms = append(result.Platforms, platformSize{Platform: size.Platform.String(), Digest: size.Digest, Size: size.Size})
+		result.Size = max(result.Size, size.Size)
+	}
+	if len(result.sizes) == 0 {
+		result.Error = "the image provides none of the platforms"
+	}
+	return result
+}
+
+// newSizeReport sums the sizes of the images in total and for every object using them.
+func newSizeReport(occurrences []images.Occurrence, results []sizeResult) sizeReport {
+	report := sizeReport{Images: results, Workloads: []workloadSize{}}
+	largest := make(map[string][]registry.Descriptor)
+	total := make(map[string]int64)
+	for _, result := range results {
+		var blobs []registry.Descriptor
+		for _, size := range result.sizes {
+			for _, blob := range size.Blobs {
+				total[blob.Digest] = blob.Size
+			}
+			if size.Size == result.Size && blobs == nil {
+				blobs = size.Blobs
+			}
+		}
+		largest[result.Image] = blobs
+	}
+	for _, size := range total {
+		report.Total += size
+	}
+	report.Blobs = len(total)
+
+	var workloads []workloadSize
+	var workloadBlobs []map[string]int64
+	indexes := make(map[string]int)
+	for _, occurrence := range occurrences {
+		object := occurrence.Object.String()
+		i, ok := indexes[object]
+		if !ok {
+			i = len(workloads)
+			indexes[object] = i
+			workloads = append(workloads, workloadSize{Object: object})
+			workloadBlobs = append(workloadBlobs, make(map[string]int64))
+		}
+		if slices.Contains(workloads[i].Images, occurrence.Image) {
+			continue
+		}
+		workloads[i].Images = append(workloads[i].Images, occurrence.Image)
+		for _, blob := range largest[occurrence.Image] {
+			workloadBlobs[i][blob.Digest] = blob.Size
+		}
+	}
+	for i := range workloads {
+		for _, size := range workloadBlobs[i] {
+			workloads[i].Size += size
+		}
+	}
+	slices.SortStableFunc(workloads, func(a, b workloadSize) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Object, b.Object))
+	})
+	report.Workloads = append(report.Workloads, workloads...)
+	return report
+}
+
+// writeSizeText writes the size of every image, e.g. "nginx:1.25: 67.3 MB (linux/amd64 67.3 MB, linux/arm64/v8 65.1 MB)",
+// the top heaviest workloads and the total.
+func writeSizeText(w io.Writer, report sizeReport, top int) error {
+	var sb strings.Builder
+	for _, result := range report.Images {
+		if result.Error != "" {
+			fmt.Fprintf(&sb, "%s: error: %s\n", result.Image, result.Error)
+			continue
+		}
+		platforms := make([]string, 0, len(result.Platforms))
+		for _, platform := range result.Platforms {
+			platforms = append(platforms, platform.Platform+" "+formatSize(platform.Size))
+		}
+		fmt.Fprintf(&sb, "%s: %s (%s)\n", result.Image, formatSize(result.Size), strings.Join(platforms, ", "))
+	}
+	workloads := report.Workloads
+	if top > 0 && len(workloads) > top {
+		workloads = workloads[:top]
+	}
+	if len(workloads) > 0 {
+		sb.WriteString("\nHeaviest workloads:\n")
+		for _, workload := range workloads {
+			fmt.Fprintf(&sb, "%s: %s (%s)\n", workload.Object, formatSize(workload.Size), strings.Join(workload.Images, ", "))
+		}
+	}
+	fmt.Fprintf(&sb, "\nTotal: %s in %d blobs\n", formatSize(report.Total), report.Blobs)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// formatSize formats a size in bytes with decimal units like docker does, e.g. 67.3 MB.
+func formatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	var prefix string
+	for _, prefix = range []string{"k", "M", "G", "T", "P"} {
+		value /= unit
+		if value < unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %sB", value, prefix)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+// registryImageSize is the size of an image pushed by [registrytest.Registry.PushImage].
+func registryImageSize(t *testing.T, image string, platform registry.Platform) int64 {
+	t.Helper()
+	config, err := json.Marshal(platform)
+	require.NoError(t, err)
+	return int64(len(config) + len(registrytest.BaseLayer) + len(image+" "+platform.String()+" layer"))
+}
+
+func TestSizeCmd(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.PushImage("app", "v1", linuxAMD64, linuxARM64)
+	sidecar := r.PushImage("sidecar", "v1")
+	path := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/sidecar:v1")+"---\n"+
+		imagesFixture(t, "images_pod.yaml", r.Host()+"/sidecar:v1", r.Host()+"/sidecar:v1")), 0o600))
+
+	sizeCmd := newSizeCmd()
+	var stdout bytes.Buffer
+	sizeCmd.SetOut(&stdout)
+	sizeCmd.SetErr(io.Discard)
+	sizeCmd.SetArgs([]string{"--plain-http", "-o", "json", path})
+	require.NoError(t, sizeCmd.Execute())
+	var report sizeReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	amd64, arm64 := registryImageSize(t, "app:v1", linuxAMD64), registryImageSize(t, "app:v1", linuxARM64)
+	sidecarSize := registryImageSize(t, "sidecar:v1", linuxAMD64)
+	require.Len(t, report.Images, 2)
+	require.Equal(t, r.Host()+"/app:v1", report.Images[0].Image)
+	require.Greater(t, arm64, amd64)
+	require.Equal(t, arm64, report.Images[0].Size)
+	require.Len(t, report.Images[0].Platforms, 2)
+	require.Equal(t, platformSize{Platform: "linux/amd64", Digest: report.Images[0].Platforms[0].Digest, Size: amd64}, report.Images[0].Platforms[0])
+	require.Equal(t, []platformSize{{Platform: "linux/amd64", Digest: sidecar, Size: sidecarSize}}, report.Images[1].Platforms)
+	// the base layer is shared by every image and counted once, the app and sidecar configs of linux/amd64 are the same blob
+	base := int64(len(registrytest.BaseLayer))
+	config := sidecarSize - base - int64(len("sidecar:v1 linux/amd64 layer"))
+	require.Equal(t, amd64+arm64-base+sidecarSize-base-config, report.Total)
+	require.Equal(t, 6, report.Blobs)
+	require.Equal(t, []workloadSize{
+		// the largest platform of the app image is linux/arm64, only the base layer is shared with the sidecar
+		{Object: "Deployment/production/web", Images: []string{r.Host() + "/app:v1", r.Host() + "/sidecar:v1"}, Size: arm64 + sidecarSize - base},
+		{Object: "Pod/app", Images: []string{r.Host() + "/sidecar:v1"}, Size: sidecarSize},
+	}, report.Workloads)
+
+	sizeCmd = newSizeCmd()
+	stdout.Reset()
+	sizeCmd.SetOut(&stdout)
+	sizeCmd.SetErr(io.Discard)
+	sizeCmd.SetArgs([]string{"--plain-http", "--platform", "linux/arm64", "--top", "1", path})
+	sizeCmd.SilenceUsage = true
+	require.EqualError(t, sizeCmd.Execute(), "failed to look up the sizes of 1 of 2 images")
+	require.Equal(t, r.Host()+"/app:v1: "+formatSize(arm64)+" (linux/arm64/v8 "+formatSize(arm64)+")\n"+
+		r.Host()+"/sidecar:v1: error: the image provides none of the platforms\n"+
+		"\nHeaviest workloads:\n"+
+		"Deployment/production/web: "+formatSize(arm64)+" ("+r.Host()+"/app:v1, "+r.Host()+"/sidecar:v1)\n"+
+		"\nTotal: "+formatSize(arm64)+" in 3 blobs\n", stdout.String())
+}
+
+func TestFormatSize(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "0 B", formatSize(0))
+	require.Equal(t, "999 B", formatSize(999))
+	require.Equal(t, "1.0 kB", formatSize(1000))
+	require.Equal(t, "67.3 MB", formatSize(67_300_000))
+	require.Equal(t, "1.5 GB", formatSize(1_500_000_000))
+}
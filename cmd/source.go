@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// sourceOptions holds the flags that control where manifests are read from and how they are parsed.
+type sourceOptions struct {
+	unknownGVKBehavior string
+	gitRef             string
+	gitRepo            string
+}
+
+func (o *sourceOptions) addFlags(cmd *cobra.Command) {
+	o.addUnknownGVKBehaviorFlag(cmd, "fail")
+	cmd.Flags().StringVar(&o.gitRef, "git-ref", "", "Read manifests from this git revision (branch, tag or commit) instead of the file system, PATHs are then paths in the revision's tree.")
+	o.addGitRepoFlag(cmd, "Path to the git repository (can be bare) used with --git-ref.")
+}
+
+func (o *sourceOptions) addUnknownGVKBehaviorFlag(cmd *cobra.Command, defaultBehavior string) {
+	cmd.Flags().StringVarP(&o.unknownGVKBehavior, "unknown-gvk-behavior", "u", defaultBehavior, fmt.Sprintf("Behavior when encountering unknown Group-Version-Kind (options: fail, skip, freetext). Defaults to %s.", defaultBehavior))
+}
+
+func (o *sourceOptions) addGitRepoFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringVar(&o.gitRepo, "git-repo", ".", usage)
+}
+
+// validateArgs requires at least one PATH unless manifests are read from git where no PATH means the entire tree.
+func (o *sourceOptions) validateArgs(cmd *cobra.Command, args []string) error {
+	if o.gitRef != "" {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
+// newExtractor creates an extractor configured by the flags.
+func (o *sourceOptions) newExtractor(logger *slog.Logger) (*images.Extractor, error) {
+	extractor := &images.Extractor{
+		Logger: logger,
+	}
+	switch strings.ToLower(o.unknownGVKBehavior) {
+	case "fail":
+		extractor.UnknownGVKBehavior = images.UnknownGVKFail
+	case "skip":
+		extractor.UnknownGVKBehavior = images.UnknownGVKSkip
+	case "freetext":
+		extractor.UnknownGVKBehavior = images.UnknownGVKFreeText
+	default:
+		return nil, fmt.Errorf("unknown value for unknown-gvk-behavior: %s", o.unknownGVKBehavior)
+	}
+	return extractor, nil
+}
+
+// extract extracts image references from the manifests the arguments point to placing them in the images map as keys.
+func (o *sourceOptions) extract(cmd *cobra.Command, extractor *images.Extractor, args []string, imagesOutput map[string]struct{}) error {
+	return o.walk(cmd, extractor.Logger, args, func(source string, r io.Reader) error {
+		err := extractor.ExtractFromManifests(cmd.Context(), r, imagesOutput)
+		if err != nil {
+			return fmt.Errorf("failed to extract images from %s: %w", source, err)
+		}
+		return nil
+	})
+}
+
+// extractOccurrences extracts image references along with the objects they were found in from the manifests the arguments point to.
+func (o *sourceOptions) extractOccurrences(cmd *cobra.Command, extractor *images.Extractor, args []string) ([]images.Occurrence, error) {
+	var occurrences []images.Occurrence
+	err := o.walk(cmd, extractor.Logger, args, func(source string, r io.Reader) error {
+		manifestOccurrences, err := extractor.ExtractOccurrencesFromManifests(cmd.Context(), r, source)
+		if err != nil {
+			return fmt.Errorf("failed to extract images from %s: %w", source, err)
+		}
+		occurrences = append(occurrences, manifestOccurrences...)
+		return nil
+	})
+	return occurrences, err
+}
+
+// walk calls fn for every manifest file the arguments point to.
+func (o *sourceOptions) walk(cmd *cobra.Command, logger *slog.Logger, args []string, fn images.ManifestFunc) error {
+	walkFn := func(source string, r io.Reader) error {
+		logger.Info("Processing file", "path", source)
+		return fn(source, r)
+	}
+	if o.gitRef != "" {
+		// Process the arguments as paths in the git revision's tree
+		logger.Info("Processing git ref", "repo", o.gitRepo, "ref", o.gitRef)
+		return walkGit(cmd.Context(), o.gitRepo, o.gitRef, args, walkFn)
+	}
+	// Process each argument - can be files, directories, archives or stdin (-)
+	for _, arg := range args {
+		if arg == "-" {
+			// Process stdin
+			logger.Info("Processing stdin")
+			err := fn("stdin", cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := walkLocalFiles(arg, func(fsys fs.FS, path string, source string) error {
+			file, err := fsys.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s: %w", source, err)
+			}
+			defer file.Close()
+			return images.WalkFile(file, source, walkFn)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %w", arg, err)
+		}
+	}
+	return nil
+}
+
+// walkLocalFiles calls fn for every regular file a path on the local file system matches, walking directories
+// recursively like [images.WalkFS]. Every command reading local files finds them with it, so they all see the same files.
+// fn gets the file system the file is in, its path there and its path on the local file system, see localSource.
+func walkLocalFiles(arg string, fn func(fsys fs.FS, path string, source string) error) error {
+	fsys, root, pattern, err := pathToFS(arg)
+	if err != nil {
+		return err
+	}
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to match pattern %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("failed to match pattern %s: %w", pattern, fs.ErrNotExist)
+	}
+	for _, match := range matches {
+		err := fs.WalkDir(fsys, match, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			return fn(fsys, path, localSource(arg, root, path))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to walk path %s: %w", match, err)
+		}
+	}
+	return nil
+}
+
+// globEscaper escapes the characters [path.Match] treats as special.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+// pathToFS converts a path on the local file system into a file system rooted at its parent directory, the directory and a pattern matching it.
+func pathToFS(path string) (fs.FS, string, string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to get absolute path of %s: %w", path, err)
+	}
+	dir, base := filepath.Split(absPath)
+	if base == "" {
+		// the path is a root directory
+		return os.DirFS(dir), dir, ".", nil
+	}
+	return os.DirFS(dir), dir, globEscaper.Replace(base), nil
+}
+
+// localSource converts the source of a file found in the file system rooted at root into a path on the local file system,
+// relative to the working directory unless arg is absolute, so that locations point at the files the user passed.
+func localSource(arg string, root string, source string) string {
+	path := filepath.Join(root, filepath.FromSlash(source))
+	if filepath.IsAbs(arg) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	relative, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path
+	}
+	return relative
+}
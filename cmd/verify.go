@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// Statuses of verified images, the order is the order problems are reported in.
+const (
+	verifyOK           = "ok"
+	verifyMissing      = "missing"
+	verifyUnauthorized = "unauthorized"
+	verifyUnreachable  = "unreachable"
+	verifyInvalid      = "invalid"
+	verifyFailed       = "failed"
+)
+
+var verifyProblems = []string{verifyMissing, verifyUnauthorized, verifyUnreachable, verifyInvalid, verifyFailed}
+
+// verifyResult is the outcome of verifying that an image exists.
+type verifyResult struct {
+	Image string `json:"image"`
+	// Checked is the reference that was looked up when rewrite rules map the image to a mirror.
+	Checked string `json:"checked,omitempty"`
+	Status  string `json:"status"`
+	Digest  string `json:"digest,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// verifyFunc looks up the manifest of a reference and returns its digest.
+type verifyFunc func(ctx context.Context, ref reference.Reference) (string, error)
+
+func newVerifyCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var rewrites rewriteOptions
+	var format string
+	var against string
+	var verifyCmd = &cobra.Command{
+		Use:   "verify PATH [PATH...]",
+		Short: "Verify that the container images of Kubernetes resources exist in their registries",
+		Long: `Verify that the container images of Kubernetes resources exist in their registries.
+
+The manifest of every image is looked up with a HEAD request, in a mirror when rewrite rules map the image to one.
+Missing images, images the credentials don't give access to, unreachable registries and invalid references are
+reported separately and fail the command.
+
+With --against, images are looked up offline in a bundle instead, an OCI image layout written by skim save or another
+tool, as a directory or a tarball, or a docker archive written by docker save. Images pinned to a digest are matched by
+the digest of a manifest of the bundle, others by their tag. Docker archives only have tags.`,
+		Example: `skim verify path/to/manifests/
+skim verify --rule 'docker.io/=mirror.example.com/dockerhub/' path/to/manifests/
+skim verify --against bundle.tar path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			config, err := rewrites.load()
+			if err != nil {
+				return err
+			}
+			var verify verifyFunc
+			if against != "" {
+				contents, err := readBundle(against)
+				if err != nil {
+					return err
+				}
+				verify = contents.verify
+			} else {
+				client, err := registries.newClient()
+				if err != nil {
+					return err
+				}
+				verify = func(ctx context.Context, ref reference.Reference) (string, error) {
+					descriptor, err := client.Head(ctx, ref)
+					return descriptor.Digest, err
+				}
+			}
+			imagesOutput := make(map[string]struct{})
+			err = sources.extract(cmd, extractor, args, imagesOutput)
+			if err != nil {
+				return err
+			}
+			fn := config.rewriteFunc()
+			results := concurrently(slices.Sorted(maps.Keys(imagesOutput)), registries.concurrency, func(image string) verifyResult {
+				return verifyImage(cmd.Context(), image, fn, verify)
+			})
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeVerifyText(cmd.OutOrStdout(), results)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Status != verifyOK {
+					failed++
+				}
+			}
+			logger.Info("Verified images", "images", len(results), "failed", failed)
+			if failed > 0 {
+				return fmt.Errorf("%d of %d images failed verification", failed, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(verifyCmd)
+	registries.addFlags(verifyCmd)
+	registries.addConcurrencyFlag(verifyCmd)
+	rewrites.addFlags(verifyCmd)
+	verifyCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json). text only lists the images that failed verification.")
+	verifyCmd.Flags().StringVar(&against, "against", "", "Look up images in an OCI image layout or docker archive instead of their registries.")
+	return verifyCmd
+}
+
+// bundleContents are the images of a bundle that images are verified against.
+type bundleContents struct {
+	// tags are the digests of the images of the bundle by their name and tag, e.g. docker.io/library/nginx:1.25. The
+	// digests of images of docker archives are empty.
+	tags map[string]string
+	// digests are the digests of the manifests of the bundle, including the manifests of indexes it has.
+	digests map[string]bool
+}
+
+// readBundle reads the names and digests of the images of an OCI image layout or a docker archive.
+func readBundle(name string) (bundleContents, error) {
+	layout, err := ocilayout.OpenArchive(name)
+	if err != nil {
+		return bundleContents{}, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer layout.Close()
+	contents := bundleContents{tags: make(map[string]string), digests: make(map[string]bool)}
+	for _, descriptor := range layout.Index.Manifests {
+		contents.digests[descriptor.Digest] = true
+		// the OCI annotation may be only a tag when the layout wasn't written by skim, the image is then matched by digest
+		if ref, err := reference.Parse(cmp.Or(descriptor.Annotations[ocilayout.AnnotationImageName], descriptor.Annotations[ocilayout.AnnotationRefName])); err == nil {
+			if tag, ok := bundleTag(ref); ok {
+				contents.tags[tag] = descriptor.Digest
+			}
+		}
+		content, err := layout.ReadBlob(descriptor.Digest, maxManifestSize)
+		if err != nil {
+			return bundleContents{}, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		manifest, err := registry.ParseManifest(descriptor, content)
+		if err != nil {
+			return bundleContents{}, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		for _, child := range manifest.Manifests {
+			if layout.HasBlob(child.Digest) {
+				contents.digests[child.Digest] = true
+			}
+		}
+	}
+	for _, image := range layout.DockerImages {
+		for _, repoTag := range image.RepoTags {
+			ref, err := reference.Parse(repoTag)
+			if err != nil {
+				continue
+			}
+			if tag, ok := bundleTag(ref); ok {
+				if _, found := contents.tags[tag]; !found {
+					contents.tags[tag] = ""
+				}
+			}
+		}
+	}
+	return contents, nil
+}
+
+// bundleTag returns the name and tag an image is matched by, latest when it has neither a tag nor a digest.
+func bundleTag(ref reference.Reference) (string, bool) {
+	if ref.Tag == "" && ref.Digest != "" {
+		return "", false
+	}
+	return ref.Name() + ":" + cmp.Or(ref.Tag, "latest"), true
+}
+
+// verify looks up an image in the bundle by its digest, or its tag when it isn't pinned to one.
+func (b bundleContents) verify(_ context.Context, ref reference.Reference) (string, error) {
+	if ref.Digest != "" {
+		if !b.digests[ref.Digest] {
+			return "", fmt.Errorf("no manifest of the bundle has digest %s: %w", ref.Digest, registry.ErrNotFound)
+		}
+		return ref.Digest, nil
+	}
+	tag, _ := bundleTag(ref)
+	digest, ok := b.tags[tag]
+	if !ok {
+		return "", fmt.Errorf("no image of the bundle is named %s: %w", tag, registry.ErrNotFound)
+	}
+	return digest, nil
+}
+
+// verifyImage looks up an image after mapping it with fn.
+func verifyImage(ctx context.Context, image string, fn images.RewriteFunc, verify verifyFunc) verifyResult {
+	result := verifyResult{Image: image}
+	checked, err := fn(images.Occurrence{Image: image})
+	if err != nil {
+		result.Status, result.Error = verifyFailed, err.Error()
+		return result
+	}
+	if checked != image {
+		result.Checked = checked
+	}
+	ref, err := reference.Parse(checked)
+	if err != nil {
+		result.Status, result.Error = verifyInvalid, err.Error()
+		return result
+	}
+	result.Digest, err = verify(ctx, ref)
+	switch {
+	case err == nil:
+		result.Status = verifyOK
+	case errors.Is(err, registry.ErrNotFound):
+		result.Status = verifyMissing
+	case errors.Is(err, registry.ErrUnauthorized):
+		result.Status = verifyUnauthorized
+	case errors.Is(err, registry.ErrUnreachable):
+		result.Status = verifyUnreachable
+	default:
+		result.Status = verifyFailed
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// writeVerifyText writes the images that failed verification grouped by the reason, e.g. "missing: nginx:1.99".
+func writeVerifyText(w io.Writer, results []verifyResult) error {
+	var sb strings.Builder
+	for _, status := range verifyProblems {
+		for _, result := range results {
+			if result.Status != status {
+				continue
+			}
+			fmt.Fprintf(&sb, "%s: %s", status, result.Image)
+			if result.Checked != "" {
+				fmt.Fprintf(&sb, " (checked %s)", result.Checked)
+			}
+			// the reason is clear for missing and unauthorized images, the others need the error to be acted upon
+			if status != verifyMissing && status != verifyUnauthorized {
+				fmt.Fprintf(&sb, ": %s", result.Error)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/ocilayout"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func TestVerifyCmd(t *testing.T) {
+	t.Parallel()
+	public := registrytest.New(t)
+	digest := public.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	private := registrytest.New(t)
+	private.RequireCredentials("user", "secret")
+	private.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pods.yaml")
+	content := imagesFixture(t, "images_pod.yaml", public.Host()+"/app:v1", public.Host()+"/app:v2") + "---\n" +
+		imagesFixture(t, "images_pod.yaml", private.Host()+"/app:v1", "127.0.0.1:1/app:v1")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	verifyCmd := newVerifyCmd()
+	verifyCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	verifyCmd.SetOut(&stdout)
+	verifyCmd.SetErr(io.Discard)
+	verifyCmd.SetArgs([]string{"--plain-http", "--retries", "0", "--docker-config", filepath.Join(dir, "config.json"), path})
+	require.EqualError(t, verifyCmd.Execute(), "3 of 4 images failed verification")
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, "missing: "+public.Host()+"/app:v2", lines[0])
+	require.Equal(t, "unauthorized: "+private.Host()+"/app:v1", lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "unreachable: 127.0.0.1:1/app:v1: failed to reach 127.0.0.1:1"), lines[2])
+
+	// the missing image is found in the mirror the rules map it to
+	public.PushManifest("mirror/app", "v2", registry.MediaTypeOCIManifest, []byte(pinManifest))
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_pod.yaml", public.Host()+"/app:v1", public.Host()+"/app:v2")), 0o600))
+	verifyCmd = newVerifyCmd()
+	stdout.Reset()
+	verifyCmd.SetOut(&stdout)
+	verifyCmd.SetErr(io.Discard)
+	verifyCmd.SetArgs([]string{"--plain-http", "-o", "json", "--rule", public.Host() + "/app:v2=" + public.Host() + "/mirror/app:v2", path})
+	require.NoError(t, verifyCmd.Execute())
+	var results []verifyResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Equal(t, []verifyResult{
+		{Image: public.Host() + "/app:v1", Status: verifyOK, Digest: digest},
+		{Image: public.Host() + "/app:v2", Checked: public.Host() + "/mirror/app:v2", Status: verifyOK, Digest: digest},
+	}, results)
+}
+
+func TestVerifyCmdAgainst(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	bundle, index := saveBundle(t, r, "--platform", "linux/arm64")
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(bundle), "deployment.yaml"))
+	require.NoError(t, err)
+	layout, err := ocilayout.Open(bundle)
+	require.NoError(t, err)
+	indexContent, err := layout.ReadBlob(index, maxManifestSize)
+	require.NoError(t, err)
+	require.NoError(t, layout.Close())
+	manifest, err := registry.ParseManifest(registry.Descriptor{Digest: index}, indexContent)
+	require.NoError(t, err)
+	amd64, arm64 := manifest.Manifests[0].Digest, manifest.Manifests[1].Digest
+	path := filepath.Join(t.TempDir(), "pods.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(string(content)+"---\n"+
+		imagesFixture(t, "images_pod.yaml", r.Host()+"/app@"+index, r.Host()+"/app:v2@"+arm64)+"---\n"+
+		imagesFixture(t, "images_pod.yaml", r.Host()+"/app:v2", r.Host()+"/app@"+amd64)), 0o600))
+	// the bundle is enough, the registry isn't used
+	r.Fail(100)
+
+	verifyCmd := newVerifyCmd()
+	verifyCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	verifyCmd.SetOut(&stdout)
+	verifyCmd.SetErr(io.Discard)
+	verifyCmd.SetArgs([]string{"--against", bundle, "-o", "json", path})
+	require.EqualError(t, verifyCmd.Execute(), "2 of 6 images failed verification")
+	var results []verifyResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.ElementsMatch(t, []verifyResult{
+		{Image: r.Host() + "/app:v1", Status: verifyOK, Digest: index},
+		{Image: r.Host() + "/app:v2", Status: verifyMissing, Error: "no image of the bundle is named " + r.Host() + "/app:v2: not found"},
+		// the manifest of the platform that wasn't saved is missing
+		{Image: r.Host() + "/app@" + amd64, Status: verifyMissing, Error: "no manifest of the bundle has digest " + amd64 + ": not found"},
+		{Image: r.Host() + "/app@" + index, Status: verifyOK, Digest: index},
+		{Image: r.Host() + "/app:v2@" + arm64, Status: verifyOK, Digest: arm64},
+		{Image: r.Host() + "/sidecar:v1", Status: verifyOK, Digest: results[len(results)-1].Digest},
+	}, results)
+}
+
+func TestVerifyCmdAgainstDockerArchive(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	manifest := []byte(`[{"Config":"config.json","RepoTags":["nginx:1.25","quay.io/example/app:v1"],"Layers":["layer.tar"]}]`)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0o644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(manifest)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	archive := filepath.Join(dir, "archive.tar")
+	require.NoError(t, os.WriteFile(archive, tarball.Bytes(), 0o600))
+	path := filepath.Join(dir, "pods.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_pod.yaml", "docker.io/library/nginx:1.25", "quay.io/example/app")), 0o600))
+
+	verifyCmd := newVerifyCmd()
+	verifyCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	verifyCmd.SetOut(&stdout)
+	verifyCmd.SetErr(io.Discard)
+	verifyCmd.SetArgs([]string{"--against", archive, path})
+	require.EqualError(t, verifyCmd.Execute(), "1 of 2 images failed verification")
+	require.Equal(t, "missing: quay.io/example/app\n", stdout.String())
+
+	verifyCmd = newVerifyCmd()
+	verifyCmd.SetOut(io.Discard)
+	verifyCmd.SetErr(io.Discard)
+	verifyCmd.SetArgs([]string{"--against", dir, path})
+	require.ErrorContains(t, verifyCmd.Execute(), "failed to open bundle: "+dir+" isn't an OCI image layout or a docker archive")
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/cosign"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Statuses of images whose signatures were verified, the order is the order problems are reported in.
+const (
+	signatureVerified = "verified"
+	signatureUnsigned = "unsigned"
+	signatureInvalid  = "invalid"
+	signatureFailed   = "failed"
+)
+
+var signatureProblems = []string{signatureUnsigned, signatureInvalid, signatureFailed}
+
+// signatureResult is the outcome of verifying the signatures of an image.
+type signatureResult struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	Status string `json:"status"`
+	// Objects are the objects using the image, e.g. Deployment/production/web.
+	Objects []string `json:"objects"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// signatureOptions holds the flags that configure how signatures are verified.
+type signatureOptions struct {
+	key              string
+	identity         string
+	identityRegexp   string
+	issuer           string
+	issuerRegexp     string
+	certificateRoots string
+	rekorKey         string
+}
+
+func (o *signatureOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.key, "key", "", "Path to the PEM encoded public key signatures must be made with, e.g. cosign.pub.")
+	cmd.Flags().StringVar(&o.identity, "certificate-identity", "", "Email or URI the certificate of keyless signatures must have.")
+	cmd.Flags().StringVar(&o.identityRegexp, "certificate-identity-regexp", "", "Regular expression an email or URI of the certificate of keyless signatures must match.")
+	cmd.Flags().StringVar(&o.issuer, "certificate-oidc-issuer", "", "OIDC issuer the certificate of keyless signatures must have been issued for, e.g. https://token.actions.githubusercontent.com.")
+	cmd.Flags().StringVar(&o.issuerRegexp, "certificate-oidc-issuer-regexp", "", "Regular expression the OIDC issuer of the certificate of keyless signatures must match.")
+	cmd.Flags().StringVar(&o.certificateRoots, "certificate-roots", "", "Path to the PEM encoded root certificates keyless certificates must chain to, e.g. the Fulcio root and intermediate.")
+	cmd.Flags().StringVar(&o.rekorKey, "rekor-key", "", "Path to the PEM encoded public key of the Rekor transparency log keyless signatures must be logged in.")
+}
+
+// verifier creates the verifier the flags configure, a key verifier with --key and a keyless verifier otherwise.
+func (o *signatureOptions) verifier() (cosign.Verifier, error) {
+	keyless := o.identity != "" || o.identityRegexp != "" || o.issuer != "" || o.issuerRegexp != "" || o.certificateRoots != "" || o.rekorKey != ""
+	if o.key != "" {
+		if keyless {
+			return nil, errors.New("--key can't be used with the keyless verification flags")
+		}
+		content, err := os.ReadFile(o.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		key, err := cosign.LoadPublicKey(content)
+		if err != nil {
+			return nil, err
+		}
+		return cosign.KeyVerifier{Key: key}, nil
+	}
+	switch {
+	case !keyless:
+		return nil, errors.New("either --key or the keyless verification flags are required")
+	case o.identity == "" && o.identityRegexp == "":
+		return nil, errors.New("keyless verification requires --certificate-identity or --certificate-identity-regexp")
+	case o.issuer == "" && o.issuerRegexp == "":
+		return nil, errors.New("keyless verification requires --certificate-oidc-issuer or --certificate-oidc-issuer-regexp")
+	case o.certificateRoots == "" || o.rekorKey == "":
+		return nil, errors.New("keyless verification requires --certificate-roots and --rekor-key")
+	}
+	verifier := cosign.KeylessVerifier{Identity: o.identity, Issuer: o.issuer}
+	var err error
+	if o.identityRegexp != "" {
+		verifier.IdentityRegexp, err = regexp.Compile(o.identityRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate identity regexp: %w", err)
+		}
+	}
+	if o.issuerRegexp != "" {
+		verifier.IssuerRegexp, err = regexp.Compile(o.issuerRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate OIDC issuer regexp: %w", err)
+		}
+	}
+	content, err := os.ReadFile(o.certificateRoots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate roots: %w", err)
+	}
+	verifier.Roots, err = cosign.LoadCertificates(content)
+	if err != nil {
+		return nil, err
+	}
+	content, err = os.ReadFile(o.rekorKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Rekor key: %w", err)
+	}
+	verifier.RekorKey, err = cosign.LoadPublicKey(content)
+	if err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+func newVerifySignaturesCmd() *cobra.Command {
+	var sources sourceOptions
+	var registries registryOptions
+	var signatures signatureOptions
+	var format string
+	var verifySignaturesCmd = &cobra.Command{
+		Use:   "verify-signatures PATH [PATH...]",
+		Short: "Verify the cosign signatures of the container images of Kubernetes resources",
+		Long: `Verify the cosign signatures of the container images of Kubernetes resources.
+
+The tag of every image is resolved to its digest and the signatures of the digest are looked up in the repository of
+the image, both with the cosign tag scheme (sha256-<hex>.sig) and with the OCI referrers API. An image passes when one
+of its signatures verifies and signs its digest.
+
+Signatures are verified with a public key (--key) or, for keyless signatures, against certificate identity and OIDC
+issuer constraints. Keyless certificates must chain to --certificate-roots at the time the signature was logged in the
+Rekor transparency log, whose bundle is verified with --rekor-key. Both can be downloaded from the Sigstore TUF
+repository, e.g. with cosign initialize.
+
+Unsigned images and images whose signatures don't verify are reported with the objects using them and fail the command.`,
+		Example: `skim verify-signatures --key cosign.pub path/to/manifests/
+skim verify-signatures \
+  --certificate-identity-regexp '^https://github.com/example/' \
+  --certificate-oidc-issuer https://token.actions.githubusercontent.com \
+  --certificate-roots fulcio.crt.pem --rekor-key rekor.pub \
+  path/to/manifests/`,
+		Args: sources.validateArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			if err := registries.validateConcurrency(); err != nil {
+				return err
+			}
+			verifier, err := signatures.verifier()
+			if err != nil {
+				return err
+			}
+			extractor, err := sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			client, err := registries.newClient()
+			if err != nil {
+				return err
+			}
+			occurrences, err := sources.extractOccurrences(cmd, extractor, args)
+			if err != nil {
+				return err
+			}
+			objects := imageObjects(occurrences)
+			results := concurrently(slices.Sorted(maps.Keys(objects)), registries.concurrency, func(image string) signatureResult {
+				result := signatureResult{Image: image, Objects: objects[image]}
+				ref, err := reference.Parse(image)
+				if err != nil {
+					result.Status, result.Error = signatureFailed, err.Error()
+					return result
+				}
+				result.Digest = ref.Digest
+				if result.Digest == "" {
+					descriptor, err := client.Head(cmd.Context(), ref)
+					if err != nil {
+						result.Status, result.Error = signatureFailed, fmt.Sprintf("failed to resolve digest: %s", err)
+						return result
+					}
+					result.Digest = descriptor.Digest
+				}
+				found, err := cosign.Fetch(cmd.Context(), client, ref, result.Digest)
+				if err != nil {
+					result.Status, result.Error = signatureFailed, err.Error()
+					return result
+				}
+				err = cosign.VerifyAny(verifier, found, result.Digest)
+				switch {
+				case err == nil:
+					result.Status = signatureVerified
+				case errors.Is(err, cosign.ErrUnsigned):
+					result.Status = signatureUnsigned
+				default:
+					result.Status, result.Error = signatureInvalid, strings.ReplaceAll(err.Error(), "\n", "; ")
+				}
+				return result
+			})
+			switch strings.ToLower(format) {
+			case "text":
+				err = writeSignaturesText(cmd.OutOrStdout(), results)
+			case "json":
+				err = json.NewEncoder(cmd.OutOrStdout()).Encode(results)
+			default:
+				return fmt.Errorf("unknown value for format: %s", format)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Status != signatureVerified {
+					failed++
+				}
+			}
+			logger.Info("Verified signatures", "images", len(results), "failed", failed)
+			if failed > 0 {
+				return fmt.Errorf("%d of %d images failed signature verification", failed, len(results))
+			}
+			return nil
+		},
+	}
+	sources.addFlags(verifySignaturesCmd)
+	registries.addFlags(verifySignaturesCmd)
+	registries.addConcurrencyFlag(verifySignaturesCmd)
+	signatures.addFlags(verifySignaturesCmd)
+	verifySignaturesCmd.Flags().StringVarP(&format, "format", "o", "text", "Output format (options: text, json). text only lists the images that failed verification.")
+	return verifySignaturesCmd
+}
+
+// imageObjects maps every image to the objects using it, in the order they were found.
+func imageObjects(occurrences []images.Occurrence) map[string][]string {
+	objects := make(map[string][]string)
+	for _, occurrence := range occurrences {
+		object := occurrence.Object.String()
+		if !slices.Contains(objects[occurrence.Image], object) {
+			objects[occurrence.Image] = append(objects[occurrence.Image], object)
+		}
+	}
+	return objects
+}
+
+// writeSignaturesText writes the images that failed verification grouped by the reason,
+// e.g. "unsigned: nginx:1.25 (Deployment/production/web)".
+func writeSignaturesText(w io.Writer, results []signatureResult) error {
+	var sb strings.Builder
+	for _, status := range signatureProblems {
+		for _, result := range results {
+			if result.Status != status {
+				continue
+			}
+			fmt.Fprintf(&sb, "%s: %s (%s)", status, result.Image, strings.Join(result.Objects, ", "))
+			if result.Error != "" {
+				fmt.Fprintf(&sb, ": %s", result.Error)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
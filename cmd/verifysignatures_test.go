@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/cosign/cosigntest"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func TestVerifySignaturesCmdKey(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	key := cosigntest.NewKey()
+	signed := r.PushImage("app", "v1")
+	key.Sign(r, "app", signed)
+	referred := r.PushImage("app", "v2")
+	key.SignReferrer(r, "app", referred)
+	cosigntest.NewKey().Sign(r, "app", r.PushImage("app", "v3"))
+	r.PushImage("sidecar", "v1")
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "cosign.pub")
+	require.NoError(t, os.WriteFile(keyPath, key.PublicKeyPEM(), 0o600))
+	path := filepath.Join(dir, "deployment.yaml")
+	manifests := imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/app:v2@"+referred) + "---\n" +
+		imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v3", r.Host()+"/sidecar:v1")
+	require.NoError(t, os.WriteFile(path, []byte(manifests), 0o600))
+
+	verifySignaturesCmd := newVerifySignaturesCmd()
+	verifySignaturesCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	verifySignaturesCmd.SetOut(&stdout)
+	verifySignaturesCmd.SetErr(io.Discard)
+	verifySignaturesCmd.SetArgs([]string{"--plain-http", "--key", keyPath, path})
+	require.EqualError(t, verifySignaturesCmd.Execute(), "2 of 4 images failed signature verification")
+	require.Equal(t, "unsigned: "+r.Host()+"/sidecar:v1 (Deployment/production/web)\n"+
+		"invalid: "+r.Host()+"/app:v3 (Deployment/production/web): invalid signature\n", stdout.String())
+
+	verifySignaturesCmd = newVerifySignaturesCmd()
+	verifySignaturesCmd.SilenceUsage = true
+	stdout.Reset()
+	verifySignaturesCmd.SetOut(&stdout)
+	verifySignaturesCmd.SetErr(io.Discard)
+	verifySignaturesCmd.SetArgs([]string{"--plain-http", "--key", keyPath, "-o", "json", path})
+	require.Error(t, verifySignaturesCmd.Execute())
+	var results []signatureResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 4)
+	require.Equal(t, signatureResult{
+		Image:   r.Host() + "/app:v1",
+		Digest:  signed,
+		Status:  signatureVerified,
+		Objects: []string{"Deployment/production/web"},
+	}, results[0])
+	require.Equal(t, signatureVerified, results[1].Status)
+}
+
+func TestVerifySignaturesCmdKeyless(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	authority := cosigntest.NewAuthority()
+	identity := "release@example.com"
+	issuer := "https://accounts.example.com"
+	authority.Sign(r, "app", r.PushImage("app", "v1"), identity, issuer)
+	authority.Sign(r, "sidecar", r.PushImage("sidecar", "v1"), "someone@example.com", issuer)
+	dir := t.TempDir()
+	roots := filepath.Join(dir, "fulcio.crt.pem")
+	require.NoError(t, os.WriteFile(roots, authority.RootsPEM(), 0o600))
+	rekorKey := filepath.Join(dir, "rekor.pub")
+	require.NoError(t, os.WriteFile(rekorKey, authority.RekorPublicKeyPEM(), 0o600))
+	path := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(imagesFixture(t, "images_deployment.yaml", r.Host()+"/app:v1", r.Host()+"/sidecar:v1")), 0o600))
+
+	verifySignaturesCmd := newVerifySignaturesCmd()
+	verifySignaturesCmd.SilenceUsage = true
+	var stdout bytes.Buffer
+	verifySignaturesCmd.SetOut(&stdout)
+	verifySignaturesCmd.SetErr(io.Discard)
+	verifySignaturesCmd.SetArgs([]string{
+		"--plain-http", "--certificate-identity", identity, "--certificate-oidc-issuer", issuer,
+		"--certificate-roots", roots, "--rekor-key", rekorKey, path,
+	})
+	require.EqualError(t, verifySignaturesCmd.Execute(), "1 of 2 images failed signature verification")
+	require.Equal(t, "invalid: "+r.Host()+"/sidecar:v1 (Deployment/production/web): certificate identity someone@example.com doesn't match "+identity+"\n", stdout.String())
+}
+
+func TestVerifySignaturesCmdFlagErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{name: "no verification", args: []string{"../testdata/pod.yaml"}, err: "either --key or the keyless verification flags are required"},
+		{name: "key and keyless", args: []string{"--key", "cosign.pub", "--certificate-identity", "a@example.com", "../testdata/pod.yaml"}, err: "--key can't be used with the keyless verification flags"},
+		{name: "no identity", args: []string{"--certificate-oidc-issuer", "https://accounts.example.com", "../testdata/pod.yaml"}, err: "keyless verification requires --certificate-identity or --certificate-identity-regexp"},
+		{name: "no issuer", args: []string{"--certificate-identity", "a@example.com", "../testdata/pod.yaml"}, err: "keyless verification requires --certificate-oidc-issuer or --certificate-oidc-issuer-regexp"},
+		{name: "no roots", args: []string{"--certificate-identity", "a@example.com", "--certificate-oidc-issuer", "https://accounts.example.com", "../testdata/pod.yaml"}, err: "keyless verification requires --certificate-roots and --rekor-key"},
+		{name: "missing key", args: []string{"--key", "missing.pub", "../testdata/pod.yaml"}, err: "failed to read key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifySignaturesCmd := newVerifySignaturesCmd()
+			verifySignaturesCmd.SetOut(io.Discard)
+			verifySignaturesCmd.SetErr(io.Discard)
+			verifySignaturesCmd.SetArgs(tt.args)
+			require.ErrorContains(t, verifySignaturesCmd.Execute(), tt.err)
+		})
+	}
+}
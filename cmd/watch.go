@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// watcher keeps the images of the files under its paths, re-extracting only the files that changed since the last scan.
+type watcher struct {
+	extractor *images.Extractor
+	args      []string
+	// files are the files found by the last scan, by their path on the local file system.
+	files map[string]watchedFile
+}
+
+// watchedFile is the state of a file when its images were extracted.
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	images  map[string]struct{}
+}
+
+func newWatcher(extractor *images.Extractor, args []string) *watcher {
+	return &watcher{extractor: extractor, args: args, files: make(map[string]watchedFile)}
+}
+
+// scan extracts the images of files that are new or were modified and forgets files that were removed.
+// It reports whether any file changed. A file whose images can't be extracted, e.g. while it is being edited, keeps the
+// images it had and is logged, it is extracted again when it changes. When scan fails, e.g. because a path is missing
+// for a moment while an editor replaces it, the files are left as they were so the next scan sees the same changes.
+func (w *watcher) scan(ctx context.Context) (bool, error) {
+	files := maps.Clone(w.files)
+	changed := false
+	found := make(map[string]bool)
+	for _, arg := range w.args {
+		err := walkLocalFiles(arg, func(fsys fs.FS, path string, source string) error {
+			info, err := fs.Stat(fsys, path)
+			if err != nil {
+				return err
+			}
+			found[source] = true
+			file, ok := files[source]
+			if ok && file.modTime.Equal(info.ModTime()) && file.size == info.Size() {
+				return nil
+			}
+			changed = true
+			file.modTime, file.size = info.ModTime(), info.Size()
+			fileImages := make(map[string]struct{})
+			// archives are read like they are by skim list
+			err = w.extractFile(ctx, fsys, path, source, fileImages)
+			if err != nil {
+				w.extractor.Logger.ErrorContext(ctx, "Failed to extract images", "path", source, "error", err)
+			} else {
+				file.images = fileImages
+			}
+			files[source] = file
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to process %s: %w", arg, err)
+		}
+	}
+	for source := range files {
+		if !found[source] {
+			delete(files, source)
+			changed = true
+		}
+	}
+	w.files = files
+	return changed, nil
+}
+
+// extractFile extracts the images of the file at path in fsys, or of the manifest files inside it if it is an archive.
+func (w *watcher) extractFile(ctx context.Context, fsys fs.FS, path string, source string, fileImages map[string]struct{}) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return images.WalkFile(file, source, func(entry string, r io.Reader) error {
+		w.extractor.Logger.InfoContext(ctx, "Processing file", "path", entry)
+		return w.extractor.ExtractFromManifests(ctx, r, fileImages)
+	})
+}
+
+// images returns the images of every file.
+func (w *watcher) images() map[string]struct{} {
+	imagesOutput := make(map[string]struct{})
+	for _, file := range w.files {
+		maps.Copy(imagesOutput, file.images)
+	}
+	return imagesOutput
+}
+
+// watch scans the files every interval until ctx is done and calls fn with the images before and after every change.
+func (w *watcher) watch(ctx context.Context, logger *slog.Logger, interval time.Duration, fn func(before map[string]struct{}, after map[string]struct{}) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	before := w.images()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		changed, err := w.scan(ctx)
+		if err != nil {
+			// the path may come back, e.g. when an editor replaces a file
+			logger.ErrorContext(ctx, "Failed to scan files", "error", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		after := w.images()
+		if maps.Equal(before, after) {
+			logger.InfoContext(ctx, "Files changed, images didn't")
+			continue
+		}
+		err = fn(before, after)
+		if err != nil {
+			return err
+		}
+		before = after
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+// syncBuffer is a buffer commands running in the background can write to while a test reads it.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+func TestWatcherScan(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	require.NoError(t, os.WriteFile(app, []byte(imagesFixture(t, "images_pod.yaml", "nginx:1.25", "busybox:1.36")), 0o600))
+	extractor := images.NewExtractor()
+	extractor.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := newWatcher(extractor, []string{dir})
+
+	changed, err := w.scan(t.Context())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, map[string]struct{}{"nginx:1.25": {}, "busybox:1.36": {}}, w.images())
+	changed, err = w.scan(t.Context())
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// a file that can't be parsed keeps its images
+	require.NoError(t, os.WriteFile(app, []byte("kind: [\n"), 0o600))
+	changed, err = w.scan(t.Context())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, map[string]struct{}{"nginx:1.25": {}, "busybox:1.36": {}}, w.images())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte(imagesFixture(t, "images_pod.yaml", "redis:7", "busybox:1.36")), 0o600))
+	require.NoError(t, os.Remove(app))
+	changed, err = w.scan(t.Context())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, map[string]struct{}{"redis:7": {}, "busybox:1.36": {}}, w.images())
+}
+
+func TestWatcherScanFailureKeepsChanges(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	other := filepath.Join(dir, "other.yaml")
+	require.NoError(t, os.WriteFile(app, []byte(imagesFixture(t, "images_pod.yaml", "nginx:1.25", "busybox:1.36")), 0o600))
+	require.NoError(t, os.WriteFile(other, []byte(imagesFixture(t, "images_pod.yaml", "redis:7", "redis:7")), 0o600))
+	extractor := images.NewExtractor()
+	extractor.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := newWatcher(extractor, []string{app, other})
+	_, err := w.scan(t.Context())
+	require.NoError(t, err)
+
+	// app.yaml changes while other.yaml is missing for a moment, e.g. during an atomic save
+	require.NoError(t, os.WriteFile(app, []byte(imagesFixture(t, "images_pod.yaml", "nginx:1.27.0", "busybox:1.36")), 0o600))
+	require.NoError(t, os.Rename(other, other+".tmp"))
+	_, err = w.scan(t.Context())
+	require.Error(t, err)
+	require.Equal(t, map[string]struct{}{"nginx:1.25": {}, "busybox:1.36": {}, "redis:7": {}}, w.images())
+
+	require.NoError(t, os.Rename(other+".tmp", other))
+	changed, err := w.scan(t.Context())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, map[string]struct{}{"nginx:1.27.0": {}, "busybox:1.36": {}, "redis:7": {}}, w.images())
+}
+
+func TestListCmdWatch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	require.NoError(t, os.WriteFile(app, []byte(imagesFixture(t, "images_pod.yaml", "nginx:1.25", "busybox:1.36")), 0o600))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	listCmd := newListCmd()
+	var stdout syncBuffer
+	listCmd.SetOut(&stdout)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--watch", "--diff", "--interval", "10ms", dir})
+	done := make(chan error, 1)
+	go func() {
+		done <- listCmd.ExecuteContext(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return stdout.String() == "busybox:1.36\nnginx:1.25\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(app, []byte(imagesFixture(t, "images_pod.yaml", "nginx:1.27", "busybox:1.36")+"---\n"+imagesFixture(t, "images_pod.yaml", "redis:7", "redis:7")), 0o600))
+	require.Eventually(t, func() bool {
+		return stdout.String() == "busybox:1.36\nnginx:1.25\n~ nginx:1.25 → 1.27\n+ redis:7\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestListCmdWatchErrors(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{{"--watch", "-"}, {"--watch", "--git-ref", "HEAD"}} {
+		listCmd := newListCmd()
+		listCmd.SetOut(io.Discard)
+		listCmd.SetErr(io.Discard)
+		listCmd.SetArgs(args)
+		require.EqualError(t, listCmd.Execute(), "--watch only watches files, it can't be used with stdin or --git-ref")
+	}
+	listCmd := newListCmd()
+	listCmd.SetOut(io.Discard)
+	listCmd.SetErr(io.Discard)
+	listCmd.SetArgs([]string{"--watch", "--interval", "0s", t.TempDir()})
+	require.EqualError(t, listCmd.Execute(), "invalid interval 0s, it must be positive")
+}
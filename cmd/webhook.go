@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/policy"
+)
+
+// admissionAPIVersion is the apiVersion of the AdmissionReview objects skim webhook serves.
+const admissionAPIVersion = "admission.k8s.io/v1"
+
+// admissionReview is the part of an AdmissionReview skim webhook decodes and writes.
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace,omitempty"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object,omitempty"`
+}
+
+type admissionResponse struct {
+	UID      string           `json:"uid"`
+	Allowed  bool             `json:"allowed"`
+	Status   *admissionStatus `json:"status,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// admissionStatus is the status of a denied request, its message is shown to the user.
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newWebhookCmd() *cobra.Command {
+	var sources sourceOptions
+	var server serverOptions
+	var policyPath string
+	var webhookCmd = &cobra.Command{
+		Use:   "webhook --policy POLICY",
+		Short: "Serve a Kubernetes validating admission webhook checking images against a policy",
+		Long: `Serve a Kubernetes validating admission webhook checking images against a policy.
+
+POST /validate serves admission.k8s.io/v1 AdmissionReview requests. The images of the object are extracted like skim
+check does, for any GVK skim knows including custom resources, and the request is denied when they violate the policy,
+see skim check --help for its format. The namespace of the request is used for objects without one.
+
+Objects with unknown GVKs are allowed with a warning unless --unknown-gvk-behavior is fail or freetext. The API server
+only calls webhooks over HTTPS, serve it with --tls-cert and --tls-key or behind a proxy terminating TLS.`,
+		Example: `skim webhook --policy policy.yaml --addr :8443 --tls-cert tls.crt --tls-key tls.key`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			content, err := os.ReadFile(policyPath)
+			if err != nil {
+				return fmt.Errorf("failed to read policy: %w", err)
+			}
+			p, err := policy.Parse(content)
+			if err != nil {
+				return err
+			}
+			// fail early on an invalid behavior, an extractor is created for every request
+			_, err = sources.newExtractor(logger)
+			if err != nil {
+				return err
+			}
+			return server.serve(cmd, logger, newWebhookHandler(logger, sources, p))
+		},
+	}
+	sources.addUnknownGVKBehaviorFlag(webhookCmd, "skip")
+	server.addFlags(webhookCmd, ":8443")
+	webhookCmd.Flags().StringVarP(&policyPath, "policy", "p", "", "Path to the policy file.")
+	_ = webhookCmd.MarkFlagRequired("policy")
+	return webhookCmd
+}
+
+// newWebhookHandler returns the handler of skim webhook, it checks the images of objects against p.
+func newWebhookHandler(logger *slog.Logger, sources sourceOptions, p *policy.Policy) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /validate", func(w http.ResponseWriter, r *http.Request) {
+		var review admissionReview
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&review)
+		if err != nil {
+			writeJSON(w, logger, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to decode AdmissionReview: %s", err)})
+			return
+		}
+		if review.APIVersion != admissionAPIVersion || review.Kind != "AdmissionReview" || review.Request == nil {
+			writeJSON(w, logger, http.StatusBadRequest, errorResponse{Error: "expected an " + admissionAPIVersion + " AdmissionReview request"})
+			return
+		}
+		response := admit(r, logger, sources, p, review.Request)
+		writeJSON(w, logger, http.StatusOK, admissionReview{APIVersion: admissionAPIVersion, Kind: "AdmissionReview", Response: response})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// admit checks the images of the object of an admission request against p.
+func admit(r *http.Request, logger *slog.Logger, sources sourceOptions, p *policy.Policy, request *admissionRequest) *admissionResponse {
+	response := &admissionResponse{UID: request.UID, Allowed: true}
+	// deleted objects have no object, there is nothing to check
+	if len(request.Object) == 0 || bytes.Equal(request.Object, []byte("null")) {
+		return response
+	}
+	extractor, err := sources.newExtractor(logger)
+	if err != nil {
+		return deny(response, http.StatusInternalServerError, err.Error())
+	}
+	extractor.UnknownGVKFound = func(unknownGVK images.UnknownGVK) {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("skim doesn't know where %s keeps images", unknownGVK.GVK))
+	}
+	occurrences, err := extractor.ExtractOccurrencesFromManifests(r.Context(), bytes.NewReader(request.Object), "")
+	if err != nil {
+		if _, ok := errors.AsType[*images.UnknownGVKError](err); ok {
+			return deny(response, http.StatusForbidden, err.Error())
+		}
+		return deny(response, http.StatusBadRequest, fmt.Sprintf("failed to extract images: %s", err))
+	}
+	for i := range occurrences {
+		if occurrences[i].Object.Namespace == "" {
+			occurrences[i].Object.Namespace = request.Namespace
+		}
+	}
+	violations := p.Check(occurrences)
+	if len(violations) > 0 {
+		messages := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			check := violation.Check
+			if violation.Rule != "" {
+				check = violation.Rule + "/" + check
+			}
+			messages = append(messages, fmt.Sprintf("%s: %s [%s]", violation.Occurrence.Image, violation.Message, check))
+		}
+		deny(response, http.StatusForbidden, "images violate the policy: "+strings.Join(messages, "; "))
+	}
+	logger.InfoContext(r.Context(), "Reviewed object", "uid", request.UID, "operation", request.Operation, "images", len(occurrences), "allowed", response.Allowed)
+	return response
+}
+
+// deny denies an admission request with a status code and message shown to the user.
+func deny(response *admissionResponse, code int, message string) *admissionResponse {
+	response.Allowed = false
+	response.Status = &admissionStatus{Code: code, Message: message}
+	return response
+}
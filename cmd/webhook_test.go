@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/policy"
+)
+
+// newWebhookServer starts a test server of skim webhook with the check policy and an unknown GVK behavior.
+func newWebhookServer(t *testing.T, unknownGVKBehavior string) *httptest.Server {
+	t.Helper()
+	p, err := policy.Parse([]byte(checkPolicy))
+	require.NoError(t, err)
+	server := httptest.NewServer(newWebhookHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), sourceOptions{unknownGVKBehavior: unknownGVKBehavior}, p))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// review posts an AdmissionReview for object to a webhook test server and returns its response.
+func review(t *testing.T, server *httptest.Server, namespace string, object string) *admissionResponse {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": admissionAPIVersion,
+		"kind":       "AdmissionReview",
+		"request":    map[string]any{"uid": "705ab4f5-6393-11e8-b7cc-42010a800002", "namespace": namespace, "operation": "CREATE", "object": json.RawMessage(object)},
+	})
+	require.NoError(t, err)
+	request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/validate", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := server.Client().Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var review admissionReview
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&review))
+	require.Equal(t, admissionAPIVersion, review.APIVersion)
+	require.Equal(t, "AdmissionReview", review.Kind)
+	require.NotNil(t, review.Response)
+	require.Equal(t, "705ab4f5-6393-11e8-b7cc-42010a800002", review.Response.UID)
+	return review.Response
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+	server := newWebhookServer(t, "skip")
+
+	// the operator creates pods with the image of the cluster, the cluster itself is denied
+	cluster := `{"apiVersion":"postgresql.cnpg.io/v1","kind":"Cluster","metadata":{"name":"db","labels":{"tier":"web"}},"spec":{"imageName":"ghcr.io/cloudnative-pg/postgresql:16"}}`
+	response := review(t, server, "production", cluster)
+	require.False(t, response.Allowed)
+	require.Equal(t, &admissionStatus{
+		Code:    http.StatusForbidden,
+		Message: "images violate the policy: ghcr.io/cloudnative-pg/postgresql:16: registry ghcr.io is not allowed, allowed registries are registry.example.com [production/allowed-registries]",
+	}, response.Status)
+	// the namespace of the object is matched
+	require.True(t, review(t, server, "staging", cluster).Allowed)
+
+	inferenceService := `{"apiVersion":"serving.kserve.io/v1beta1","kind":"InferenceService","metadata":{"name":"model","namespace":"staging"},"spec":{"predictor":{"containers":[{"name":"kserve-container","image":"registry.example.com/model"}]}}}`
+	response = review(t, server, "", inferenceService)
+	require.False(t, response.Allowed)
+	require.Contains(t, response.Status.Message, "registry.example.com/model: ")
+	require.Contains(t, response.Status.Message, "[everywhere/require-tag]")
+
+	response = review(t, server, "production", `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"widget"}}`)
+	require.True(t, response.Allowed)
+	require.Equal(t, []string{"skim doesn't know where example.com/v1.Widget keeps images"}, response.Warnings)
+
+	// deleted objects are allowed
+	require.True(t, review(t, server, "production", "null").Allowed)
+}
+
+func TestWebhookUnknownGVKFail(t *testing.T) {
+	t.Parallel()
+	server := newWebhookServer(t, "fail")
+	response := review(t, server, "production", `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"widget"}}`)
+	require.False(t, response.Allowed)
+	require.Equal(t, http.StatusForbidden, response.Status.Code)
+	require.Contains(t, response.Status.Message, "example.com/v1.Widget")
+}
+
+func TestWebhookErrors(t *testing.T) {
+	t.Parallel()
+	server := newWebhookServer(t, "skip")
+	for _, body := range []string{"{", `{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"1"}}`} {
+		request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/validate", strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := server.Client().Do(request)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(checkPolicy), 0o600))
+	webhookCmd := newWebhookCmd()
+	webhookCmd.SetOut(io.Discard)
+	webhookCmd.SetErr(io.Discard)
+	webhookCmd.SetArgs([]string{"--policy", policyPath, "-u", "ignore"})
+	require.EqualError(t, webhookCmd.Execute(), "unknown value for unknown-gvk-behavior: ignore")
+}
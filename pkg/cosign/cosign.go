@@ -0,0 +1,163 @@
+// Package cosign finds and verifies cosign signatures of container images, see https://github.com/sigstore/cosign.
+//
+// Signatures are looked up with the tag scheme, a sha256-<hex>.sig tag in the repository of the image, and with the
+// OCI referrers API. Each layer of a signature manifest is a simple signing payload whose signature is in its annotations.
+package cosign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+const (
+	// MediaTypeSimpleSigning is the media type of the layers holding signed payloads.
+	MediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// ArtifactTypeSignature is the artifact type of signatures stored with the referrers API.
+	ArtifactTypeSignature = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	// PayloadType is the type of the critical section of payloads.
+	PayloadType = "cosign container image signature"
+
+	// Annotations of signature layers.
+	AnnotationSignature   = "dev.cosignproject.cosign/signature"
+	AnnotationCertificate = "dev.sigstore.cosign/certificate"
+	AnnotationChain       = "dev.sigstore.cosign/chain"
+	AnnotationBundle      = "dev.sigstore.cosign/bundle"
+)
+
+// maxPayloadSize limits the size of signed payloads.
+const maxPayloadSize = 1 << 20
+
+// Signature is a signed payload and what is needed to verify it.
+type Signature struct {
+	// Payload is the signed simple signing payload.
+	Payload []byte
+	// Signature is the decoded signature of the payload.
+	Signature []byte
+	// Certificate is the PEM encoded signing certificate of keyless signatures.
+	Certificate []byte
+	// Chain is the PEM encoded chain of the certificate, it may be empty.
+	Chain []byte
+	// Bundle is the transparency log entry of keyless signatures.
+	Bundle *Bundle
+}
+
+// Bundle proves that a signature was added to the Rekor transparency log, see https://github.com/sigstore/rekor.
+type Bundle struct {
+	// SignedEntryTimestamp is the signature of the log over the canonical JSON of the payload.
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		// Body is the base64 encoded log entry.
+		Body string `json:"body"`
+		// IntegratedTime is when the entry was added to the log, in seconds since the Unix epoch.
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// Payload is a simple signing payload, see https://github.com/containers/image/blob/main/docs/containers-signature.5.md.
+type Payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]any `json:"optional"`
+}
+
+// NewPayload returns the payload cosign signs for the manifest with digest in the repository name, e.g. docker.io/library/nginx.
+func NewPayload(name string, digest string) []byte {
+	var payload Payload
+	payload.Critical.Identity.DockerReference = name
+	payload.Critical.Image.DockerManifestDigest = digest
+	payload.Critical.Type = PayloadType
+	content, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// SignatureTag returns the tag of the signatures of the manifest with digest, e.g. sha256-<hex>.sig.
+func SignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// Fetch returns the signatures of the manifest with digest in the repository of ref, from both the tag scheme and the referrers API.
+// An image without signatures has none and no error.
+func Fetch(ctx context.Context, client *registry.Client, ref reference.Reference, digest string) ([]Signature, error) {
+	manifests := []reference.Reference{{Registry: ref.Registry, Repository: ref.Repository, Tag: SignatureTag(digest)}}
+	referrers, err := client.Referrers(ctx, ref, digest, ArtifactTypeSignature)
+	if err != nil && !errors.Is(err, registry.ErrNotFound) {
+		return nil, fmt.Errorf("failed to list referrers of %s@%s: %w", ref.Name(), digest, err)
+	}
+	for _, referrer := range referrers {
+		manifests = append(manifests, reference.Reference{Registry: ref.Registry, Repository: ref.Repository, Digest: referrer.Digest})
+	}
+	var signatures []Signature
+	for _, manifestRef := range manifests {
+		descriptor, content, err := client.Manifest(ctx, manifestRef)
+		if errors.Is(err, registry.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch signatures %s: %w", manifestRef, err)
+		}
+		manifest, err := registry.ParseManifest(descriptor, content)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range manifest.Layers {
+			if layer.MediaType != MediaTypeSimpleSigning {
+				continue
+			}
+			signature, err := fetchSignature(ctx, client, manifestRef, layer)
+			if err != nil {
+				return nil, err
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+	return signatures, nil
+}
+
+func fetchSignature(ctx context.Context, client *registry.Client, ref reference.Reference, layer registry.Descriptor) (Signature, error) {
+	blob, err := client.Blob(ctx, ref, layer.Digest)
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to fetch signature payload %s: %w", layer.Digest, err)
+	}
+	defer blob.Close()
+	payload, err := io.ReadAll(io.LimitReader(blob, maxPayloadSize))
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to read signature payload %s: %w", layer.Digest, err)
+	}
+	if registry.Digest(payload) != layer.Digest {
+		return Signature{}, fmt.Errorf("signature payload %s has digest %s", layer.Digest, registry.Digest(payload))
+	}
+	signature := Signature{
+		Payload:     payload,
+		Certificate: []byte(layer.Annotations[AnnotationCertificate]),
+		Chain:       []byte(layer.Annotations[AnnotationChain]),
+	}
+	// an undecodable signature or bundle makes the signature invalid rather than the lookup failed, verification reports it
+	signature.Signature, _ = base64.StdEncoding.DecodeString(layer.Annotations[AnnotationSignature])
+	if bundle := layer.Annotations[AnnotationBundle]; bundle != "" {
+		signature.Bundle = &Bundle{}
+		if json.Unmarshal([]byte(bundle), signature.Bundle) != nil {
+			signature.Bundle = nil
+		}
+	}
+	return signature, nil
+}
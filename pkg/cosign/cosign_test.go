@@ -0,0 +1,135 @@
+package cosign_test
+
+import (
+	"crypto"
+	"crypto/x509"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/cosign"
+	"github.com/yardenshoham/skim/pkg/cosign/cosigntest"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func fetch(t *testing.T, r *registrytest.Registry, repository string, digest string) []cosign.Signature {
+	t.Helper()
+	ref, err := reference.Parse(r.Host() + "/" + repository)
+	require.NoError(t, err)
+	signatures, err := cosign.Fetch(t.Context(), &registry.Client{PlainHTTP: true}, ref, digest)
+	require.NoError(t, err)
+	return signatures
+}
+
+func TestKeyVerifier(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	key := cosigntest.NewKey()
+	publicKey, err := cosign.LoadPublicKey(key.PublicKeyPEM())
+	require.NoError(t, err)
+	verifier := cosign.KeyVerifier{Key: publicKey}
+	otherKey, err := cosign.LoadPublicKey(cosigntest.NewKey().PublicKeyPEM())
+	require.NoError(t, err)
+
+	tagged := r.PushImage("app", "v1")
+	key.Sign(r, "app", tagged)
+	signatures := fetch(t, r, "app", tagged)
+	require.Len(t, signatures, 1)
+	require.NoError(t, cosign.VerifyAny(verifier, signatures, tagged))
+	require.EqualError(t, cosign.VerifyAny(cosign.KeyVerifier{Key: otherKey}, signatures, tagged), "invalid signature")
+
+	referred := r.PushImage("app", "v2")
+	key.SignReferrer(r, "app", referred)
+	require.NoError(t, cosign.VerifyAny(verifier, fetch(t, r, "app", referred), referred))
+
+	unsigned := r.PushImage("app", "v3")
+	require.ErrorIs(t, cosign.VerifyAny(verifier, fetch(t, r, "app", unsigned), unsigned), cosign.ErrUnsigned)
+
+	// a signature copied to another manifest doesn't sign it
+	r.PushManifest("app", cosign.SignatureTag(unsigned), registry.MediaTypeOCIManifest, manifestOf(t, r, "app", cosign.SignatureTag(tagged)))
+	require.ErrorContains(t, cosign.VerifyAny(verifier, fetch(t, r, "app", unsigned), unsigned), "signature is for manifest "+tagged)
+}
+
+func TestKeylessVerifier(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	authority := cosigntest.NewAuthority()
+	roots, err := cosign.LoadCertificates(authority.RootsPEM())
+	require.NoError(t, err)
+	rekorKey, err := cosign.LoadPublicKey(authority.RekorPublicKeyPEM())
+	require.NoError(t, err)
+	identity := "https://github.com/example/app/.github/workflows/release.yaml@refs/heads/main"
+	issuer := "https://token.actions.githubusercontent.com"
+	digest := r.PushImage("app", "v1")
+	authority.Sign(r, "app", digest, identity, issuer)
+	signatures := fetch(t, r, "app", digest)
+
+	tests := []struct {
+		name     string
+		verifier cosign.KeylessVerifier
+		err      string
+	}{
+		{name: "identity", verifier: cosign.KeylessVerifier{Identity: identity, Issuer: issuer}},
+		{name: "regexps", verifier: cosign.KeylessVerifier{IdentityRegexp: regexp.MustCompile(`^https://github\.com/example/`), IssuerRegexp: regexp.MustCompile(`githubusercontent`)}},
+		{name: "other identity", verifier: cosign.KeylessVerifier{Identity: "someone@example.com", Issuer: issuer}, err: "certificate identity " + identity + " doesn't match someone@example.com"},
+		{name: "other issuer", verifier: cosign.KeylessVerifier{Identity: identity, Issuer: "https://accounts.google.com"}, err: `certificate OIDC issuer "` + issuer + `" doesn't match https://accounts.google.com`},
+		{name: "other roots", verifier: cosign.KeylessVerifier{Identity: identity, Issuer: issuer, Roots: otherRoots(t)}, err: "untrusted signature certificate"},
+		{name: "other log", verifier: cosign.KeylessVerifier{Identity: identity, Issuer: issuer, RekorKey: otherRekorKey(t)}, err: "invalid transparency log bundle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier := tt.verifier
+			if verifier.Roots == nil {
+				verifier.Roots = roots
+			}
+			if verifier.RekorKey == nil {
+				verifier.RekorKey = rekorKey
+			}
+			err := cosign.VerifyAny(verifier, signatures, digest)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	// a keyless signature isn't made with a key
+	publicKey, err := cosign.LoadPublicKey(cosigntest.NewKey().PublicKeyPEM())
+	require.NoError(t, err)
+	require.Error(t, cosign.VerifyAny(cosign.KeyVerifier{Key: publicKey}, signatures, digest))
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	_, err := cosign.LoadPublicKey([]byte("not a key"))
+	require.ErrorContains(t, err, "isn't PEM encoded")
+	_, err = cosign.LoadCertificates(cosigntest.NewKey().PublicKeyPEM())
+	require.ErrorContains(t, err, "there are no PEM encoded certificates")
+}
+
+func manifestOf(t *testing.T, r *registrytest.Registry, repository string, tag string) []byte {
+	t.Helper()
+	ref, err := reference.Parse(r.Host() + "/" + repository + ":" + tag)
+	require.NoError(t, err)
+	_, content, err := (&registry.Client{PlainHTTP: true}).Manifest(t.Context(), ref)
+	require.NoError(t, err)
+	return content
+}
+
+func otherRoots(t *testing.T) *x509.CertPool {
+	t.Helper()
+	roots, err := cosign.LoadCertificates(cosigntest.NewAuthority().RootsPEM())
+	require.NoError(t, err)
+	return roots
+}
+
+func otherRekorKey(t *testing.T) crypto.PublicKey {
+	t.Helper()
+	key, err := cosign.LoadPublicKey(cosigntest.NewAuthority().RekorPublicKeyPEM())
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,229 @@
+// Package cosigntest signs images in a [registrytest.Registry] the way cosign does, for tests.
+package cosigntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yardenshoham/skim/pkg/cosign"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+// oidIssuer is the Fulcio extension with the OIDC issuer.
+var oidIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+
+// Key is a key pair like the one cosign generate-key-pair creates.
+type Key struct {
+	private *ecdsa.PrivateKey
+}
+
+// NewKey generates a P-256 key pair.
+func NewKey() *Key {
+	return &Key{private: mustGenerateKey()}
+}
+
+// PublicKeyPEM returns the PEM encoded public key, like cosign.pub.
+func (k *Key) PublicKeyPEM() []byte {
+	return publicKeyPEM(k.private)
+}
+
+// Sign signs the manifest with digest in a repository and stores the signature with the tag scheme.
+func (k *Key) Sign(r *registrytest.Registry, repository string, digest string) {
+	payload, signature := sign(k.private, r.Host()+"/"+repository, digest)
+	pushTag(r, repository, digest, payload, map[string]string{cosign.AnnotationSignature: signature})
+}
+
+// SignReferrer signs the manifest with digest in a repository and stores the signature as a referrer of the manifest.
+func (k *Key) SignReferrer(r *registrytest.Registry, repository string, digest string) {
+	payload, signature := sign(k.private, r.Host()+"/"+repository, digest)
+	layer := pushLayer(r, repository, payload, map[string]string{cosign.AnnotationSignature: signature})
+	manifest := registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		ArtifactType:  cosign.ArtifactTypeSignature,
+		Config:        pushDescriptor(r, repository, "application/vnd.oci.empty.v1+json", []byte("{}")),
+		Layers:        []registry.Descriptor{layer},
+		Subject:       &registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: digest},
+	}
+	r.PushManifest(repository, "", registry.MediaTypeOCIManifest, mustMarshal(manifest))
+}
+
+// Authority is a Fulcio certificate authority and a Rekor transparency log for keyless signatures.
+type Authority struct {
+	root     *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+}
+
+// NewAuthority creates a root certificate and a transparency log key.
+func NewAuthority() *Authority {
+	a := &Authority{rootKey: mustGenerateKey(), rekorKey: mustGenerateKey()}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cosigntest"}, CommonName: "cosigntest root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &a.rootKey.PublicKey, a.rootKey)
+	if err != nil {
+		panic(err)
+	}
+	a.root, err = x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// RootsPEM returns the PEM encoded root certificate.
+func (a *Authority) RootsPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.root.Raw})
+}
+
+// RekorPublicKeyPEM returns the PEM encoded public key of the transparency log.
+func (a *Authority) RekorPublicKeyPEM() []byte {
+	return publicKeyPEM(a.rekorKey)
+}
+
+// Sign signs the manifest with digest in a repository with a short-lived certificate for an identity, an email or URI,
+// issued for an OIDC issuer, logs the signature and stores it with the tag scheme.
+// The certificate expired long ago, like Fulcio's do, it was valid when the signature was logged.
+func (a *Authority) Sign(r *registrytest.Registry, repository string, digest string, identity string, issuer string) {
+	key := mustGenerateKey()
+	issuerValue, err := asn1.MarshalWithParams(issuer, "utf8")
+	if err != nil {
+		panic(err)
+	}
+	signedAt := time.Now().Add(-time.Hour)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-time.Minute),
+		NotAfter:        signedAt.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuer, Value: issuerValue}},
+	}
+	if strings.Contains(identity, "://") {
+		uri, err := url.Parse(identity)
+		if err != nil {
+			panic(err)
+		}
+		template.URIs = []*url.URL{uri}
+	} else {
+		template.EmailAddresses = []string{identity}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.root, &key.PublicKey, a.rootKey)
+	if err != nil {
+		panic(err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	payload, signature := sign(key, r.Host()+"/"+repository, digest)
+
+	hash := sha256.Sum256(payload)
+	body := mustMarshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"signature": map[string]any{
+				"content":   signature,
+				"publicKey": map[string]any{"content": base64.StdEncoding.EncodeToString(certificate)},
+			},
+			"data": map[string]any{"hash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(hash[:])}},
+		},
+	})
+	var bundle cosign.Bundle
+	bundle.Payload.Body = base64.StdEncoding.EncodeToString(body)
+	bundle.Payload.IntegratedTime = signedAt.Unix()
+	bundle.Payload.LogIndex = 1
+	bundle.Payload.LogID = "cosigntest"
+	canonical := mustMarshal(map[string]any{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	})
+	canonicalHash := sha256.Sum256(canonical)
+	bundle.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, a.rekorKey, canonicalHash[:])
+	if err != nil {
+		panic(err)
+	}
+	pushTag(r, repository, digest, payload, map[string]string{
+		cosign.AnnotationSignature:   signature,
+		cosign.AnnotationCertificate: string(certificate),
+		cosign.AnnotationChain:       string(a.RootsPEM()),
+		cosign.AnnotationBundle:      string(mustMarshal(bundle)),
+	})
+}
+
+// sign returns the payload cosign signs for a manifest and its base64 encoded signature.
+func sign(key *ecdsa.PrivateKey, name string, digest string) ([]byte, string) {
+	payload := cosign.NewPayload(name, digest)
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		panic(err)
+	}
+	return payload, base64.StdEncoding.EncodeToString(signature)
+}
+
+// pushTag stores a signature manifest with a single layer under the signature tag of digest.
+func pushTag(r *registrytest.Registry, repository string, digest string, payload []byte, annotations map[string]string) {
+	manifest := registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		Config:        pushDescriptor(r, repository, "application/vnd.oci.image.config.v1+json", []byte("{}")),
+		Layers:        []registry.Descriptor{pushLayer(r, repository, payload, annotations)},
+	}
+	r.PushManifest(repository, cosign.SignatureTag(digest), registry.MediaTypeOCIManifest, mustMarshal(manifest))
+}
+
+func pushLayer(r *registrytest.Registry, repository string, payload []byte, annotations map[string]string) registry.Descriptor {
+	layer := pushDescriptor(r, repository, cosign.MediaTypeSimpleSigning, payload)
+	layer.Annotations = annotations
+	return *layer
+}
+
+func pushDescriptor(r *registrytest.Registry, repository string, mediaType string, content []byte) *registry.Descriptor {
+	return &registry.Descriptor{MediaType: mediaType, Digest: r.PushBlob(repository, content), Size: int64(len(content))}
+}
+
+func publicKeyPEM(key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func mustGenerateKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustMarshal(v any) []byte {
+	content, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
@@ -0,0 +1,309 @@
+package cosign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrUnsigned is returned when an image has no signatures.
+var ErrUnsigned = errors.New("no signatures")
+
+var (
+	// oidIssuerV2 is the Fulcio extension with the OIDC issuer as a DER encoded string.
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	// oidIssuerV1 is the deprecated Fulcio extension with the OIDC issuer as raw bytes.
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+)
+
+// Verifier verifies signatures.
+type Verifier interface {
+	// Verify checks that a signature is valid and that it signs the manifest with digest.
+	Verify(signature Signature, digest string) error
+}
+
+// VerifyAny returns nil when one of the signatures verifies, [ErrUnsigned] when there are none
+// and the reasons every signature failed otherwise.
+func VerifyAny(verifier Verifier, signatures []Signature, digest string) error {
+	if len(signatures) == 0 {
+		return ErrUnsigned
+	}
+	var errs []error
+	for _, signature := range signatures {
+		err := verifier.Verify(signature, digest)
+		if err == nil {
+			return nil
+		}
+		if !slices.ContainsFunc(errs, func(e error) bool { return e.Error() == err.Error() }) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KeyVerifier verifies signatures made with the private key of a key pair, e.g. one created with cosign generate-key-pair.
+type KeyVerifier struct {
+	Key crypto.PublicKey
+}
+
+// Verify implements [Verifier].
+func (v KeyVerifier) Verify(signature Signature, digest string) error {
+	err := verifySignature(v.Key, signature.Payload, signature.Signature)
+	if err != nil {
+		return err
+	}
+	return verifyPayload(signature.Payload, digest)
+}
+
+// KeylessVerifier verifies signatures made with short-lived Fulcio certificates that were added to the Rekor transparency log.
+// The certificate must chain to one of the roots at the time the log integrated the signature,
+// and its identity and OIDC issuer must match the constraints that are set.
+type KeylessVerifier struct {
+	// Roots are the trusted certificate authorities, e.g. the Fulcio root.
+	Roots *x509.CertPool
+	// RekorKey is the public key of the transparency log, it verifies the signed entry timestamps of bundles.
+	RekorKey crypto.PublicKey
+	// Identity is the email or URI the certificate must have, e.g. https://github.com/org/repo/.github/workflows/release.yaml@refs/heads/main.
+	Identity string
+	// IdentityRegexp must match an email or URI of the certificate.
+	IdentityRegexp *regexp.Regexp
+	// Issuer is the OIDC issuer the certificate must have been issued for, e.g. https://token.actions.githubusercontent.com.
+	Issuer string
+	// IssuerRegexp must match the OIDC issuer.
+	IssuerRegexp *regexp.Regexp
+}
+
+// Verify implements [Verifier].
+func (v KeylessVerifier) Verify(signature Signature, digest string) error {
+	certificates := parseCertificates(signature.Certificate)
+	if len(certificates) == 0 {
+		return errors.New("signature has no certificate")
+	}
+	certificate, err := x509.ParseCertificate(certificates[0])
+	if err != nil {
+		return fmt.Errorf("invalid signature certificate: %w", err)
+	}
+	if signature.Bundle == nil {
+		return errors.New("signature has no transparency log bundle")
+	}
+	err = v.verifyBundle(*signature.Bundle, signature, certificate)
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, der := range parseCertificates(signature.Chain) {
+		if chained, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(chained)
+		}
+	}
+	_, err = certificate.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(signature.Bundle.Payload.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("untrusted signature certificate: %w", err)
+	}
+	err = v.verifyIdentity(certificate)
+	if err != nil {
+		return err
+	}
+	err = verifySignature(certificate.PublicKey, signature.Payload, signature.Signature)
+	if err != nil {
+		return err
+	}
+	return verifyPayload(signature.Payload, digest)
+}
+
+// verifyBundle checks the log's signature over the bundle and that the logged entry is the signature.
+func (v KeylessVerifier) verifyBundle(bundle Bundle, signature Signature, certificate *x509.Certificate) error {
+	// the log signs the canonical JSON of the payload, object keys in lexical order and no whitespace
+	canonical, err := json.Marshal(map[string]any{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode transparency log bundle: %w", err)
+	}
+	err = verifySignature(v.RekorKey, canonical, bundle.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log bundle: %w", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	var entry struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Signature struct {
+				Content   string `json:"content"`
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+		} `json:"spec"`
+	}
+	err = json.Unmarshal(body, &entry)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	if entry.Kind != "hashedrekord" {
+		return fmt.Errorf("unsupported transparency log entry kind %q", entry.Kind)
+	}
+	loggedSignature, _ := base64.StdEncoding.DecodeString(entry.Spec.Signature.Content)
+	loggedCertificate, _ := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+	hash := sha256.Sum256(signature.Payload)
+	switch {
+	case !bytes.Equal(loggedSignature, signature.Signature):
+		return errors.New("transparency log entry is for another signature")
+	case !slices.ContainsFunc(parseCertificates(loggedCertificate), func(der []byte) bool { return bytes.Equal(der, certificate.Raw) }):
+		return errors.New("transparency log entry is for another certificate")
+	case entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(hash[:]):
+		return errors.New("transparency log entry is for another payload")
+	}
+	return nil
+}
+
+func (v KeylessVerifier) verifyIdentity(certificate *x509.Certificate) error {
+	identities := slices.Clone(certificate.EmailAddresses)
+	for _, uri := range certificate.URIs {
+		identities = append(identities, uri.String())
+	}
+	if v.Identity != "" && !slices.Contains(identities, v.Identity) {
+		return fmt.Errorf("certificate identity %s doesn't match %s", strings.Join(identities, ", "), v.Identity)
+	}
+	if v.IdentityRegexp != nil && !slices.ContainsFunc(identities, v.IdentityRegexp.MatchString) {
+		return fmt.Errorf("certificate identity %s doesn't match %s", strings.Join(identities, ", "), v.IdentityRegexp)
+	}
+	issuer := certificateIssuer(certificate)
+	if v.Issuer != "" && issuer != v.Issuer {
+		return fmt.Errorf("certificate OIDC issuer %q doesn't match %s", issuer, v.Issuer)
+	}
+	if v.IssuerRegexp != nil && !v.IssuerRegexp.MatchString(issuer) {
+		return fmt.Errorf("certificate OIDC issuer %q doesn't match %s", issuer, v.IssuerRegexp)
+	}
+	return nil
+}
+
+// certificateIssuer returns the OIDC issuer Fulcio recorded in a certificate.
+func certificateIssuer(certificate *x509.Certificate) string {
+	var legacy string
+	for _, extension := range certificate.Extensions {
+		switch {
+		case extension.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(extension.Value, &issuer); err == nil {
+				return issuer
+			}
+		case extension.Id.Equal(oidIssuerV1):
+			legacy = string(extension.Value)
+		}
+	}
+	return legacy
+}
+
+// verifyPayload checks that a payload signs the manifest with digest.
+func verifyPayload(content []byte, digest string) error {
+	var payload Payload
+	err := json.Unmarshal(content, &payload)
+	if err != nil {
+		return fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if payload.Critical.Type != PayloadType {
+		return fmt.Errorf("unsupported signature payload type %q", payload.Critical.Type)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for manifest %s", payload.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// verifySignature verifies the signature of a message, ECDSA and RSA signatures are over its SHA-256 hash.
+func verifySignature(key crypto.PublicKey, message []byte, signature []byte) error {
+	hash := sha256.Sum256(message)
+	var valid bool
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil || rsa.VerifyPSS(key, crypto.SHA256, hash[:], signature, nil) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, message, signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// LoadPublicKey parses a PEM encoded public key, e.g. the cosign.pub of cosign generate-key-pair.
+func LoadPublicKey(content []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("failed to decode public key, it isn't PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadCertificates parses PEM encoded certificates into a pool, e.g. the Fulcio root and intermediate certificates.
+func LoadCertificates(content []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	blocks := parseCertificates(content)
+	if len(blocks) == 0 {
+		return nil, errors.New("failed to decode certificates, there are no PEM encoded certificates")
+	}
+	for _, der := range blocks {
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		pool.AddCert(certificate)
+	}
+	return pool, nil
+}
+
+// parseCertificates returns the DER content of the certificates in PEM encoded content.
+func parseCertificates(content []byte) [][]byte {
+	var certificates [][]byte
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return certificates
+		}
+		if block.Type == "CERTIFICATE" {
+			certificates = append(certificates, block.Bytes)
+		}
+	}
+}
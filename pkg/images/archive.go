@@ -0,0 +1,152 @@
+package images
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// archiveSeparator separates the name of an archive from the name of an entry inside it, e.g. bundle.tar.gz!/templates/deploy.yaml.
+const archiveSeparator = "!/"
+
+var archiveExtensions = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// IsArchive reports whether name has the extension of an archive format the extractor can read (tar, tar.gz, tgz or zip).
+func IsArchive(name string) bool {
+	return hasExtension(name, archiveExtensions)
+}
+
+// IsManifest reports whether name has the extension of a YAML or JSON file, the files read when walking archives.
+func IsManifest(name string) bool {
+	return hasExtension(name, manifestExtensions)
+}
+
+func hasExtension(name string, extensions []string) bool {
+	name = strings.ToLower(name)
+	for _, extension := range extensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractFromTar extracts image references from the YAML and JSON files inside a tar stream placing them in the images map as keys.
+// The entries are found with [WalkTar].
+func (e *Extractor) ExtractFromTar(ctx context.Context, r io.Reader, name string, images map[string]struct{}) error {
+	return WalkTar(r, name, e.extractFromManifestFile(ctx, images))
+}
+
+// ExtractFromZip extracts image references from the YAML and JSON files inside a zip archive placing them in the images map as keys.
+// The entries are found with [WalkZip].
+func (e *Extractor) ExtractFromZip(ctx context.Context, r io.ReaderAt, size int64, name string, images map[string]struct{}) error {
+	return WalkZip(r, size, name, e.extractFromManifestFile(ctx, images))
+}
+
+// WalkTar calls fn for every YAML and JSON file inside a tar stream.
+// Gzip compressed streams are detected and decompressed automatically. The archive is read sequentially and never unpacked to disk.
+// name describes the archive, entries are described as name!/entry.
+func WalkTar(r io.Reader, name string, fn ManifestFunc) error {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read archive %s: %w", name, err)
+	}
+	r = buffered
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive %s: %w", name, err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read archive %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg || !IsManifest(header.Name) {
+			continue
+		}
+		err = fn(archiveEntrySource(name, header.Name), tarReader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WalkZip calls fn for every YAML and JSON file inside a zip archive.
+// name describes the archive, entries are described as name!/entry.
+func WalkZip(r io.ReaderAt, size int64, name string, fn ManifestFunc) error {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read archive %s: %w", name, err)
+	}
+	for _, file := range zipReader.File {
+		if !file.Mode().IsRegular() || !IsManifest(file.Name) {
+			continue
+		}
+		source := archiveEntrySource(name, file.Name)
+		err := func() error {
+			entry, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", source, err)
+			}
+			defer entry.Close()
+			return fn(source, entry)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveEntrySource describes an entry inside an archive.
+func archiveEntrySource(archiveName string, entryName string) string {
+	return archiveName + archiveSeparator + strings.TrimPrefix(path.Clean("/"+entryName), "/")
+}
+
+// WalkFile calls fn for a file named name, or for every YAML and JSON file inside it when it is an archive (see [IsArchive]).
+func WalkFile(r io.Reader, name string, fn ManifestFunc) error {
+	if !IsArchive(name) {
+		return fn(name, r)
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".zip") {
+		return WalkTar(r, name, fn)
+	}
+	switch r := r.(type) {
+	case *bytes.Reader:
+		return WalkZip(r, r.Size(), name, fn)
+	case fs.File:
+		if readerAt, ok := r.(io.ReaderAt); ok {
+			info, err := r.Stat()
+			if err != nil {
+				return fmt.Errorf("failed to stat archive %s: %w", name, err)
+			}
+			return WalkZip(readerAt, info.Size(), name, fn)
+		}
+	}
+	// zip archives need random access so we buffer the ones that do not support it
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read archive %s: %w", name, err)
+	}
+	return WalkZip(bytes.NewReader(content), int64(len(content)), name, fn)
+}
@@ -0,0 +1,144 @@
+package images
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func archiveEntries(t *testing.T) map[string][]byte {
+	t.Helper()
+	deployment, err := os.ReadFile(filepath.Join("..", "..", "testdata", "deployment.yaml"))
+	require.NoError(t, err)
+	pod, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pod.yaml"))
+	require.NoError(t, err)
+	return map[string][]byte{
+		"./templates/deployment.yaml": deployment,
+		"templates/pod.yml":           pod,
+		"README.md":                   []byte("image: ignored:1.0\n"),
+	}
+}
+
+func tarArchive(t *testing.T, entries map[string][]byte, compress bool) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	var gzipWriter *gzip.Writer
+	tarWriter := tar.NewWriter(&buffer)
+	if compress {
+		gzipWriter = gzip.NewWriter(&buffer)
+		tarWriter = tar.NewWriter(gzipWriter)
+	}
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "templates/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for name, content := range entries {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	if compress {
+		require.NoError(t, gzipWriter.Close())
+	}
+	return buffer.Bytes()
+}
+
+func zipArchive(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for name, content := range entries {
+		writer, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = writer.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return buffer.Bytes()
+}
+
+var archiveImages = map[string]struct{}{
+	"example.com/processor:1.2.3": {},
+	"nginx:1.21.0":                {},
+	"busybox:1.35":                {},
+}
+
+func TestExtractFromTar(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	extractor := NewExtractor()
+	for _, compress := range []bool{false, true} {
+		images := make(map[string]struct{})
+		err := extractor.ExtractFromTar(ctx, bytes.NewReader(tarArchive(t, archiveEntries(t), compress)), "bundle.tar", images)
+		require.NoError(t, err)
+		require.Equal(t, archiveImages, images)
+	}
+}
+
+func TestExtractFromTarProvenance(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	entries := map[string][]byte{"templates/broken.yaml": []byte("apiVersion: v1\nkind: Podonkadonk\n")}
+	extractor := NewExtractor()
+	err := extractor.ExtractFromTar(ctx, bytes.NewReader(tarArchive(t, entries, true)), "bundle.tar.gz", map[string]struct{}{})
+	require.ErrorContains(t, err, "bundle.tar.gz!/templates/broken.yaml")
+}
+
+func TestExtractFromZip(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	archive := zipArchive(t, archiveEntries(t))
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err := extractor.ExtractFromZip(ctx, bytes.NewReader(archive), int64(len(archive)), "bundle.zip", images)
+	require.NoError(t, err)
+	require.Equal(t, archiveImages, images)
+}
+
+func TestExtractFromFSArchives(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	fsys := fstest.MapFS{
+		"bundle.tar.gz": &fstest.MapFile{Data: tarArchive(t, archiveEntries(t), true)},
+		"bundle.zip":    &fstest.MapFile{Data: zipArchive(t, map[string][]byte{"redis.yaml": []byte("apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: redis:7.0\n")})},
+	}
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err := extractor.ExtractFromFS(ctx, fsys, images)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{
+		"example.com/processor:1.2.3": {},
+		"nginx:1.21.0":                {},
+		"busybox:1.35":                {},
+		"redis:7.0":                   {},
+	}, images)
+}
+
+func TestIsArchive(t *testing.T) {
+	t.Parallel()
+	require.True(t, IsArchive("bundle.tar"))
+	require.True(t, IsArchive("chart-1.0.0.tgz"))
+	require.True(t, IsArchive("path/to/BUNDLE.TAR.GZ"))
+	require.True(t, IsArchive("bundle.zip"))
+	require.False(t, IsArchive("deployment.yaml"))
+	require.False(t, IsArchive("archive.gz"))
+}
+
+func TestWalkFile(t *testing.T) {
+	t.Parallel()
+	archive := zipArchive(t, map[string][]byte{"redis.yaml": []byte("kind: Pod\n"), "README.md": []byte("# not a manifest\n")})
+	var sources []string
+	fn := func(source string, _ io.Reader) error {
+		sources = append(sources, source)
+		return nil
+	}
+	require.NoError(t, WalkFile(bytes.NewReader(archive), "bundle.zip", fn))
+	require.NoError(t, WalkFile(bytes.NewReader([]byte("kind: Pod\n")), "rendered.txt", fn))
+	require.Equal(t, []string{"bundle.zip!/redis.yaml", "rendered.txt"}, sources)
+}
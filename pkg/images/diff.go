@@ -0,0 +1,92 @@
+package images
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Diff describes how a set of image references changed.
+type Diff struct {
+	// Added holds the references only present in the new set.
+	Added []string `json:"added"`
+	// Removed holds the references only present in the old set.
+	Removed []string `json:"removed"`
+	// Changed holds references whose repository is present in both sets with a different tag or digest.
+	Changed []Change `json:"changed"`
+}
+
+// Change pairs an old and a new reference to the same repository.
+type Change struct {
+	// Repository is the fully qualified repository name, e.g. docker.io/library/nginx.
+	Repository string `json:"repository"`
+	// From is the old reference.
+	From string `json:"from"`
+	// To is the new reference.
+	To string `json:"to"`
+}
+
+// Empty reports whether the sets were identical.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffImages compares two sets of image references.
+// References that are only in one of the sets are paired up by repository, so nginx:1.21 being replaced by nginx:1.25 is reported as a change rather than a removal and an addition.
+// When a repository has several references on both sides they are paired in sorted order and the rest are reported as removed or added.
+func DiffImages(oldImages map[string]struct{}, newImages map[string]struct{}) Diff {
+	removedByRepository := make(map[string][]string)
+	addedByRepository := make(map[string][]string)
+	diff := Diff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []Change{},
+	}
+	for image := range oldImages {
+		if _, ok := newImages[image]; !ok {
+			groupByRepository(image, removedByRepository, &diff.Removed)
+		}
+	}
+	for image := range newImages {
+		if _, ok := oldImages[image]; !ok {
+			groupByRepository(image, addedByRepository, &diff.Added)
+		}
+	}
+	for repository, removed := range removedByRepository {
+		added := addedByRepository[repository]
+		slices.Sort(removed)
+		slices.Sort(added)
+		pairs := min(len(removed), len(added))
+		for i := range pairs {
+			diff.Changed = append(diff.Changed, Change{
+				Repository: repository,
+				From:       removed[i],
+				To:         added[i],
+			})
+		}
+		diff.Removed = append(diff.Removed, removed[pairs:]...)
+		diff.Added = append(diff.Added, added[pairs:]...)
+		delete(addedByRepository, repository)
+	}
+	for _, added := range addedByRepository {
+		diff.Added = append(diff.Added, added...)
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b Change) int {
+		return cmp.Or(strings.Compare(a.Repository, b.Repository), strings.Compare(a.From, b.From))
+	})
+	return diff
+}
+
+// groupByRepository adds image to the list of its repository, images that are not valid references can't be paired and are added to ungrouped.
+func groupByRepository(image string, byRepository map[string][]string, ungrouped *[]string) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		*ungrouped = append(*ungrouped, image)
+		return
+	}
+	byRepository[ref.Name()] = append(byRepository[ref.Name()], image)
+}
@@ -0,0 +1,39 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffImages(t *testing.T) {
+	t.Parallel()
+	oldImages := map[string]struct{}{
+		"nginx:1.21":                {},
+		"docker.io/library/redis:7": {},
+		"memcached:1.6":             {},
+		"busybox:1.35":              {},
+		"quay.io/app/api:1.0":       {},
+		"quay.io/app/api:1.1":       {},
+		"{{ .Values.image }}":       {},
+	}
+	newImages := map[string]struct{}{
+		"nginx:1.25":          {},
+		"redis:7.2":           {},
+		"busybox:1.35":        {},
+		"quay.io/app/api:2.0": {},
+		"ghcr.io/app/web:1.0": {},
+	}
+	diff := DiffImages(oldImages, newImages)
+	require.Equal(t, Diff{
+		Added:   []string{"ghcr.io/app/web:1.0"},
+		Removed: []string{"memcached:1.6", "quay.io/app/api:1.1", "{{ .Values.image }}"},
+		Changed: []Change{
+			{Repository: "docker.io/library/nginx", From: "nginx:1.21", To: "nginx:1.25"},
+			{Repository: "docker.io/library/redis", From: "docker.io/library/redis:7", To: "redis:7.2"},
+			{Repository: "quay.io/app/api", From: "quay.io/app/api:1.0", To: "quay.io/app/api:2.0"},
+		},
+	}, diff)
+	require.False(t, diff.Empty())
+	require.True(t, DiffImages(newImages, newImages).Empty())
+}
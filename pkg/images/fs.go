@@ -0,0 +1,74 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ManifestFunc is called for every manifest file found while walking a file system or an archive.
+// source describes the file, e.g. templates/deploy.yaml or bundle.tar.gz!/templates/deploy.yaml for an archive entry.
+type ManifestFunc func(source string, r io.Reader) error
+
+// ExtractFromFS extracts image references from the manifests in fsys placing them in the images map as keys.
+// The files are found with [WalkFS].
+func (e *Extractor) ExtractFromFS(ctx context.Context, fsys fs.FS, images map[string]struct{}, patterns ...string) error {
+	return WalkFS(fsys, e.extractFromManifestFile(ctx, images), patterns...)
+}
+
+// extractFromManifestFile returns a [ManifestFunc] that extracts image references placing them in the images map as keys.
+func (e *Extractor) extractFromManifestFile(ctx context.Context, images map[string]struct{}) ManifestFunc {
+	return func(source string, r io.Reader) error {
+		e.Logger.InfoContext(ctx, "Processing file", "path", source)
+		err := e.ExtractFromManifests(ctx, r, images)
+		if err != nil {
+			return fmt.Errorf("failed to extract images from %s: %w", source, err)
+		}
+		return nil
+	}
+}
+
+// WalkFS calls fn for every manifest file in fsys.
+// Each pattern is matched with [fs.Glob] and every match is walked recursively, every regular file found is treated as a YAML stream
+// except for archives (see [IsArchive]) which are walked with [WalkFile] instead, only their YAML and JSON entries are read.
+// When no patterns are given the entire file system is walked.
+func WalkFS(fsys fs.FS, fn ManifestFunc, patterns ...string) error {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return fmt.Errorf("failed to match pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("failed to match pattern %s: %w", pattern, fs.ErrNotExist)
+		}
+		for _, match := range matches {
+			err := fs.WalkDir(fsys, match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.Type().IsRegular() {
+					return nil
+				}
+				return walkFile(fsys, path, fn)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to walk path %s: %w", match, err)
+			}
+		}
+	}
+	return nil
+}
+
+// walkFile calls fn for the file at path in fsys or for the manifest files inside it if it is an archive.
+func walkFile(fsys fs.FS, path string, fn ManifestFunc) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+	return WalkFile(file, path, fn)
+}
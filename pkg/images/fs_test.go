@@ -0,0 +1,88 @@
+package images
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractFromFS(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	fsys := os.DirFS(filepath.Join("..", "..", "testdata"))
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err := extractor.ExtractFromFS(ctx, fsys, images, "deployment.yaml", "pod.yaml")
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{
+		"example.com/processor:1.2.3": {},
+		"nginx:1.21.0":                {},
+		"busybox:1.35":                {},
+	}, images)
+}
+
+func TestExtractFromFSWalksDirectories(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	deployment, err := os.ReadFile(filepath.Join("..", "..", "testdata", "deployment.yaml"))
+	require.NoError(t, err)
+	multiObject, err := os.ReadFile(filepath.Join("..", "..", "testdata", "multi_object.yaml"))
+	require.NoError(t, err)
+	fsys := fstest.MapFS{
+		"apps/processor/deployment.yaml": &fstest.MapFile{Data: deployment},
+		"apps/multi_object.yaml":         &fstest.MapFile{Data: multiObject},
+		"other/ignored.yaml":             &fstest.MapFile{Data: []byte("not: a manifest")},
+	}
+
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err = extractor.ExtractFromFS(ctx, fsys, images, "apps")
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{
+		"example.com/processor:1.2.3": {},
+		"nginx:1.21.0":                {},
+		"redis:7.0":                   {},
+	}, images)
+
+	images = make(map[string]struct{})
+	err = extractor.ExtractFromFS(ctx, fsys, images, "apps/*/*.yaml")
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{
+		"example.com/processor:1.2.3": {},
+	}, images)
+
+	err = extractor.ExtractFromFS(ctx, fsys, images)
+	require.Error(t, err)
+}
+
+func TestExtractFromFSNoMatch(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err := extractor.ExtractFromFS(ctx, fstest.MapFS{}, images, "missing.yaml")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestWalkFSReadsEveryFileInDirectories(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"chart/templates/deployment.yaml": &fstest.MapFile{Data: []byte("kind: Deployment\n")},
+		"chart/templates/NOTES.txt":       &fstest.MapFile{Data: []byte("not a manifest\n")},
+		"chart/README.md":                 &fstest.MapFile{Data: []byte("# not a manifest\n")},
+		"rendered":                        &fstest.MapFile{Data: []byte("kind: Pod\n")},
+	}
+	var sources []string
+	err := WalkFS(fsys, func(source string, _ io.Reader) error {
+		sources = append(sources, source)
+		return nil
+	}, "chart", "rendered")
+	require.NoError(t, err)
+	// unlike in archives, files in directories are read whatever their extension
+	require.Equal(t, []string{"chart/README.md", "chart/templates/NOTES.txt", "chart/templates/deployment.yaml", "rendered"}, sources)
+}
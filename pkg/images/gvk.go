@@ -11,3 +11,15 @@ type UnknownGVKError struct {
 func (e *UnknownGVKError) Error() string {
 	return fmt.Sprintf("failed to detect Group Version Kind: %s, manifest: %+v", e.GVK, e.Manifest)
 }
+
+// UnknownGVK is a manifest with an unknown GVK and where it was found.
+type UnknownGVK struct {
+	GVK    string `json:"gvk"`
+	Object Object `json:"object"`
+	// Source describes where the manifest was read from, e.g. a file path.
+	Source string `json:"source,omitempty"`
+	// Line is the 1-based line of the manifest's kind field in the source, it is 0 when unknown.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column of the manifest's kind field in the source, it is 0 when unknown.
+	Column int `json:"column,omitempty"`
+}
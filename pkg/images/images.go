@@ -3,10 +3,10 @@ package images
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"strings"
 
 	"github.com/goccy/go-yaml"
@@ -32,7 +32,12 @@ type Extractor struct {
 	// GVKMappings maps custom GVK strings to their corresponding extraction functions. You can add custom GVKs here.
 	// The key is the GVK string in the format "apiVersion.kind", e.g. "apps/v1.Deployment".
 	// The value is a function that takes a manifest and an output map, and extracts image references from the manifest.
+	// When occurrences are extracted or images rewritten, an image is tied to the string fields whose value is exactly the
+	// image, e.g. an image built from a repository and a tag field is reported without a field and can't be rewritten.
 	GVKMappings map[string]func(map[string]any, map[string]struct{}) error
+	// UnknownGVKFound is called for every manifest with an unknown GVK that extracting occurrences skips or reads as free text.
+	// It is optional, findings are only located when extracting occurrences.
+	UnknownGVKFound func(UnknownGVK)
 }
 
 // NewExtractor creates a new Extractor with the provided options.
@@ -45,6 +50,38 @@ func NewExtractor() *Extractor {
 
 // ExtractFromManifests extracts image references from a YAML stream placing them in the images map as keys.
 func (e *Extractor) ExtractFromManifests(ctx context.Context, r io.Reader, images map[string]struct{}) error {
+	return e.extractFromManifests(ctx, r, func(_ map[string]any, manifestImages map[string]struct{}) {
+		maps.Copy(images, manifestImages)
+	})
+}
+
+// ExtractFromManifest extracts image references from a single decoded manifest placing them in the images map as keys.
+// Unlike [Extractor.ExtractFromManifests] it does not apply the UnknownGVKBehavior, an [*UnknownGVKError] is returned for unknown GVKs.
+func (e *Extractor) ExtractFromManifest(manifest map[string]any, images map[string]struct{}) error {
+	return fromManifest(manifest, images, e.GVKMappings)
+}
+
+// ExtractOccurrencesFromManifests extracts image references from a YAML stream along with the objects and the fields they were found in.
+// source describes where the stream was read from, e.g. a file path, and is copied to every occurrence.
+// Occurrences are sorted by their position in the stream.
+func (e *Extractor) ExtractOccurrencesFromManifests(ctx context.Context, r io.Reader, source string) ([]Occurrence, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	locatedOccurrences, err := e.locate(ctx, content, source)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := make([]Occurrence, 0, len(locatedOccurrences))
+	for _, occurrence := range locatedOccurrences {
+		occurrences = append(occurrences, occurrence.Occurrence)
+	}
+	return occurrences, nil
+}
+
+// extractFromManifests decodes every manifest in a YAML stream and calls fn with it and the images extracted from it.
+func (e *Extractor) extractFromManifests(ctx context.Context, r io.Reader, fn func(manifest map[string]any, manifestImages map[string]struct{})) error {
 	var bufferForYAML bytes.Buffer
 	var entireInput string
 	if e.UnknownGVKBehavior == UnknownGVKFreeText {
@@ -65,28 +102,18 @@ func (e *Extractor) ExtractFromManifests(ctx context.Context, r io.Reader, image
 			}
 			return fmt.Errorf("failed to decode manifest: %w", err)
 		}
-		err := fromManifest(manifest, images, e.GVKMappings)
+		manifestImages := make(map[string]struct{})
+		err := fromManifest(manifest, manifestImages, e.GVKMappings)
 		if err != nil {
-			if unknownGVKError, ok := errors.AsType[*UnknownGVKError](err); ok {
-				switch e.UnknownGVKBehavior {
-				case UnknownGVKFail:
-					return fmt.Errorf("failed to extract images from manifest: %w", err)
-				case UnknownGVKSkip:
-					e.Logger.WarnContext(ctx, "Skipping unknown GVK", "group-version-kind", unknownGVKError.GVK, "manifest", unknownGVKError.Manifest)
-					continue
-				case UnknownGVKFreeText:
-					e.Logger.WarnContext(ctx, "Unknown GVK, extracting images as free text from the input", "group-version-kind", unknownGVKError.GVK, "manifest", unknownGVKError.Manifest)
-					err := extractImagesFromFreeText(entireInput, images)
-					if err != nil {
-						return fmt.Errorf("failed to extract images from free text: %w", err)
-					}
-					continue
-				default:
-					panic("unhandled UnknownGVKBehavior")
-				}
+			skip, err := e.handleExtractionError(ctx, err, entireInput, manifestImages)
+			if err != nil {
+				return err
+			}
+			if skip {
+				continue
 			}
-			return fmt.Errorf("failed to extract images from manifest: %w", err)
 		}
+		fn(manifest, manifestImages)
 	}
 	return nil
 }
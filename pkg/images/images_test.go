@@ -1,6 +1,7 @@
 package images
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -103,3 +104,74 @@ func TestFromManifestsMultipleObjects(t *testing.T) {
 	require.Contains(t, images, "nginx:1.21.0")
 	require.Contains(t, images, "redis:7.0")
 }
+
+func TestExtractOccurrencesFromManifests(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	file, err := os.Open(filepath.Join("..", "..", "testdata", "multi_object.yaml"))
+	require.NoError(t, err)
+	defer file.Close()
+	extractor := NewExtractor()
+	occurrences, err := extractor.ExtractOccurrencesFromManifests(ctx, file, "multi_object.yaml")
+	require.NoError(t, err)
+	require.Equal(t, []Occurrence{
+		{
+			Image:  "nginx:1.21.0",
+			Object: Object{APIVersion: "v1", Kind: "Pod", Name: "first-pod"},
+			Source: "multi_object.yaml",
+			Path:   "$.spec.containers[0].image",
+			Line:   8,
+			Column: 14,
+		},
+		{
+			Image:  "redis:7.0",
+			Object: Object{APIVersion: "apps/v1", Kind: "Deployment", Name: "my-deployment"},
+			Source: "multi_object.yaml",
+			Path:   "$.spec.template.spec.containers[0].image",
+			Line:   28,
+			Column: 18,
+		},
+	}, occurrences)
+}
+
+func TestExtractFromManifest(t *testing.T) {
+	t.Parallel()
+	images := make(map[string]struct{})
+	extractor := NewExtractor()
+	err := extractor.ExtractFromManifest(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"spec": map[string]any{
+			"containers": []any{map[string]any{"image": nginxLatest}},
+		},
+	}, images)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{nginxLatest: {}}, images)
+
+	err = extractor.ExtractFromManifest(map[string]any{"apiVersion": "v1", "kind": "Podonkadonk"}, images)
+	_, ok := errors.AsType[*UnknownGVKError](err)
+	require.True(t, ok)
+}
+
+func TestExtractOccurrencesFromManifestsUnknownGVKFound(t *testing.T) {
+	t.Parallel()
+	file, err := os.Open(filepath.Join("..", "..", "testdata", "unknown_gvk.yaml"))
+	require.NoError(t, err)
+	defer file.Close()
+	var found []UnknownGVK
+	extractor := NewExtractor()
+	extractor.UnknownGVKBehavior = UnknownGVKSkip
+	extractor.UnknownGVKFound = func(unknownGVK UnknownGVK) {
+		found = append(found, unknownGVK)
+	}
+	occurrences, err := extractor.ExtractOccurrencesFromManifests(t.Context(), file, "unknown_gvk.yaml")
+	require.NoError(t, err)
+	require.Empty(t, occurrences)
+	require.Equal(t, []UnknownGVK{{
+		GVK:    "v1.Podonkadonk",
+		Object: Object{APIVersion: "v1", Kind: "Podonkadonk", Name: "test-pod"},
+		Source: "unknown_gvk.yaml",
+		Line:   2,
+		Column: 7,
+	}}, found)
+}
@@ -0,0 +1,294 @@
+package images
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// tokenPrefix marks the strings of a manifest replaced by tokenize. It can't appear in a parsed YAML string.
+const tokenPrefix = "\x00skim:"
+
+// located is an occurrence along with the YAML node it was read from, node is nil when the image can't be tied to a single node.
+type located struct {
+	Occurrence
+	node *ast.StringNode
+}
+
+// fieldPath is the path of a string field in a manifest, its steps are map keys (string) and sequence indexes (int).
+type fieldPath struct {
+	steps []any
+	value string
+}
+
+func (p fieldPath) String() string {
+	builder := (&yaml.PathBuilder{}).Root()
+	for _, step := range p.steps {
+		switch step := step.(type) {
+		case string:
+			builder = builder.Child(step)
+		case int:
+			builder = builder.Index(uint(step))
+		}
+	}
+	return builder.Build().String()
+}
+
+// locate extracts image references from a YAML stream along with the objects and the fields they were found in.
+//
+// The GVK-aware extraction functions only see decoded manifests so every string in a manifest is first replaced by a token
+// naming its field, the tokens the functions return tell which fields hold images. Custom mappings (see
+// [Extractor.GVKMappings]) may build images from several fields or inspect their values, so they are also given the
+// manifest as it is and their tokens are only trusted when they lead to the same images, otherwise the images are tied
+// to the fields holding exactly their value.
+func (e *Extractor) locate(ctx context.Context, content []byte, source string) ([]located, error) {
+	// the parser miscounts lines of CRLF files, line breaks are never part of an image so they can be normalized
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	file, err := parser.ParseBytes(normalized, 0, parser.AllowDuplicateMapKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	var result []located
+	for _, doc := range file.Docs {
+		if doc.Body == nil {
+			continue
+		}
+		if _, ok := doc.Body.(*ast.NullNode); ok {
+			continue
+		}
+		var manifest map[string]any
+		err := yaml.NodeToValue(doc.Body, &manifest, yaml.AllowDuplicateMapKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+		if manifest == nil {
+			continue
+		}
+		var paths []fieldPath
+		tokenized := tokenize(manifest, nil, &paths).(map[string]any)
+		manifestImages := make(map[string]struct{})
+		byValue := false
+		err = fromManifest(tokenized, manifestImages, e.GVKMappings)
+		if e.hasCustomMapping(manifest) {
+			values := make(map[string]struct{})
+			valuesErr := fromManifest(manifest, values, e.GVKMappings)
+			if err != nil || valuesErr != nil || !maps.Equal(resolveTokens(manifestImages, paths), values) {
+				manifestImages, err, byValue = values, valuesErr, true
+			}
+		} else if err != nil {
+			// extract again from the original manifest so the error doesn't show tokens
+			clear(manifestImages)
+			err = fromManifest(manifest, manifestImages, e.GVKMappings)
+		}
+		if err != nil {
+			unknownGVKError, _ := errors.AsType[*UnknownGVKError](err)
+			skip, err := e.handleExtractionError(ctx, err, string(content), manifestImages)
+			if err != nil {
+				return nil, err
+			}
+			if unknownGVKError != nil && e.UnknownGVKFound != nil {
+				unknownGVK := UnknownGVK{GVK: unknownGVKError.GVK, Object: objectFromManifest(manifest), Source: source}
+				if node := nodeAt(doc.Body, []any{"kind"}); node != nil {
+					unknownGVK.Line = node.GetToken().Position.Line
+					unknownGVK.Column = node.GetToken().Position.Column
+				}
+				e.UnknownGVKFound(unknownGVK)
+			}
+			if skip {
+				continue
+			}
+		}
+		object := objectFromManifest(manifest)
+		labels := labelsFromManifest(manifest)
+		for image := range manifestImages {
+			occurrence := located{
+				Occurrence: Occurrence{
+					Image:  image,
+					Object: object,
+					Labels: labels,
+					Source: source,
+				},
+			}
+			if index, ok := strings.CutPrefix(image, tokenPrefix); ok {
+				i, err := strconv.Atoi(index)
+				if err != nil || i >= len(paths) {
+					return nil, fmt.Errorf("failed to resolve image field token %q", image)
+				}
+				result = append(result, occurrence.at(doc.Body, paths[i]))
+				continue
+			}
+			found := false
+			if byValue {
+				for _, path := range paths {
+					if path.value == image {
+						result = append(result, occurrence.at(doc.Body, path))
+						found = true
+					}
+				}
+			}
+			if !found {
+				result = append(result, occurrence)
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b located) int {
+		return cmp.Or(
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+			strings.Compare(a.Image, b.Image),
+		)
+	})
+	return result, nil
+}
+
+// at ties an occurrence to the field at path.
+func (l located) at(body ast.Node, path fieldPath) located {
+	l.Image = path.value
+	l.Path = path.String()
+	if node, ok := nodeAt(body, path.steps).(*ast.StringNode); ok {
+		l.node = node
+		l.Line = node.GetToken().Position.Line
+		l.Column = node.GetToken().Position.Column
+	}
+	return l
+}
+
+// resolveTokens returns the values of the fields the tokens among images name, images that aren't tokens are kept.
+func resolveTokens(images map[string]struct{}, paths []fieldPath) map[string]struct{} {
+	resolved := make(map[string]struct{}, len(images))
+	for image := range images {
+		if index, ok := strings.CutPrefix(image, tokenPrefix); ok {
+			if i, err := strconv.Atoi(index); err == nil && i < len(paths) {
+				image = paths[i].value
+			}
+		}
+		resolved[image] = struct{}{}
+	}
+	return resolved
+}
+
+// hasCustomMapping reports whether the GVK of a manifest is extracted by a function of [Extractor.GVKMappings].
+func (e *Extractor) hasCustomMapping(manifest map[string]any) bool {
+	apiVersion, _ := manifest["apiVersion"].(string)
+	kind, _ := manifest["kind"].(string)
+	_, ok := e.GVKMappings[apiVersion+"."+kind]
+	return ok
+}
+
+// handleExtractionError applies the UnknownGVKBehavior to an error returned by fromManifest.
+// It returns whether the manifest should be skipped, or an error if extraction should stop.
+func (e *Extractor) handleExtractionError(ctx context.Context, err error, entireInput string, manifestImages map[string]struct{}) (bool, error) {
+	unknownGVKError, ok := errors.AsType[*UnknownGVKError](err)
+	if !ok {
+		return false, fmt.Errorf("failed to extract images from manifest: %w", err)
+	}
+	switch e.UnknownGVKBehavior {
+	case UnknownGVKFail:
+		return false, fmt.Errorf("failed to extract images from manifest: %w", err)
+	case UnknownGVKSkip:
+		e.Logger.WarnContext(ctx, "Skipping unknown GVK", "group-version-kind", unknownGVKError.GVK, "manifest", unknownGVKError.Manifest)
+		return true, nil
+	case UnknownGVKFreeText:
+		e.Logger.WarnContext(ctx, "Unknown GVK, extracting images as free text from the input", "group-version-kind", unknownGVKError.GVK, "manifest", unknownGVKError.Manifest)
+		err := extractImagesFromFreeText(entireInput, manifestImages)
+		if err != nil {
+			return false, fmt.Errorf("failed to extract images from free text: %w", err)
+		}
+		return false, nil
+	default:
+		panic("unhandled UnknownGVKBehavior")
+	}
+}
+
+// tokenize returns a copy of value where every string is replaced by a token indexing the paths slice.
+// apiVersion and kind fields are kept as they are because extraction functions dispatch on them.
+func tokenize(value any, steps []any, paths *[]fieldPath) any {
+	switch value := value.(type) {
+	case map[string]any:
+		tokenized := make(map[string]any, len(value))
+		for key, child := range value {
+			if _, ok := child.(string); ok && (key == "apiVersion" || key == "kind") {
+				tokenized[key] = child
+				continue
+			}
+			tokenized[key] = tokenize(child, append(slices.Clip(steps), key), paths)
+		}
+		return tokenized
+	case []any:
+		tokenized := make([]any, len(value))
+		for i, child := range value {
+			tokenized[i] = tokenize(child, append(slices.Clip(steps), i), paths)
+		}
+		return tokenized
+	case string:
+		*paths = append(*paths, fieldPath{steps: steps, value: value})
+		return tokenPrefix + strconv.Itoa(len(*paths)-1)
+	default:
+		return value
+	}
+}
+
+// nodeAt returns the node at the given path or nil if it can't be found, e.g. because it is behind an alias.
+func nodeAt(node ast.Node, steps []any) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+			continue
+		case *ast.TagNode:
+			node = n.Value
+			continue
+		}
+		break
+	}
+	if len(steps) == 0 {
+		return node
+	}
+	switch step := steps[0].(type) {
+	case string:
+		var values []*ast.MappingValueNode
+		switch n := node.(type) {
+		case *ast.MappingNode:
+			values = n.Values
+		case *ast.MappingValueNode:
+			values = []*ast.MappingValueNode{n}
+		default:
+			return nil
+		}
+		// later keys override earlier duplicates like they do when decoding
+		for _, value := range slices.Backward(values) {
+			if mapKeyString(value.Key) == step {
+				return nodeAt(value.Value, steps[1:])
+			}
+		}
+	case int:
+		sequence, ok := node.(*ast.SequenceNode)
+		if !ok || step >= len(sequence.Values) {
+			return nil
+		}
+		return nodeAt(sequence.Values[step], steps[1:])
+	}
+	return nil
+}
+
+func mapKeyString(key ast.MapKeyNode) string {
+	switch key := key.(type) {
+	case *ast.StringNode:
+		return key.Value
+	case *ast.MappingKeyNode:
+		if value, ok := key.Value.(*ast.StringNode); ok {
+			return value.Value
+		}
+	}
+	return key.GetToken().Value
+}
@@ -0,0 +1,64 @@
+package images
+
+// Object identifies the Kubernetes object an image reference was found in.
+type Object struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// String returns the object in the form kind/name or kind/namespace/name.
+func (o Object) String() string {
+	if o.Namespace == "" {
+		return o.Kind + "/" + o.Name
+	}
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+// Occurrence is an image reference found in a Kubernetes object.
+type Occurrence struct {
+	// Image is the image reference as written in the manifest.
+	Image string `json:"image"`
+	// Object is the object the image reference was found in.
+	Object Object `json:"object"`
+	// Labels are the labels of the object.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Source describes where the manifest was read from, e.g. a file path.
+	Source string `json:"source,omitempty"`
+	// Path is the YAML path of the field holding the image reference, e.g. $.spec.containers[0].image.
+	// It is empty when the image was found as free text.
+	Path string `json:"path,omitempty"`
+	// Line is the 1-based line of the image reference in the source, it is 0 when unknown.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column of the image reference in the source, it is 0 when unknown.
+	Column int `json:"column,omitempty"`
+}
+
+// objectFromManifest reads the identifying fields of a manifest, missing fields are left empty.
+func objectFromManifest(manifest map[string]any) Object {
+	var object Object
+	object.APIVersion, _ = manifest["apiVersion"].(string)
+	object.Kind, _ = manifest["kind"].(string)
+	if metadata, ok := manifest["metadata"].(map[string]any); ok {
+		object.Namespace, _ = metadata["namespace"].(string)
+		object.Name, _ = metadata["name"].(string)
+	}
+	return object
+}
+
+// labelsFromManifest reads the labels of a manifest, labels that aren't strings are left out.
+func labelsFromManifest(manifest map[string]any) map[string]string {
+	metadata, _ := manifest["metadata"].(map[string]any)
+	labels, _ := metadata["labels"].(map[string]any)
+	if len(labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if value, ok := value.(string); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
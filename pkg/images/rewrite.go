@@ -0,0 +1,116 @@
+package images
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml/token"
+)
+
+// RewriteFunc returns the new image reference for an occurrence, returning the current reference leaves it untouched.
+type RewriteFunc func(occurrence Occurrence) (string, error)
+
+// RewriteManifests rewrites the image references in a YAML stream and returns the new stream.
+// Only the fields the extraction functions read images from are changed and only the bytes of the references themselves,
+// so comments, key order, quoting and formatting are kept as they are.
+// Images found as free text (see [UnknownGVKFreeText]) or behind YAML aliases can't be tied to a single field and are left untouched.
+func (e *Extractor) RewriteManifests(ctx context.Context, content []byte, source string, fn RewriteFunc) ([]byte, error) {
+	occurrences, err := e.locate(ctx, content, source)
+	if err != nil {
+		return nil, err
+	}
+	type edit struct {
+		line  int
+		start int
+		end   int
+		text  string
+	}
+	var edits []edit
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	for _, occurrence := range occurrences {
+		image, err := fn(occurrence.Occurrence)
+		if err != nil {
+			return nil, err
+		}
+		if image == occurrence.Image {
+			continue
+		}
+		if occurrence.node == nil {
+			e.Logger.WarnContext(ctx, "Can't rewrite image, it is not tied to a single field", "image", occurrence.Image, "path", source)
+			continue
+		}
+		tokenType := occurrence.node.GetToken().Type
+		line := occurrence.Line - 1
+		if line < 0 || line >= len(lines) {
+			return nil, fmt.Errorf("failed to find image %s in %s at line %d", occurrence.Image, source, occurrence.Line)
+		}
+		start := runeOffset(lines[line], occurrence.Column-1)
+		current := QuoteScalar(occurrence.Image, tokenType)
+		if start < 0 || !bytes.HasPrefix(lines[line][start:], []byte(current)) {
+			return nil, fmt.Errorf("failed to find image %s in %s at line %d column %d", occurrence.Image, source, occurrence.Line, occurrence.Column)
+		}
+		if tokenType == token.StringType && needsQuoting(image) {
+			tokenType = token.DoubleQuoteType
+		}
+		edits = append(edits, edit{
+			line:  line,
+			start: start,
+			end:   start + len(current),
+			text:  QuoteScalar(image, tokenType),
+		})
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+	// apply the edits from the end of each line so earlier offsets stay valid
+	slices.SortFunc(edits, func(a, b edit) int {
+		return cmp.Or(cmp.Compare(a.line, b.line), cmp.Compare(b.start, a.start))
+	})
+	for _, edit := range edits {
+		current := lines[edit.line]
+		lines[edit.line] = slices.Concat(current[:edit.start], []byte(edit.text), current[edit.end:])
+	}
+	return bytes.Join(lines, nil), nil
+}
+
+// runeOffset returns the byte offset of the n-th rune of line or -1 if the line is shorter.
+func runeOffset(line []byte, n int) int {
+	offset := 0
+	for range n {
+		if offset >= len(line) {
+			return -1
+		}
+		_, size := utf8.DecodeRune(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// QuoteScalar renders a string as a YAML scalar in the given style.
+func QuoteScalar(s string, tokenType token.Type) string {
+	switch tokenType {
+	case token.SingleQuoteType:
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case token.DoubleQuoteType:
+		return strconv.Quote(s)
+	default:
+		return s
+	}
+}
+
+// needsQuoting reports whether s can't be written as a plain YAML scalar.
+func needsQuoting(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "!&*{}[]|>'\"%@`#,?-:") {
+		return true
+	}
+	return strings.ContainsAny(s, ",[]{}\n") || strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":")
+}
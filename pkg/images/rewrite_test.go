@@ -0,0 +1,120 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteManifests(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	content, err := os.ReadFile(filepath.Join("..", "..", "testdata", "rewrite.yaml"))
+	require.NoError(t, err)
+	replacements := map[string]string{
+		"nginx:1.21":   "mirror.example.com/nginx:1.21",
+		"busybox:1.35": "mirror.example.com/busybox:1.35",
+		"quay.io/prometheus/node-exporter:v1.6.0": "mirror.example.com/node-exporter:v1.6.0",
+	}
+	var rewritten []Occurrence
+	extractor := NewExtractor()
+	result, err := extractor.RewriteManifests(ctx, content, "rewrite.yaml", func(occurrence Occurrence) (string, error) {
+		rewritten = append(rewritten, occurrence)
+		return replacements[occurrence.Image], nil
+	})
+	require.NoError(t, err)
+	expected := strings.NewReplacer(
+		"image: 'busybox:1.35'", "image: 'mirror.example.com/busybox:1.35'",
+		`image: "nginx:1.21"`, `image: "mirror.example.com/nginx:1.21"`,
+		"image: quay.io/prometheus/node-exporter:v1.6.0", "image: mirror.example.com/node-exporter:v1.6.0",
+	).Replace(string(content))
+	require.Equal(t, expected, string(result))
+	require.Len(t, rewritten, 3)
+	require.Equal(t, "$.spec.template.spec.initContainers[0].image", rewritten[0].Path)
+	require.Equal(t, 12, rewritten[0].Line)
+}
+
+func TestRewriteManifestsCRLF(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	content := "# comment\r\napiVersion: v1\r\nkind: Pod\r\nmetadata:\r\n  name: é\r\nspec:\r\n  containers:\r\n    - name: app\r\n      image: nginx:1.21 # é\r\n"
+	extractor := NewExtractor()
+	result, err := extractor.RewriteManifests(ctx, []byte(content), "pod.yaml", func(Occurrence) (string, error) {
+		return "nginx:1.25", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, strings.Replace(content, "nginx:1.21", "nginx:1.25", 1), string(result))
+}
+
+func TestRewriteManifestsQuotesWhenNeeded(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	content := "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: nginx\n"
+	extractor := NewExtractor()
+	result, err := extractor.RewriteManifests(ctx, []byte(content), "pod.yaml", func(Occurrence) (string, error) {
+		return "@weird", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: \"@weird\"\n", string(result))
+}
+
+func TestRewriteManifestsUnchanged(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	content, err := os.ReadFile(filepath.Join("..", "..", "testdata", "rewrite.yaml"))
+	require.NoError(t, err)
+	extractor := NewExtractor()
+	result, err := extractor.RewriteManifests(ctx, content, "rewrite.yaml", func(occurrence Occurrence) (string, error) {
+		return occurrence.Image, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, content, result)
+}
+
+func TestRewriteManifestsCustomMapping(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+	content := []byte(`apiVersion: example.com/v1
+kind: App
+spec:
+  image: nginx:1.25
+  sidecar:
+    repository: redis
+    tag: "7.2"
+`)
+	extractor := NewExtractor()
+	extractor.GVKMappings = map[string]func(map[string]any, map[string]struct{}) error{
+		"example.com/v1.App": func(manifest map[string]any, output map[string]struct{}) error {
+			spec := manifest["spec"].(map[string]any)
+			image := spec["image"].(string)
+			// custom mappings see the values of the fields, not tokens
+			if !strings.Contains(image, ":") {
+				return fmt.Errorf("image %s has no tag", image)
+			}
+			output[image] = struct{}{}
+			sidecar := spec["sidecar"].(map[string]any)
+			output[sidecar["repository"].(string)+":"+sidecar["tag"].(string)] = struct{}{}
+			return nil
+		},
+	}
+	occurrences, err := extractor.ExtractOccurrencesFromManifests(ctx, bytes.NewReader(content), "app.yaml")
+	require.NoError(t, err)
+	require.Len(t, occurrences, 2)
+	// an image built from several fields isn't tied to any of them
+	require.Equal(t, "redis:7.2", occurrences[0].Image)
+	require.Empty(t, occurrences[0].Path)
+	require.Equal(t, "nginx:1.25", occurrences[1].Image)
+	require.Equal(t, "$.spec.image", occurrences[1].Path)
+	require.Equal(t, 4, occurrences[1].Line)
+
+	result, err := extractor.RewriteManifests(ctx, content, "app.yaml", func(occurrence Occurrence) (string, error) {
+		return "mirror.example.com/" + occurrence.Image, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, strings.Replace(string(content), "image: nginx:1.25", "image: mirror.example.com/nginx:1.25", 1), string(result))
+}
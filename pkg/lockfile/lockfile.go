@@ -0,0 +1,119 @@
+// Package lockfile reads and writes image lockfiles, deterministic records of the images a set of manifests references.
+package lockfile
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Version is the version of the lockfile format written by this package.
+const Version = 1
+
+// header is written at the top of every lockfile.
+const header = "# This file is generated by skim lock, do not edit it by hand.\n"
+
+// Lockfile records the images a set of manifests references.
+type Lockfile struct {
+	// Version is the version of the lockfile format.
+	Version int `yaml:"version"`
+	// Images are sorted by image.
+	Images []Image `yaml:"images"`
+}
+
+// Image is a single locked image.
+type Image struct {
+	// Image is the normalized image reference without its digest, e.g. docker.io/library/nginx:1.25.
+	// References that can't be parsed are recorded as written in the manifests.
+	Image string `yaml:"image"`
+	// Digest is the digest of the image, it is only recorded when it is known.
+	Digest string `yaml:"digest,omitempty"`
+	// Objects are the objects referencing the image sorted by kind, namespace and name.
+	Objects []images.Object `yaml:"objects"`
+}
+
+// Reference returns the locked reference including the digest if there is one.
+func (i Image) Reference() string {
+	if i.Digest == "" {
+		return i.Image
+	}
+	return i.Image + "@" + i.Digest
+}
+
+// New creates a lockfile from image occurrences.
+func New(occurrences []images.Occurrence) *Lockfile {
+	byReference := make(map[string]*Image)
+	for _, occurrence := range occurrences {
+		image := Image{Image: occurrence.Image}
+		if ref, err := reference.Parse(occurrence.Image); err == nil {
+			image.Digest = ref.Digest
+			ref.Digest = ""
+			image.Image = ref.String()
+		}
+		locked, ok := byReference[image.Reference()]
+		if !ok {
+			locked = &image
+			byReference[image.Reference()] = locked
+		}
+		if !slices.Contains(locked.Objects, occurrence.Object) {
+			locked.Objects = append(locked.Objects, occurrence.Object)
+		}
+	}
+	lockfile := &Lockfile{
+		Version: Version,
+		Images:  make([]Image, 0, len(byReference)),
+	}
+	for _, image := range byReference {
+		slices.SortFunc(image.Objects, compareObjects)
+		lockfile.Images = append(lockfile.Images, *image)
+	}
+	slices.SortFunc(lockfile.Images, func(a, b Image) int {
+		return cmp.Or(strings.Compare(a.Image, b.Image), strings.Compare(a.Digest, b.Digest))
+	})
+	return lockfile
+}
+
+func compareObjects(a, b images.Object) int {
+	return cmp.Or(
+		strings.Compare(a.Kind, b.Kind),
+		strings.Compare(a.Namespace, b.Namespace),
+		strings.Compare(a.Name, b.Name),
+		strings.Compare(a.APIVersion, b.APIVersion),
+	)
+}
+
+// Marshal encodes the lockfile, the output only depends on the locked images so it can be committed and compared.
+func (l *Lockfile) Marshal() ([]byte, error) {
+	content, err := yaml.MarshalWithOptions(l, yaml.IndentSequence(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+	return append([]byte(header), content...), nil
+}
+
+// Parse decodes a lockfile.
+func Parse(data []byte) (*Lockfile, error) {
+	var lockfile Lockfile
+	err := yaml.Unmarshal(data, &lockfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
+	}
+	if lockfile.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version: %d", lockfile.Version)
+	}
+	return &lockfile, nil
+}
+
+// References returns the set of locked references including their digests.
+func (l *Lockfile) References() map[string]struct{} {
+	references := make(map[string]struct{}, len(l.Images))
+	for _, image := range l.Images {
+		references[image.Reference()] = struct{}{}
+	}
+	return references
+}
@@ -0,0 +1,72 @@
+package lockfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	web := images.Object{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	worker := images.Object{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "worker"}
+	job := images.Object{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}
+	lockfile := New([]images.Occurrence{
+		{Image: "nginx:1.25", Object: worker},
+		{Image: "docker.io/library/nginx:1.25", Object: web},
+		{Image: "nginx:1.25", Object: web},
+		{Image: "quay.io/app/migrate:1.0@" + testDigest, Object: job},
+		{Image: "{{ .Values.image }}", Object: job},
+	})
+	require.Equal(t, &Lockfile{
+		Version: Version,
+		Images: []Image{
+			{Image: "docker.io/library/nginx:1.25", Objects: []images.Object{web, worker}},
+			{Image: "quay.io/app/migrate:1.0", Digest: testDigest, Objects: []images.Object{job}},
+			{Image: "{{ .Values.image }}", Objects: []images.Object{job}},
+		},
+	}, lockfile)
+	require.Equal(t, map[string]struct{}{
+		"docker.io/library/nginx:1.25":          {},
+		"quay.io/app/migrate:1.0@" + testDigest: {},
+		"{{ .Values.image }}":                   {},
+	}, lockfile.References())
+}
+
+func TestMarshalParse(t *testing.T) {
+	t.Parallel()
+	lockfile := New([]images.Occurrence{
+		{Image: "redis:7.2", Object: images.Object{APIVersion: "v1", Kind: "Pod", Namespace: "cache", Name: "redis"}},
+		{Image: "nginx@" + testDigest, Object: images.Object{APIVersion: "v1", Kind: "Pod", Name: "web"}},
+	})
+	content, err := lockfile.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, `# This file is generated by skim lock, do not edit it by hand.
+version: 1
+images:
+  - image: docker.io/library/nginx
+    digest: `+testDigest+`
+    objects:
+      - apiVersion: v1
+        kind: Pod
+        name: web
+  - image: docker.io/library/redis:7.2
+    objects:
+      - apiVersion: v1
+        kind: Pod
+        namespace: cache
+        name: redis
+`, string(content))
+	parsed, err := Parse(content)
+	require.NoError(t, err)
+	require.Equal(t, lockfile, parsed)
+}
+
+func TestParseUnsupportedVersion(t *testing.T) {
+	t.Parallel()
+	_, err := Parse([]byte("version: 2\nimages: []\n"))
+	require.ErrorContains(t, err, "unsupported lockfile version")
+}
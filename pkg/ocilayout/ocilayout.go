@@ -0,0 +1,308 @@
+// Package ocilayout writes and reads OCI image layouts, as directories and as tarballs,
+// see https://github.com/opencontainers/image-spec/blob/main/image-layout.md. It also reads docker archives.
+package ocilayout
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+const (
+	// AnnotationRefName is the annotation of index entries naming their image, skim sets it to the full image reference.
+	AnnotationRefName = "org.opencontainers.image.ref.name"
+	// AnnotationImageName is the annotation containerd names imported images with, e.g. with ctr images import.
+	AnnotationImageName = "io.containerd.image.name"
+
+	layoutFile         = "oci-layout"
+	indexFile          = "index.json"
+	dockerManifestFile = "manifest.json"
+	layoutVersion      = "1.0.0"
+)
+
+// maxIndexSize limits the size of the index of layouts that are read.
+const maxIndexSize = 64 << 20
+
+// Writer writes an OCI image layout tarball. Blobs are written as they are added, the index when the writer is closed.
+type Writer struct {
+	tw    *tar.Writer
+	index registry.Manifest
+	blobs map[string]bool
+}
+
+// NewWriter returns a writer writing a tarball to w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{
+		tw:    tar.NewWriter(w),
+		index: registry.Manifest{SchemaVersion: 2, MediaType: registry.MediaTypeOCIIndex, Manifests: []registry.Descriptor{}},
+		blobs: make(map[string]bool),
+	}
+	content, err := json.Marshal(map[string]string{"imageLayoutVersion": layoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	return writer, writer.writeFile(layoutFile, content)
+}
+
+// HasBlob reports whether a blob was written.
+func (w *Writer) HasBlob(digest string) bool {
+	return w.blobs[digest]
+}
+
+// WriteBlob writes a blob of size bytes read from content, e.g. a manifest or a layer. Blobs that were written are skipped.
+// The content must have digest, the tarball is unusable otherwise.
+func (w *Writer) WriteBlob(digest string, size int64, content io.Reader) error {
+	if w.blobs[digest] {
+		return nil
+	}
+	name, err := blobPath(digest)
+	if err != nil {
+		return err
+	}
+	err = w.tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Unix(0, 0), Typeflag: tar.TypeReg})
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", digest, err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(w.tw, io.TeeReader(io.LimitReader(content, size), hash))
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", digest, err)
+	}
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		return fmt.Errorf("blob %s has digest %s", digest, actual)
+	}
+	w.blobs[digest] = true
+	return nil
+}
+
+// AddManifest adds a manifest that was written as a blob to the index, named by the full reference of its image.
+func (w *Writer) AddManifest(descriptor registry.Descriptor, name string) {
+	descriptor.Annotations = map[string]string{AnnotationRefName: name, AnnotationImageName: name}
+	w.index.Manifests = append(w.index.Manifests, descriptor)
+}
+
+// Close writes the index and finishes the tarball, it doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	content, err := json.Marshal(w.index)
+	if err != nil {
+		return err
+	}
+	err = w.writeFile(indexFile, content)
+	if err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
+
+func (w *Writer) writeFile(name string, content []byte) error {
+	err := w.tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: time.Unix(0, 0), Typeflag: tar.TypeReg})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	_, err = w.tw.Write(content)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of a blob in a layout, e.g. blobs/sha256/<hex>.
+func blobPath(digest string) (string, error) {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || encoded == "" || strings.ContainsAny(digest, "/\\.") {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return "blobs/" + algorithm + "/" + encoded, nil
+}
+
+// Layout is an OCI image layout that is read, a directory or a tarball.
+type Layout struct {
+	// Index is the index of the layout, its manifests are the images of the layout.
+	Index registry.Manifest
+	// DockerImages are the images of a docker archive opened with [OpenArchive].
+	DockerImages []DockerImage
+
+	file *os.File
+	dir  string
+	// entries are the sections of the files of a tarball, by path.
+	entries map[string]*io.SectionReader
+}
+
+// Open opens the layout in a directory or a tarball. The layout must be closed.
+func Open(name string) (*Layout, error) {
+	layout, err := openFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	err = layout.decode(indexFile, &layout.Index)
+	if err != nil {
+		layout.Close()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s isn't an OCI image layout: %w", name, err)
+		}
+		return nil, fmt.Errorf("failed to decode index of %s: %w", name, err)
+	}
+	return layout, nil
+}
+
+// OpenArchive opens an OCI image layout like [Open] or a docker archive written by docker save, in a directory or a
+// tarball. The images of docker archives are in DockerImages, docker 25 and later write both. The layout must be closed.
+func OpenArchive(name string) (*Layout, error) {
+	layout, err := openFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	indexErr := layout.decode(indexFile, &layout.Index)
+	if indexErr != nil && !errors.Is(indexErr, fs.ErrNotExist) {
+		layout.Close()
+		return nil, fmt.Errorf("failed to decode index of %s: %w", name, indexErr)
+	}
+	manifestErr := layout.decode(dockerManifestFile, &layout.DockerImages)
+	if manifestErr != nil && !errors.Is(manifestErr, fs.ErrNotExist) {
+		layout.Close()
+		return nil, fmt.Errorf("failed to decode %s of %s: %w", dockerManifestFile, name, manifestErr)
+	}
+	if indexErr != nil && manifestErr != nil {
+		layout.Close()
+		return nil, fmt.Errorf("%s isn't an OCI image layout or a docker archive: %w", name, indexErr)
+	}
+	return layout, nil
+}
+
+// openFiles opens the files of a layout without reading them.
+func openFiles(name string) (*Layout, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return openTarball(name)
+	}
+	return &Layout{dir: name}, nil
+}
+
+// decode decodes a JSON file of the layout into v.
+func (l *Layout) decode(name string, v any) error {
+	file, err := l.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(io.LimitReader(file, maxIndexSize)).Decode(v)
+}
+
+// openTarball indexes the files of a tarball so they can be read in any order.
+func openTarball(name string) (*Layout, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	layout := &Layout{file: file, entries: make(map[string]*io.SectionReader)}
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return layout, nil
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read tarball %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// the reader is at the start of the content of regular files
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read tarball %s: %w", name, err)
+		}
+		layout.entries[path.Clean(strings.TrimPrefix(header.Name, "./"))] = io.NewSectionReader(file, offset, header.Size)
+	}
+}
+
+// DockerImage is an image of a docker archive, an entry of its manifest.json.
+type DockerImage struct {
+	// Config is the path of the config of the image, its digest is the ID of the image.
+	Config string `json:"Config"`
+	// RepoTags are the names of the image, e.g. nginx:1.25.
+	RepoTags []string `json:"RepoTags"`
+	// Layers are the paths of the layers of the image.
+	Layers []string `json:"Layers"`
+}
+
+// Open opens a file of the layout by its slash separated path, e.g. index.json.
+func (l *Layout) Open(name string) (io.ReadSeekCloser, error) {
+	if l.entries == nil {
+		return os.Open(filepath.Join(l.dir, filepath.FromSlash(name)))
+	}
+	entry, ok := l.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return nopCloser{io.NewSectionReader(entry, 0, entry.Size())}, nil
+}
+
+// HasBlob reports whether the layout has a blob.
+func (l *Layout) HasBlob(digest string) bool {
+	blob, err := l.Blob(digest)
+	if err != nil {
+		return false
+	}
+	blob.Close()
+	return true
+}
+
+// Blob opens a blob of the layout, it returns an error matching [fs.ErrNotExist] when the layout doesn't have it.
+func (l *Layout) Blob(digest string) (io.ReadSeekCloser, error) {
+	name, err := blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	return l.Open(name)
+}
+
+// ReadBlob reads a blob of the layout that is at most limit bytes, e.g. a manifest, and checks its digest.
+func (l *Layout) ReadBlob(digest string, limit int64) ([]byte, error) {
+	blob, err := l.Blob(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	content, err := io.ReadAll(io.LimitReader(blob, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", digest, err)
+	}
+	if registry.Digest(content) != digest {
+		return nil, fmt.Errorf("blob %s has digest %s", digest, registry.Digest(content))
+	}
+	return content, nil
+}
+
+// Close closes the tarball of the layout.
+func (l *Layout) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+func TestWriterAndLayout(t *testing.T) {
+	t.Parallel()
+	layer := []byte("layer")
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	descriptor := registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: registry.Digest(manifest), Size: int64(len(manifest))}
+
+	var tarball bytes.Buffer
+	writer, err := NewWriter(&tarball)
+	require.NoError(t, err)
+	require.False(t, writer.HasBlob(registry.Digest(layer)))
+	require.NoError(t, writer.WriteBlob(registry.Digest(layer), int64(len(layer)), bytes.NewReader(layer)))
+	require.True(t, writer.HasBlob(registry.Digest(layer)))
+	// blobs are written once
+	require.NoError(t, writer.WriteBlob(registry.Digest(layer), int64(len(layer)), bytes.NewReader(layer)))
+	require.NoError(t, writer.WriteBlob(descriptor.Digest, descriptor.Size, bytes.NewReader(manifest)))
+	writer.AddManifest(descriptor, "docker.io/library/nginx:1.25")
+	require.NoError(t, writer.Close())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.tar")
+	require.NoError(t, os.WriteFile(path, tarball.Bytes(), 0o600))
+	// the same layout extracted to a directory
+	layoutDir := filepath.Join(dir, "layout")
+	tr := tar.NewReader(bytes.NewReader(tarball.Bytes()))
+	var names []string
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		target := filepath.Join(layoutDir, filepath.FromSlash(header.Name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0o700))
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(target, content, 0o600))
+	}
+	require.Equal(t, []string{"oci-layout", "blobs/sha256/" + strings.TrimPrefix(registry.Digest(layer), "sha256:"), "blobs/sha256/" + strings.TrimPrefix(descriptor.Digest, "sha256:"), "index.json"}, names)
+
+	for _, name := range []string{path, layoutDir} {
+		layout, err := Open(name)
+		require.NoError(t, err, name)
+		require.Equal(t, registry.MediaTypeOCIIndex, layout.Index.MediaType)
+		require.Len(t, layout.Index.Manifests, 1)
+		require.Equal(t, map[string]string{
+			AnnotationRefName:   "docker.io/library/nginx:1.25",
+			AnnotationImageName: "docker.io/library/nginx:1.25",
+		}, layout.Index.Manifests[0].Annotations)
+		content, err := layout.ReadBlob(descriptor.Digest, 1024)
+		require.NoError(t, err)
+		require.Equal(t, manifest, content)
+		require.True(t, layout.HasBlob(registry.Digest(layer)))
+		require.False(t, layout.HasBlob(registry.Digest([]byte("missing"))))
+		_, err = layout.Blob(registry.Digest([]byte("missing")))
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		require.NoError(t, layout.Close())
+	}
+}
+
+func TestWriterDigestMismatch(t *testing.T) {
+	t.Parallel()
+	writer, err := NewWriter(io.Discard)
+	require.NoError(t, err)
+	err = writer.WriteBlob(registry.Digest([]byte("expected")), 6, strings.NewReader("actual"))
+	require.ErrorContains(t, err, "has digest "+registry.Digest([]byte("actual")))
+	require.ErrorContains(t, writer.WriteBlob("sha256:../../etc", 0, strings.NewReader("")), "invalid digest")
+}
+
+func TestOpenErrors(t *testing.T) {
+	t.Parallel()
+	_, err := Open(filepath.Join(t.TempDir(), "missing.tar"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = Open(t.TempDir())
+	require.ErrorContains(t, err, "isn't an OCI image layout")
+}
+
+func TestOpenArchive(t *testing.T) {
+	t.Parallel()
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	manifest := []byte(`[{"Config":"blobs/sha256/config","RepoTags":["nginx:1.25"],"Layers":["blobs/sha256/layer"]}]`)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0o644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(manifest)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	require.NoError(t, os.WriteFile(path, tarball.Bytes(), 0o600))
+
+	layout, err := OpenArchive(path)
+	require.NoError(t, err)
+	require.Equal(t, []DockerImage{{Config: "blobs/sha256/config", RepoTags: []string{"nginx:1.25"}, Layers: []string{"blobs/sha256/layer"}}}, layout.DockerImages)
+	require.Empty(t, layout.Index.Manifests)
+	require.NoError(t, layout.Close())
+	// docker archives aren't OCI image layouts
+	_, err = Open(path)
+	require.ErrorContains(t, err, "isn't an OCI image layout")
+
+	_, err = OpenArchive(t.TempDir())
+	require.ErrorContains(t, err, "isn't an OCI image layout or a docker archive")
+}
@@ -0,0 +1,259 @@
+// Package policy checks the image references of Kubernetes objects against a policy.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Checks a policy rule can fail, they identify violations.
+const (
+	CheckInvalidReference  = "invalid-reference"
+	CheckAllowedRegistries = "allowed-registries"
+	CheckForbidLatest      = "forbid-latest"
+	CheckRequireTag        = "require-tag"
+	CheckRequireDigest     = "require-digest"
+	CheckDeniedRepository  = "denied-repository"
+	CheckTagPattern        = "tag-pattern"
+)
+
+// Policy is a list of rules every image reference must follow.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule restricts the image references of the objects it matches. Every restriction that is set applies.
+type Rule struct {
+	// Name identifies the rule in violations.
+	Name string `json:"name"`
+	// Match limits the rule to some objects, the rule applies to every object when it is empty.
+	Match Match `json:"match"`
+	// AllowedRegistries are the registries images may come from, e.g. registry.example.com.
+	// An entry can also be a prefix of repositories, e.g. docker.io/library.
+	AllowedRegistries []string `json:"allowedRegistries"`
+	// ForbidLatest forbids the latest tag.
+	ForbidLatest bool `json:"forbidLatest"`
+	// RequireTag forbids references without a tag or digest, they implicitly mean the latest tag.
+	RequireTag bool `json:"requireTag"`
+	// RequireDigest requires references to be pinned to a digest.
+	RequireDigest bool `json:"requireDigest"`
+	// DeniedRepositories are repositories images may not come from, e.g. nginx or quay.io/example/*.
+	// They are normalized like image references, patterns with * wildcards matching any characters must be fully qualified.
+	DeniedRepositories []string `json:"deniedRepositories"`
+	// TagPatterns restrict the tags of repositories.
+	TagPatterns []TagPattern `json:"tagPatterns"`
+}
+
+// Match selects objects, every field that is set must match.
+type Match struct {
+	// Namespaces the object must be in one of.
+	Namespaces []string `json:"namespaces"`
+	// Kinds the object must be one of.
+	Kinds []Kind `json:"kinds"`
+	// Labels the object must have.
+	Labels map[string]string `json:"labels"`
+}
+
+// Kind matches objects by their apiVersion and kind, an empty field matches every value.
+type Kind struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// TagPattern requires the tags of a repository to match a regular expression.
+type TagPattern struct {
+	// Repository is matched like [Rule.DeniedRepositories].
+	Repository string `json:"repository"`
+	// Pattern must match the entire tag, e.g. v\d+\.\d+\.\d+.
+	Pattern string `json:"pattern"`
+
+	regexp *regexp.Regexp
+}
+
+// Violation is an image reference that breaks a rule.
+type Violation struct {
+	// Rule is the name of the rule, it is empty for invalid references.
+	Rule string `json:"rule,omitempty"`
+	// Check is the check that failed, e.g. [CheckForbidLatest].
+	Check string `json:"check"`
+	// Message describes the violation.
+	Message string `json:"message"`
+	// Occurrence is the image reference and where it was found.
+	Occurrence images.Occurrence `json:"occurrence"`
+}
+
+// Parse decodes and validates a policy.
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+	err := yaml.UnmarshalWithOptions(data, &policy, yaml.Strict())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	err = policy.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// compile validates the rules and compiles their patterns.
+func (p *Policy) compile() error {
+	if len(p.Rules) == 0 {
+		return errors.New("invalid policy, it has no rules")
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("invalid policy, rule %d has no name", i)
+		}
+		for _, repository := range rule.DeniedRepositories {
+			if repository == "" {
+				return fmt.Errorf("invalid policy, rule %s has an empty denied repository", rule.Name)
+			}
+		}
+		for j := range rule.TagPatterns {
+			tagPattern := &rule.TagPatterns[j]
+			if tagPattern.Repository == "" {
+				return fmt.Errorf("invalid policy, a tag pattern of rule %s has no repository", rule.Name)
+			}
+			compiled, err := regexp.Compile(`^(?:` + tagPattern.Pattern + `)$`)
+			if err != nil {
+				return fmt.Errorf("invalid tag pattern %q in rule %s: %w", tagPattern.Pattern, rule.Name, err)
+			}
+			tagPattern.regexp = compiled
+		}
+	}
+	return nil
+}
+
+// Check returns the violations of the rules by the occurrences in the order of the occurrences and rules.
+// References that can't be parsed are violations when a rule matches their object.
+func (p *Policy) Check(occurrences []images.Occurrence) []Violation {
+	var violations []Violation
+	for _, occurrence := range occurrences {
+		var rules []Rule
+		for _, rule := range p.Rules {
+			if rule.Match.matches(occurrence) {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		ref, err := reference.Parse(occurrence.Image)
+		if err != nil {
+			violations = append(violations, Violation{Check: CheckInvalidReference, Message: err.Error(), Occurrence: occurrence})
+			continue
+		}
+		for _, rule := range rules {
+			for _, violation := range rule.check(ref) {
+				violation.Rule = rule.Name
+				violation.Occurrence = occurrence
+				violations = append(violations, violation)
+			}
+		}
+	}
+	return violations
+}
+
+func (m Match) matches(occurrence images.Occurrence) bool {
+	object := occurrence.Object
+	if len(m.Namespaces) > 0 && !slices.Contains(m.Namespaces, object.Namespace) {
+		return false
+	}
+	if len(m.Kinds) > 0 {
+		matched := false
+		for _, kind := range m.Kinds {
+			if (kind.APIVersion == "" || kind.APIVersion == object.APIVersion) && (kind.Kind == "" || kind.Kind == object.Kind) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for key, value := range m.Labels {
+		if actual, ok := occurrence.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) check(ref reference.Reference) []Violation {
+	var violations []Violation
+	add := func(check string, format string, args ...any) {
+		violations = append(violations, Violation{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(r.AllowedRegistries) > 0 && !r.allowedRegistry(ref) {
+		add(CheckAllowedRegistries, "registry %s is not allowed, allowed registries are %s", ref.Registry, strings.Join(r.AllowedRegistries, ", "))
+	}
+	if r.ForbidLatest && ref.Tag == "latest" {
+		add(CheckForbidLatest, "the latest tag is forbidden")
+	}
+	if r.RequireTag && ref.Tag == "" && ref.Digest == "" {
+		add(CheckRequireTag, "a tag is required, no tag means latest")
+	}
+	if r.RequireDigest && ref.Digest == "" {
+		add(CheckRequireDigest, "a digest is required")
+	}
+	for _, repository := range r.DeniedRepositories {
+		if matchRepository(repository, ref) {
+			add(CheckDeniedRepository, "repository %s is denied", ref.Name())
+			break
+		}
+	}
+	for _, tagPattern := range r.TagPatterns {
+		if ref.Tag == "" || !matchRepository(tagPattern.Repository, ref) {
+			continue
+		}
+		if !tagPattern.regexp.MatchString(ref.Tag) {
+			add(CheckTagPattern, "tag %s of %s doesn't match %s", ref.Tag, ref.Name(), tagPattern.Pattern)
+		}
+	}
+	return violations
+}
+
+func (r Rule) allowedRegistry(ref reference.Reference) bool {
+	name := ref.Name()
+	for _, allowed := range r.AllowedRegistries {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if allowed == ref.Registry || strings.HasPrefix(name, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRepository reports whether the normalized name of ref matches a repository pattern.
+// Patterns are normalized like image references, so nginx matches docker.io/library/nginx, and * matches any characters.
+func matchRepository(pattern string, ref reference.Reference) bool {
+	if normalized, err := reference.Parse(pattern); err == nil && normalized.Tag == "" && normalized.Digest == "" {
+		return normalized.Name() == ref.Name()
+	}
+	name := ref.Name()
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(name, part)
+		}
+		index := strings.Index(name, part)
+		if index < 0 {
+			return false
+		}
+		name = name[index+len(part):]
+	}
+	return name == ""
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+)
+
+const testPolicy = `rules:
+  - name: production
+    match:
+      namespaces: [production]
+      kinds:
+        - apiVersion: apps/v1
+          kind: Deployment
+        - kind: Pod
+      labels:
+        tier: web
+    allowedRegistries:
+      - registry.example.com
+      - docker.io/library
+    forbidLatest: true
+    requireTag: true
+    requireDigest: true
+  - name: everywhere
+    deniedRepositories:
+      - busybox
+      - quay.io/untrusted/*
+    tagPatterns:
+      - repository: registry.example.com/*
+        pattern: v\d+\.\d+\.\d+
+`
+
+func occurrence(image string, kind string, namespace string, labels map[string]string) images.Occurrence {
+	apiVersion := "v1"
+	if kind == "Deployment" {
+		apiVersion = "apps/v1"
+	}
+	return images.Occurrence{
+		Image:  image,
+		Object: images.Object{APIVersion: apiVersion, Kind: kind, Namespace: namespace, Name: "app"},
+		Labels: labels,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	policy, err := Parse([]byte(testPolicy))
+	require.NoError(t, err)
+	web := map[string]string{"tier": "web", "team": "a"}
+	tests := []struct {
+		name       string
+		occurrence images.Occurrence
+		violations []Violation
+	}{
+		{
+			name:       "compliant",
+			occurrence: occurrence("registry.example.com/app:v1.2.3@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "Deployment", "production", web),
+		},
+		{
+			name:       "official image pinned",
+			occurrence: occurrence("nginx:1.25@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "Pod", "production", web),
+		},
+		{
+			name:       "production violations",
+			occurrence: occurrence("quay.io/untrusted/app:latest", "Deployment", "production", web),
+			violations: []Violation{
+				{Rule: "production", Check: CheckAllowedRegistries, Message: "registry quay.io is not allowed, allowed registries are registry.example.com, docker.io/library"},
+				{Rule: "production", Check: CheckForbidLatest, Message: "the latest tag is forbidden"},
+				{Rule: "production", Check: CheckRequireDigest, Message: "a digest is required"},
+				{Rule: "everywhere", Check: CheckDeniedRepository, Message: "repository quay.io/untrusted/app is denied"},
+			},
+		},
+		{
+			name:       "missing tag",
+			occurrence: occurrence("registry.example.com/app", "Pod", "production", web),
+			violations: []Violation{
+				{Rule: "production", Check: CheckRequireTag, Message: "a tag is required, no tag means latest"},
+				{Rule: "production", Check: CheckRequireDigest, Message: "a digest is required"},
+			},
+		},
+		{
+			name:       "other namespace",
+			occurrence: occurrence("quay.io/example/app:latest", "Deployment", "staging", web),
+		},
+		{
+			name:       "other labels",
+			occurrence: occurrence("quay.io/example/app:latest", "Deployment", "production", map[string]string{"tier": "db"}),
+		},
+		{
+			name:       "other kind",
+			occurrence: occurrence("quay.io/example/app:latest", "StatefulSet", "production", web),
+		},
+		{
+			name:       "denied repository",
+			occurrence: occurrence("docker.io/library/busybox:1.36", "Pod", "staging", nil),
+			violations: []Violation{
+				{Rule: "everywhere", Check: CheckDeniedRepository, Message: "repository docker.io/library/busybox is denied"},
+			},
+		},
+		{
+			name:       "tag pattern",
+			occurrence: occurrence("registry.example.com/team/app:main", "Pod", "staging", nil),
+			violations: []Violation{
+				{Rule: "everywhere", Check: CheckTagPattern, Message: `tag main of registry.example.com/team/app doesn't match v\d+\.\d+\.\d+`},
+			},
+		},
+		{
+			name:       "invalid reference",
+			occurrence: occurrence("Not A Reference", "Pod", "staging", nil),
+			violations: []Violation{
+				{Check: CheckInvalidReference, Message: `invalid repository in image reference "Not A Reference"`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for i := range tt.violations {
+				tt.violations[i].Occurrence = tt.occurrence
+			}
+			require.Equal(t, tt.violations, policy.Check([]images.Occurrence{tt.occurrence}))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"rules: []":                                         "it has no rules",
+		"rules:\n  - forbidLatest: true":                    "rule 0 has no name",
+		"rules:\n  - name: a\n    unknown: 1":               "unknown",
+		"rules:\n  - name: a\n    deniedRepositories: ['']": "empty denied repository",
+		"rules:\n  - name: a\n    tagPatterns:\n      - repository: nginx\n        pattern: '('": "invalid tag pattern",
+		"rules:\n  - name: a\n    tagPatterns: [{pattern: 'v.*'}]":                               "has no repository",
+	}
+	for policy, err := range tests {
+		_, parseErr := Parse([]byte(policy))
+		require.ErrorContains(t, parseErr, err, policy)
+	}
+}
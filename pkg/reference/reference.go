@@ -0,0 +1,100 @@
+// Package reference parses and normalizes container image references.
+package reference
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultRegistry is the registry used for references that do not specify one.
+	DefaultRegistry = "docker.io"
+	// officialRepositoryPrefix is prepended to single component repositories on the default registry.
+	officialRepositoryPrefix = "library/"
+)
+
+var (
+	registryRegexp   = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?$`)
+	repositoryRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+	tagRegexp        = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp     = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// Registry is the normalized registry host, e.g. docker.io or quay.io:443.
+	Registry string
+	// Repository is the normalized repository path in the registry, e.g. library/nginx.
+	Repository string
+	// Tag is the tag of the image, it is empty when the reference does not specify one.
+	Tag string
+	// Digest is the digest of the image, e.g. sha256:..., it is empty when the reference does not specify one.
+	Digest string
+}
+
+// Parse parses and normalizes an image reference such as nginx:1.25 into docker.io/library/nginx:1.25.
+func Parse(s string) (Reference, error) {
+	var ref Reference
+	remainder := s
+	if name, digest, ok := strings.Cut(remainder, "@"); ok {
+		if !digestRegexp.MatchString(digest) {
+			return Reference{}, fmt.Errorf("invalid digest in image reference %q", s)
+		}
+		ref.Digest = digest
+		remainder = name
+	}
+	lastSlash := strings.LastIndex(remainder, "/")
+	if lastColon := strings.LastIndex(remainder, ":"); lastColon > lastSlash {
+		ref.Tag = remainder[lastColon+1:]
+		remainder = remainder[:lastColon]
+		if !tagRegexp.MatchString(ref.Tag) {
+			return Reference{}, fmt.Errorf("invalid tag in image reference %q", s)
+		}
+	}
+	ref.Registry = DefaultRegistry
+	ref.Repository = remainder
+	if first, rest, ok := strings.Cut(remainder, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first) {
+		if !registryRegexp.MatchString(first) {
+			return Reference{}, fmt.Errorf("invalid registry in image reference %q", s)
+		}
+		ref.Registry = first
+		ref.Repository = rest
+	}
+	if ref.Registry == "index.docker.io" || ref.Registry == "registry-1.docker.io" {
+		ref.Registry = DefaultRegistry
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = officialRepositoryPrefix + ref.Repository
+	}
+	if !repositoryRegexp.MatchString(ref.Repository) {
+		return Reference{}, fmt.Errorf("invalid repository in image reference %q", s)
+	}
+	return ref, nil
+}
+
+// Name returns the fully qualified repository name, e.g. docker.io/library/nginx.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// Version returns the tag and digest part of the reference, e.g. 1.25, 1.25@sha256:... or @sha256:....
+func (r Reference) Version() string {
+	version := r.Tag
+	if r.Digest != "" {
+		version += "@" + r.Digest
+	}
+	return version
+}
+
+// String returns the fully qualified reference, e.g. docker.io/library/nginx:1.25.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package reference
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		expected Reference
+		name     string
+		str      string
+	}{
+		{
+			input:    "nginx",
+			expected: Reference{Registry: "docker.io", Repository: "library/nginx"},
+			name:     "docker.io/library/nginx",
+			str:      "docker.io/library/nginx",
+		},
+		{
+			input:    "nginx:1.25",
+			expected: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.25"},
+			name:     "docker.io/library/nginx",
+			str:      "docker.io/library/nginx:1.25",
+		},
+		{
+			input:    "bitnami/redis:7.0@" + testDigest,
+			expected: Reference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.0", Digest: testDigest},
+			name:     "docker.io/bitnami/redis",
+			str:      "docker.io/bitnami/redis:7.0@" + testDigest,
+		},
+		{
+			input:    "index.docker.io/nginx@" + testDigest,
+			expected: Reference{Registry: "docker.io", Repository: "library/nginx", Digest: testDigest},
+			name:     "docker.io/library/nginx",
+			str:      "docker.io/library/nginx@" + testDigest,
+		},
+		{
+			input:    "localhost:5000/team/app:v1",
+			expected: Reference{Registry: "localhost:5000", Repository: "team/app", Tag: "v1"},
+			name:     "localhost:5000/team/app",
+			str:      "localhost:5000/team/app:v1",
+		},
+		{
+			input:    "quay.io/prometheus/prometheus:v2.45.0",
+			expected: Reference{Registry: "quay.io", Repository: "prometheus/prometheus", Tag: "v2.45.0"},
+			name:     "quay.io/prometheus/prometheus",
+			str:      "quay.io/prometheus/prometheus:v2.45.0",
+		},
+		{
+			input:    "localhost/app",
+			expected: Reference{Registry: "localhost", Repository: "app"},
+			name:     "localhost/app",
+			str:      "localhost/app",
+		},
+	}
+	for _, test := range tests {
+		ref, err := Parse(test.input)
+		require.NoError(t, err, test.input)
+		require.Equal(t, test.expected, ref, test.input)
+		require.Equal(t, test.name, ref.Name(), test.input)
+		require.Equal(t, test.str, ref.String(), test.input)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{
+		"",
+		"Nginx",
+		"nginx:",
+		"nginx:-1",
+		"nginx@sha256:short",
+		"registry.example.com/",
+		"registry_example.com:5000/app",
+		"{{ .Values.image }}",
+	} {
+		_, err := Parse(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "1.25", Reference{Tag: "1.25"}.Version())
+	require.Equal(t, "1.25@"+testDigest, Reference{Tag: "1.25", Digest: testDigest}.Version())
+	require.Equal(t, "@"+testDigest, Reference{Digest: testDigest}.Version())
+	require.Empty(t, Reference{}.Version())
+}
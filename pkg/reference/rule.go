@@ -0,0 +1,169 @@
+package reference
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule maps image references starting with a prefix to another prefix, e.g. docker.io/ to mirror.example.com/dockerhub/.
+type Rule struct {
+	// From is the prefix matched against the normalized reference, see [Reference.String]. It only matches whole
+	// components: it ends with /, : or @, or the reference continues with one of them or ends after it, so
+	// docker.io/library/nginx doesn't match docker.io/library/nginx-exporter.
+	From string `json:"from"`
+	// To replaces From.
+	To string `json:"to"`
+}
+
+// ParseRule parses a rule in the form FROM=TO.
+func ParseRule(s string) (Rule, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rule %q, expected FROM=TO", s)
+	}
+	rule := Rule{From: from, To: to}
+	return rule, rule.Validate()
+}
+
+// Validate checks that both sides of the rule are set.
+func (r Rule) Validate() error {
+	if r.From == "" || r.To == "" {
+		return fmt.Errorf("invalid rule %q, both sides must be set", r.From+"="+r.To)
+	}
+	return nil
+}
+
+// ParseRules parses rules in the form FROM=TO.
+func ParseRules(rules []string) (Rules, error) {
+	parsed := make(Rules, 0, len(rules))
+	for _, rule := range rules {
+		r, err := ParseRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// Rules is an ordered list of rules, the first matching rule wins.
+type Rules []Rule
+
+// Apply maps an image reference with the first rule matching its normalized form.
+// It returns the image unchanged and false when no rule matches or the image is not a valid reference.
+func (r Rules) Apply(image string) (string, bool) {
+	ref, err := Parse(image)
+	if err != nil {
+		return image, false
+	}
+	normalized := ref.String()
+	for _, rule := range r {
+		if rest, ok := rule.match(normalized); ok {
+			return rule.To + rest, true
+		}
+	}
+	return image, false
+}
+
+// match returns the rest of a normalized reference after From when From matches it at a component boundary.
+func (r Rule) match(normalized string) (string, bool) {
+	rest, ok := strings.CutPrefix(normalized, r.From)
+	if !ok {
+		return "", false
+	}
+	if rest == "" || strings.ContainsAny(r.From[len(r.From)-1:], "/:@") || strings.ContainsAny(rest[:1], "/:@") {
+		return rest, true
+	}
+	return "", false
+}
+
+// Pin pins an image to a digest.
+type Pin struct {
+	// Image is matched against the normalized reference without its digest, e.g. docker.io/library/nginx:1.25.
+	Image string `json:"image"`
+	// Digest is the digest the image is pinned to, e.g. sha256:....
+	Digest string `json:"digest"`
+}
+
+// ParsePin parses a pin in the form IMAGE=DIGEST.
+func ParsePin(s string) (Pin, error) {
+	image, digest, ok := strings.Cut(s, "=")
+	if !ok {
+		return Pin{}, fmt.Errorf("invalid pin %q, expected IMAGE=DIGEST", s)
+	}
+	pin := Pin{Image: image, Digest: digest}
+	return pin, pin.Validate()
+}
+
+// ParsePins parses pins in the form IMAGE=DIGEST.
+func ParsePins(pins []string) (Pins, error) {
+	parsed := make(Pins, 0, len(pins))
+	for _, pin := range pins {
+		p, err := ParsePin(pin)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// Validate checks that the pin's image is a valid reference without a digest and that its digest is valid.
+func (p Pin) Validate() error {
+	ref, err := Parse(p.Image)
+	if err != nil {
+		return fmt.Errorf("invalid pin image: %w", err)
+	}
+	if ref.Digest != "" {
+		return fmt.Errorf("invalid pin image %q, it must not have a digest", p.Image)
+	}
+	if !digestRegexp.MatchString(p.Digest) {
+		return fmt.Errorf("invalid pin digest %q", p.Digest)
+	}
+	return nil
+}
+
+// Pins is a list of pins.
+type Pins []Pin
+
+// Apply pins an image reference to the digest of the first pin matching it.
+// A digest the image already has is replaced. An image with neither a tag nor a digest gets the latest tag it implies,
+// e.g. nginx is pinned to nginx:latest@sha256:..., so the tag isn't lost.
+// It returns the image unchanged and false when no pin matches or the image is not a valid reference.
+func (p Pins) Apply(image string) (string, bool) {
+	ref, err := Parse(image)
+	if err != nil {
+		return image, false
+	}
+	name := withoutDigest(image)
+	if ref.Tag == "" && ref.Digest == "" {
+		name += ":latest"
+	}
+	ref.Digest = ""
+	for _, pin := range p {
+		pinned, err := Parse(pin.Image)
+		if err != nil || pinned.String() != ref.String() {
+			continue
+		}
+		return name + "@" + pin.Digest, true
+	}
+	return image, false
+}
+
+// withoutDigest strips the digest from an image reference as written.
+func withoutDigest(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	return name
+}
+
+// Mirror maps an image reference to a repository under prefix named after its registry and repository, e.g.
+// docker.io/library/nginx:1.25 to registry.example.com/mirror/docker.io/library/nginx:1.25 for registry.example.com/mirror.
+// The port of a registry is joined to its host with a dash. It returns the image unchanged and false when it is not a valid reference.
+func Mirror(image string, prefix string) (string, bool) {
+	ref, err := Parse(image)
+	if err != nil {
+		return image, false
+	}
+	registry := strings.ToLower(strings.ReplaceAll(ref.Registry, ":", "-"))
+	return strings.TrimSuffix(prefix, "/") + "/" + registry + "/" + strings.TrimPrefix(ref.String(), ref.Registry+"/"), true
+}
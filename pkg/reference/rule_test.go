@@ -0,0 +1,103 @@
+package reference
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	t.Parallel()
+	rule, err := ParseRule("docker.io/=mirror.example.com/dockerhub/")
+	require.NoError(t, err)
+	require.Equal(t, Rule{From: "docker.io/", To: "mirror.example.com/dockerhub/"}, rule)
+	for _, invalid := range []string{"", "docker.io/", "=mirror.example.com/", "docker.io/="} {
+		_, err := ParseRule(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	t.Parallel()
+	rules, err := ParseRules([]string{
+		"docker.io/library/nginx=mirror.example.com/nginx",
+		"docker.io/library/redis:7.2=mirror.example.com/redis:7.2-patched",
+		"docker.io/=mirror.example.com/dockerhub/",
+		"quay.io/=mirror.example.com/quay/",
+	})
+	require.NoError(t, err)
+	tests := []struct {
+		image    string
+		expected string
+		applied  bool
+	}{
+		{image: "nginx:1.25", expected: "mirror.example.com/nginx:1.25", applied: true},
+		{image: "nginx", expected: "mirror.example.com/nginx", applied: true},
+		{image: "nginx-exporter:1", expected: "mirror.example.com/dockerhub/library/nginx-exporter:1", applied: true},
+		{image: "redis:7.2", expected: "mirror.example.com/redis:7.2-patched", applied: true},
+		{image: "redis:7.25", expected: "mirror.example.com/dockerhub/library/redis:7.25", applied: true},
+		{image: "bitnami/redis@" + testDigest, expected: "mirror.example.com/dockerhub/bitnami/redis@" + testDigest, applied: true},
+		{image: "quay.io/prometheus/prometheus:v2.45.0", expected: "mirror.example.com/quay/prometheus/prometheus:v2.45.0", applied: true},
+		{image: "ghcr.io/app/web:1.0", expected: "ghcr.io/app/web:1.0", applied: false},
+		{image: "{{ .Values.image }}", expected: "{{ .Values.image }}", applied: false},
+	}
+	for _, test := range tests {
+		image, applied := rules.Apply(test.image)
+		require.Equal(t, test.expected, image, test.image)
+		require.Equal(t, test.applied, applied, test.image)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"nginx:1.25": "registry.example.com/mirror/docker.io/library/nginx:1.25",
+		"quay.io/prometheus/prometheus@" + testDigest: "registry.example.com/mirror/quay.io/prometheus/prometheus@" + testDigest,
+		"localhost:5000/app:v1":                       "registry.example.com/mirror/localhost-5000/app:v1",
+	}
+	for image, expected := range tests {
+		mirrored, ok := Mirror(image, "registry.example.com/mirror/")
+		require.True(t, ok, image)
+		require.Equal(t, expected, mirrored, image)
+		_, err := Parse(mirrored)
+		require.NoError(t, err, mirrored)
+	}
+	mirrored, ok := Mirror("{{ .Values.image }}", "registry.example.com/mirror")
+	require.False(t, ok)
+	require.Equal(t, "{{ .Values.image }}", mirrored)
+}
+
+func TestParsePin(t *testing.T) {
+	t.Parallel()
+	pin, err := ParsePin("nginx:1.25=" + testDigest)
+	require.NoError(t, err)
+	require.Equal(t, Pin{Image: "nginx:1.25", Digest: testDigest}, pin)
+	for _, invalid := range []string{"nginx:1.25", "nginx:1.25=sha256:short", "nginx@" + testDigest + "=" + testDigest, "=" + testDigest} {
+		_, err := ParsePin(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestPinsApply(t *testing.T) {
+	t.Parallel()
+	otherDigest := "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	pins, err := ParsePins([]string{"docker.io/library/nginx:1.25=" + testDigest, "redis=" + testDigest})
+	require.NoError(t, err)
+	tests := []struct {
+		image    string
+		expected string
+		applied  bool
+	}{
+		{image: "nginx:1.25", expected: "nginx:1.25@" + testDigest, applied: true},
+		{image: "docker.io/library/nginx:1.25@" + otherDigest, expected: "docker.io/library/nginx:1.25@" + testDigest, applied: true},
+		{image: "nginx:1.24", expected: "nginx:1.24", applied: false},
+		{image: "redis", expected: "redis:latest@" + testDigest, applied: true},
+		{image: "redis@" + otherDigest, expected: "redis@" + testDigest, applied: true},
+		{image: "{{ .Values.image }}", expected: "{{ .Values.image }}", applied: false},
+	}
+	for _, test := range tests {
+		image, applied := pins.Apply(test.image)
+		require.Equal(t, test.expected, image, test.image)
+		require.Equal(t, test.applied, applied, test.image)
+	}
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// challenge is a parsed WWW-Authenticate header, see RFC 7235.
+type challenge struct {
+	scheme     string
+	parameters map[string]string
+}
+
+// parseChallenge parses a WWW-Authenticate header with a single challenge such as Bearer realm="...",service="...".
+func parseChallenge(header string) challenge {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	c := challenge{scheme: strings.ToLower(scheme), parameters: make(map[string]string)}
+	for rest != "" {
+		var key string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// quoted values may contain commas, e.g. scope="repository:a:pull,push"
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value = b.String()
+			rest = rest[min(i+1, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			c.parameters[key] = strings.TrimSpace(value)
+		}
+	}
+	return c
+}
+
+// authorize answers a challenge of the registry of ref and returns the Authorization header to send.
+func (c *Client) authorize(ctx context.Context, ref reference.Reference, header string) (string, error) {
+	var credentials Credentials
+	if c.Keychain != nil {
+		var err error
+		credentials, err = c.Keychain.Credentials(ref.Registry)
+		if err != nil {
+			return "", fmt.Errorf("failed to get credentials for %s: %w", ref.Registry, err)
+		}
+	}
+	challenge := parseChallenge(header)
+	switch challenge.scheme {
+	case "basic":
+		if credentials.Username == "" {
+			return "", fmt.Errorf("registry %s requires credentials: %w", ref.Registry, ErrUnauthorized)
+		}
+		return "Basic " + basicAuth(credentials.Username, credentials.Password), nil
+	case "bearer":
+		return c.token(ctx, ref, challenge, credentials)
+	default:
+		return "", fmt.Errorf("unsupported authentication scheme %q of registry %s", challenge.scheme, ref.Registry)
+	}
+}
+
+// token fetches a bearer token from the realm of a challenge, see https://distribution.github.io/distribution/spec/auth/token/.
+func (c *Client) token(ctx context.Context, ref reference.Reference, challenge challenge, credentials Credentials) (string, error) {
+	realm, err := url.Parse(challenge.parameters["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q of registry %s", challenge.parameters["realm"], ref.Registry)
+	}
+	query := realm.Query()
+	if service := challenge.parameters["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := challenge.parameters["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	for scope := range strings.FieldsSeq(scope) {
+		query.Add("scope", scope)
+	}
+	realm.RawQuery = query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	switch {
+	case credentials.IdentityToken != "":
+		// identity tokens are refresh tokens, exchanging them for an access token requires the OAuth2 flow
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {credentials.IdentityToken},
+			"service":       {challenge.parameters["service"]},
+			"scope":         strings.Fields(scope),
+			"client_id":     {"skim"},
+		}
+		request, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.Scheme+"://"+realm.Host+realm.Path, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", fmt.Errorf("failed to create token request: %w", err)
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case credentials.Username != "":
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+	response, err := c.send(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token for %s: %w", ref.Name(), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token for %s: %w", ref.Name(), responseError(request, response))
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode token of registry %s: %w", ref.Registry, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry %s returned an empty token", ref.Registry)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+func basicAuth(username string, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Credentials authenticate with a registry, the zero value means anonymous access.
+type Credentials struct {
+	Username string
+	Password string
+	// IdentityToken is an OAuth2 refresh token, it is used instead of the username and password when set.
+	IdentityToken string
+}
+
+// Keychain provides the credentials of registries.
+type Keychain interface {
+	// Credentials returns the credentials of a registry as normalized by [reference.Parse], e.g. docker.io.
+	Credentials(registry string) (Credentials, error)
+}
+
+// DockerConfig is a keychain backed by a docker config.json, see https://docs.docker.com/reference/cli/docker/#configuration-files.
+// Credentials are read from auths and from the credential helpers configured by credHelpers and credsStore.
+type DockerConfig struct {
+	Auths       map[string]DockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+// DockerAuth is an entry of the auths of a docker config.json, auth is the base64 encoded username:password.
+type DockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// DefaultDockerConfigPath returns the path of the docker config.json, in $DOCKER_CONFIG or in ~/.docker.
+func DefaultDockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// LoadDockerConfig reads a docker config.json, a missing file is an empty configuration.
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	config := &DockerConfig{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker config: %w", err)
+	}
+	err = json.Unmarshal(content, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse docker config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Credentials returns the credentials of a registry, from its credential helper if it has one and from auths otherwise.
+func (c *DockerConfig) Credentials(registry string) (Credentials, error) {
+	if helper := c.CredHelpers[registry]; helper != "" {
+		return credentialHelper(helper, serverURL(registry))
+	}
+	if registry == reference.DefaultRegistry {
+		if helper := c.CredHelpers["index.docker.io"]; helper != "" {
+			return credentialHelper(helper, serverURL(registry))
+		}
+	}
+	for key, auth := range c.Auths {
+		if normalizeServer(key) != registry {
+			continue
+		}
+		credentials := Credentials{Username: auth.Username, Password: auth.Password, IdentityToken: auth.IdentityToken}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("invalid auth of %s in docker config: %w", key, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return Credentials{}, fmt.Errorf("invalid auth of %s in docker config, expected username:password", key)
+			}
+			credentials.Username, credentials.Password = username, password
+		}
+		return credentials, nil
+	}
+	if c.CredsStore != "" {
+		return credentialHelper(c.CredsStore, serverURL(registry))
+	}
+	return Credentials{}, nil
+}
+
+// normalizeServer turns a key of auths, e.g. https://index.docker.io/v1/, into a registry as normalized by [reference.Parse].
+func normalizeServer(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	if server == "index.docker.io" || server == "registry-1.docker.io" {
+		return reference.DefaultRegistry
+	}
+	return server
+}
+
+// serverURL returns the server URL credential helpers store the credentials of a registry under.
+func serverURL(registry string) string {
+	if registry == reference.DefaultRegistry {
+		return "https://index.docker.io/v1/"
+	}
+	return registry
+}
+
+// credentialHelper gets credentials from docker-credential-<helper>, see https://github.com/docker/docker-credential-helpers.
+func credentialHelper(helper string, server string) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+	helperCmd := exec.Command("docker-credential-"+helper, "get")
+	helperCmd.Stdin = strings.NewReader(server)
+	helperCmd.Stdout = &stdout
+	helperCmd.Stderr = &stderr
+	err := helperCmd.Run()
+	if err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		// helpers report missing credentials on stdout and fail
+		if strings.Contains(output, "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, fmt.Errorf("failed to run credential helper %s: %w: %s", helper, err, output)
+	}
+	var response struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse output of credential helper %s: %w", helper, err)
+	}
+	// helpers return <token> as the username of identity tokens
+	if response.Username == "<token>" {
+		return Credentials{IdentityToken: response.Secret}, nil
+	}
+	return Credentials{Username: response.Username, Password: response.Secret}, nil
+}
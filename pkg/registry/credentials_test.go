@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDockerConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	config, err := LoadDockerConfig(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	credentials, err := config.Credentials("docker.io")
+	require.NoError(t, err)
+	require.Equal(t, Credentials{}, credentials)
+
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNzOndvcmQ="},
+			"ghcr.io": {"username": "octocat", "password": "token"},
+			"example.azurecr.io": {"identitytoken": "refresh"},
+			"broken.example.com": {"auth": "bm9jb2xvbg=="}
+		}
+	}`), 0o600))
+	config, err = LoadDockerConfig(path)
+	require.NoError(t, err)
+	tests := []struct {
+		registry    string
+		credentials Credentials
+		err         string
+	}{
+		{registry: "docker.io", credentials: Credentials{Username: "user", Password: "pass:word"}},
+		{registry: "ghcr.io", credentials: Credentials{Username: "octocat", Password: "token"}},
+		{registry: "example.azurecr.io", credentials: Credentials{IdentityToken: "refresh"}},
+		{registry: "quay.io"},
+		{registry: "broken.example.com", err: "expected username:password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			t.Parallel()
+			credentials, err := config.Credentials(tt.registry)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.credentials, credentials)
+		})
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = LoadDockerConfig(path)
+	require.ErrorContains(t, err, "failed to parse docker config")
+}
+
+func TestParseChallenge(t *testing.T) {
+	t.Parallel()
+	c := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	require.Equal(t, challenge{
+		scheme: "bearer",
+		parameters: map[string]string{
+			"realm":   "https://auth.example.com/token",
+			"service": "registry.example.com",
+			"scope":   "repository:a/b:pull,push",
+		},
+	}, c)
+	require.Equal(t, challenge{scheme: "basic", parameters: map[string]string{"realm": "registry"}}, parseChallenge(`Basic realm=registry`))
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Manifest is an image manifest or an image index (manifest list), see https://github.com/opencontainers/image-spec/blob/main/manifest.md.
+type Manifest struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	MediaType     string `json:"mediaType,omitempty"`
+	ArtifactType  string `json:"artifactType,omitempty"`
+	// Manifests are the manifests of an index.
+	Manifests []Descriptor `json:"manifests,omitempty"`
+	// Config and Layers are the blobs of an image manifest.
+	Config *Descriptor  `json:"config,omitempty"`
+	Layers []Descriptor `json:"layers,omitempty"`
+	// Subject is the manifest an artifact such as a signature refers to.
+	Subject     *Descriptor       `json:"subject,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IsIndex reports whether a media type is the media type of an image index or manifest list.
+func IsIndex(mediaType string) bool {
+	return mediaType == MediaTypeOCIIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// ParseManifest decodes a manifest, the media type of its descriptor is used when the manifest doesn't say.
+func ParseManifest(descriptor Descriptor, content []byte) (*Manifest, error) {
+	var manifest Manifest
+	err := json.Unmarshal(content, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", descriptor.Digest, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = descriptor.MediaType
+	}
+	return &manifest, nil
+}
+
+// String returns the platform as os/architecture/variant, e.g. linux/arm64/v8.
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Matches reports whether p is the required platform, a required platform without a variant matches every variant.
+func (p Platform) Matches(required Platform) bool {
+	return p.OS == required.OS && p.Architecture == required.Architecture && (required.Variant == "" || p.Variant == required.Variant)
+}
+
+// ParsePlatform parses a platform in the form os/architecture or os/architecture/variant.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return Platform{}, fmt.Errorf("invalid platform %q, expected os/architecture[/variant]", s)
+	}
+	platform := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// Platforms returns the platforms an image provides.
+// They are read from the index of multi-platform images and from the image config of single-platform images.
+// Entries of an index that aren't images, such as attestations with the unknown/unknown platform, are left out.
+func (c *Client) Platforms(ctx context.Context, ref reference.Reference) ([]Platform, error) {
+	descriptor, content, err := c.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParseManifest(descriptor, content)
+	if err != nil {
+		return nil, err
+	}
+	if IsIndex(manifest.MediaType) || len(manifest.Manifests) > 0 {
+		var platforms []Platform
+		for _, entry := range manifest.Manifests {
+			if entry.Platform == nil || entry.Platform.OS == "unknown" || entry.Platform.Architecture == "unknown" {
+				continue
+			}
+			if !slices.Contains(platforms, *entry.Platform) {
+				platforms = append(platforms, *entry.Platform)
+			}
+		}
+		return platforms, nil
+	}
+	platform, err := c.configPlatform(ctx, ref, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return []Platform{platform}, nil
+}
+
+// configPlatform reads the platform of a single-platform image from its config.
+func (c *Client) configPlatform(ctx context.Context, ref reference.Reference, manifest *Manifest) (Platform, error) {
+	if manifest.Config == nil {
+		return Platform{}, fmt.Errorf("manifest of %s has no config", ref)
+	}
+	blob, err := c.Blob(ctx, ref, manifest.Config.Digest)
+	if err != nil {
+		return Platform{}, fmt.Errorf("failed to fetch config of %s: %w", ref, err)
+	}
+	defer blob.Close()
+	var config Platform
+	err = json.NewDecoder(io.LimitReader(blob, maxConfigSize)).Decode(&config)
+	if err != nil {
+		return Platform{}, fmt.Errorf("failed to decode config of %s: %w", ref, err)
+	}
+	return config, nil
+}
+
+// maxConfigSize limits how much of an image config is read, configs are small JSON documents.
+const maxConfigSize = 4 << 20
@@ -0,0 +1,71 @@
+package registry_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+var (
+	linuxAMD64 = registry.Platform{OS: "linux", Architecture: "amd64"}
+	linuxARM64 = registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+)
+
+func TestParsePlatform(t *testing.T) {
+	t.Parallel()
+	platform, err := registry.ParsePlatform("linux/arm64/v8")
+	require.NoError(t, err)
+	require.Equal(t, linuxARM64, platform)
+	require.Equal(t, "linux/arm64/v8", platform.String())
+
+	platform, err = registry.ParsePlatform("linux/amd64")
+	require.NoError(t, err)
+	require.Equal(t, linuxAMD64, platform)
+
+	for _, invalid := range []string{"", "linux", "linux/", "linux/arm/v7/extra"} {
+		_, err := registry.ParsePlatform(invalid)
+		require.ErrorContains(t, err, "invalid platform")
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	t.Parallel()
+	require.True(t, linuxARM64.Matches(registry.Platform{OS: "linux", Architecture: "arm64"}))
+	require.True(t, linuxARM64.Matches(linuxARM64))
+	require.False(t, linuxARM64.Matches(registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v9"}))
+	require.False(t, linuxAMD64.Matches(registry.Platform{OS: "linux", Architecture: "arm64"}))
+}
+
+func TestClientPlatforms(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.PushImage("multi", "v1", linuxAMD64, linuxARM64)
+	r.PushImage("single", "v1", linuxARM64)
+	attestation := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef","size":1,"platform":{"os":"linux","architecture":"amd64"}},` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdee","size":1,"platform":{"os":"unknown","architecture":"unknown"}}]}`
+	r.PushManifest("attested", "v1", registry.MediaTypeOCIIndex, []byte(attestation))
+	client := &registry.Client{PlainHTTP: true}
+
+	tests := []struct {
+		image     string
+		platforms []registry.Platform
+	}{
+		{image: "multi:v1", platforms: []registry.Platform{linuxAMD64, linuxARM64}},
+		{image: "single:v1", platforms: []registry.Platform{linuxARM64}},
+		{image: "attested:v1", platforms: []registry.Platform{linuxAMD64}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			t.Parallel()
+			platforms, err := client.Platforms(t.Context(), mustParse(t, r.Host()+"/"+tt.image))
+			require.NoError(t, err)
+			require.Equal(t, tt.platforms, platforms)
+		})
+	}
+
+	_, err := client.Platforms(t.Context(), mustParse(t, r.Host()+"/missing:v1"))
+	require.ErrorIs(t, err, registry.ErrNotFound)
+}
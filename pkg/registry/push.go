@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// BlobExists reports whether the repository of ref has a blob.
+func (c *Client) BlobExists(ctx context.Context, ref reference.Reference, digest string) (bool, error) {
+	response, err := c.do(ctx, ref, http.MethodHead, "/blobs/"+digest, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+	return true, nil
+}
+
+// PushBlob uploads a blob with digest to the repository of ref in a single request.
+// Content that implements [io.Seeker] is sent again after authenticating or when the upload is retried, other content isn't.
+func (c *Client) PushBlob(ctx context.Context, ref reference.Reference, digest string, content io.Reader) error {
+	response, err := c.do(ctx, ref, http.MethodPost, "/blobs/uploads/", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start upload of blob %s to %s: %w", digest, ref.Name(), err)
+	}
+	drain(response)
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil || response.Header.Get("Location") == "" {
+		return fmt.Errorf("failed to start upload of blob %s to %s: invalid upload location %q", digest, ref.Name(), response.Header.Get("Location"))
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+	response, err = c.doURL(ctx, ref, http.MethodPut, location.String(), content, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return fmt.Errorf("failed to upload blob %s to %s: %w", digest, ref.Name(), err)
+	}
+	drain(response)
+	return nil
+}
+
+// PushManifest uploads a manifest to the repository of ref under its tag, or its digest when it has no tag, and returns its digest.
+func (c *Client) PushManifest(ctx context.Context, ref reference.Reference, mediaType string, content []byte) (string, error) {
+	digest := Digest(content)
+	version := ref.Tag
+	if version == "" {
+		version = digest
+	}
+	response, err := c.do(ctx, ref, http.MethodPut, "/manifests/"+version, bytes.NewReader(content), map[string]string{"Content-Type": mediaType})
+	if err != nil {
+		return "", fmt.Errorf("failed to push manifest %s to %s: %w", digest, ref.Name(), err)
+	}
+	drain(response)
+	return digest, nil
+}
+
+// rewindable makes a request with a seekable body send its whole content and able to send it again.
+func rewindable(request *http.Request, body io.Reader) error {
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok || request.GetBody != nil {
+		return nil
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek request body: %w", err)
+	}
+	_, err = seeker.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to seek request body: %w", err)
+	}
+	request.ContentLength = size
+	request.GetBody = func() (io.ReadCloser, error) {
+		_, err := seeker.Seek(0, io.SeekStart)
+		return io.NopCloser(seeker), err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// maxIndexSize limits the size of the referrers index.
+const maxIndexSize = 4 << 20
+
+// Referrers lists the manifests in the repository of ref whose subject is the manifest with the given digest, e.g. signatures.
+// Only referrers of artifactType are returned when it isn't empty. Registries that don't support the referrers API return [ErrNotFound].
+func (c *Client) Referrers(ctx context.Context, ref reference.Reference, digest string, artifactType string) ([]Descriptor, error) {
+	path := "/referrers/" + digest
+	if artifactType != "" {
+		path += "?" + url.Values{"artifactType": {artifactType}}.Encode()
+	}
+	response, err := c.do(ctx, ref, http.MethodGet, path, nil, map[string]string{"Accept": MediaTypeOCIIndex})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var index Manifest
+	err = json.NewDecoder(io.LimitReader(response.Body, maxIndexSize)).Decode(&index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode referrers of %s@%s: %w", ref.Name(), digest, err)
+	}
+	// registries may ignore the filter, they then don't set the OCI-Filters-Applied header
+	var referrers []Descriptor
+	for _, descriptor := range index.Manifests {
+		if artifactType == "" || descriptor.ArtifactType == artifactType {
+			referrers = append(referrers, descriptor)
+		}
+	}
+	return referrers, nil
+}
@@ -0,0 +1,337 @@
+// Package registry is a minimal client for the OCI Distribution API, see https://github.com/opencontainers/distribution-spec.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Manifest media types the client accepts.
+const (
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// acceptedManifestTypes is sent in the Accept header of manifest requests, the order is the order of preference.
+var acceptedManifestTypes = strings.Join([]string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifest,
+}, ", ")
+
+var (
+	// ErrNotFound is returned when a manifest or blob doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is returned when the registry refuses the credentials, or requires credentials and there are none.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnreachable is returned when a registry can't be reached, e.g. because its host doesn't resolve or refuses connections.
+	ErrUnreachable = errors.New("failed to reach")
+)
+
+// defaultRetryDelay is the delay before the first retry when [Client.RetryDelay] isn't set.
+const defaultRetryDelay = time.Second
+
+// ResponseError is returned for unexpected responses from a registry.
+// It matches [ErrNotFound] and [ErrUnauthorized] with [errors.Is] depending on its status code.
+type ResponseError struct {
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body is the beginning of the response's body, registries describe errors there.
+	Body string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.URL, e.StatusCode)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// Descriptor describes a manifest or blob, see https://github.com/opencontainers/image-spec/blob/main/descriptor.md.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform is the platform an image runs on.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Client talks to registries with the OCI Distribution API.
+// It authenticates with the credentials of its keychain, answering basic and bearer token challenges. The zero value is ready to use.
+type Client struct {
+	// HTTPClient sends the requests, [http.DefaultClient] when nil.
+	HTTPClient *http.Client
+	// Keychain provides the credentials of registries, requests are anonymous when it is nil.
+	Keychain Keychain
+	// PlainHTTP talks to registries over HTTP instead of HTTPS, e.g. for a local registry.
+	PlainHTTP bool
+	// Retries is how many times a request is sent again after a network error or a 429 or 5xx response.
+	Retries int
+	// RetryDelay is the delay before the first retry, it doubles with every retry. Defaults to one second.
+	RetryDelay time.Duration
+
+	mu sync.Mutex
+	// authorizations caches the Authorization header per registry and repository.
+	authorizations map[string]string
+}
+
+// Head returns the descriptor of the manifest a reference points to, by its digest when it has one, by its tag otherwise.
+// A reference with neither points to the latest tag.
+func (c *Client) Head(ctx context.Context, ref reference.Reference) (Descriptor, error) {
+	response, err := c.do(ctx, ref, http.MethodHead, "/manifests/"+manifestVersion(ref), nil, map[string]string{"Accept": acceptedManifestTypes})
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer response.Body.Close()
+	descriptor := Descriptor{
+		MediaType: mediaType(response.Header.Get("Content-Type")),
+		Digest:    response.Header.Get("Docker-Content-Digest"),
+		Size:      response.ContentLength,
+	}
+	if descriptor.Digest == "" {
+		// the header is optional, the digest is then the digest of the manifest's content
+		descriptor, _, err = c.Manifest(ctx, ref)
+		if err != nil {
+			return Descriptor{}, err
+		}
+	}
+	return descriptor, nil
+}
+
+// Manifest fetches the manifest a reference points to, see [Client.Head].
+// The digest of the returned descriptor is computed from the content and verified against the reference's digest.
+func (c *Client) Manifest(ctx context.Context, ref reference.Reference) (Descriptor, []byte, error) {
+	response, err := c.do(ctx, ref, http.MethodGet, "/manifests/"+manifestVersion(ref), nil, map[string]string{"Accept": acceptedManifestTypes})
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Descriptor{}, nil, fmt.Errorf("failed to read manifest of %s: %w", ref, err)
+	}
+	descriptor := Descriptor{
+		MediaType: mediaType(response.Header.Get("Content-Type")),
+		Digest:    Digest(content),
+		Size:      int64(len(content)),
+	}
+	if ref.Digest != "" && ref.Digest != descriptor.Digest {
+		return Descriptor{}, nil, fmt.Errorf("manifest of %s has digest %s", ref, descriptor.Digest)
+	}
+	return descriptor, content, nil
+}
+
+// Blob fetches a blob of the repository of ref, e.g. an image config or a layer. The caller must close it.
+func (c *Client) Blob(ctx context.Context, ref reference.Reference, digest string) (io.ReadCloser, error) {
+	response, err := c.do(ctx, ref, http.MethodGet, "/blobs/"+digest, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Digest returns the sha256 digest of content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func manifestVersion(ref reference.Reference) string {
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+	if ref.Tag != "" {
+		return ref.Tag
+	}
+	return "latest"
+}
+
+func mediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType)
+}
+
+// host returns the host serving a registry's API.
+func host(registry string) string {
+	if registry == reference.DefaultRegistry {
+		return "registry-1.docker.io"
+	}
+	return registry
+}
+
+// url returns the URL of a path in a repository of a registry, e.g. /manifests/latest.
+func (c *Client) url(ref reference.Reference, path string) string {
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + host(ref.Registry) + "/v2/" + ref.Repository + path
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// do sends a request for a path in the repository of ref, authenticating when the registry asks for it.
+// Responses other than 2xx are returned as a [*ResponseError]. body must be nil or support [http.Request.GetBody] to be sent again after authenticating.
+func (c *Client) do(ctx context.Context, ref reference.Reference, method string, path string, body io.Reader, header map[string]string) (*http.Response, error) {
+	return c.doURL(ctx, ref, method, c.url(ref, path), body, header)
+}
+
+func (c *Client) doURL(ctx context.Context, ref reference.Reference, method string, url string, body io.Reader, header map[string]string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	err = rewindable(request, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	key := ref.Name()
+	c.mu.Lock()
+	authorization := c.authorizations[key]
+	c.mu.Unlock()
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized && response.Header.Get("WWW-Authenticate") != "" {
+		challenge := response.Header.Get("WWW-Authenticate")
+		drain(response)
+		authorization, err := c.authorize(ctx, ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if c.authorizations == nil {
+			c.authorizations = make(map[string]string)
+		}
+		c.authorizations[key] = authorization
+		c.mu.Unlock()
+		request, err = rewind(request)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", authorization)
+		response, err = c.send(request)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		return nil, responseError(request, response)
+	}
+	return response, nil
+}
+
+// send sends a request, sending it again after network errors and responses saying the registry is overloaded or failing.
+func (c *Client) send(request *http.Request) (*http.Response, error) {
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		response, err := c.httpClient().Do(request)
+		retryable := err != nil || response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= c.Retries || request.Context().Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %w", ErrUnreachable, request.URL.Host, err)
+			}
+			return response, nil
+		}
+		if err == nil {
+			drain(response)
+		}
+		next, rewindErr := rewind(request)
+		if rewindErr != nil {
+			return nil, rewindErr
+		}
+		request = next
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(delay << attempt):
+		}
+	}
+}
+
+// rewind returns a copy of a request that can be sent again.
+func rewind(request *http.Request) (*http.Request, error) {
+	next := request.Clone(request.Context())
+	if request.Body == nil || request.Body == http.NoBody {
+		return next, nil
+	}
+	if request.GetBody == nil {
+		return nil, errors.New("failed to send request again, its body can't be read again")
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request again: %w", err)
+	}
+	next.Body = body
+	return next, nil
+}
+
+// maxErrorBody limits how much of an error response is kept.
+const maxErrorBody = 1024
+
+func responseError(request *http.Request, response *http.Response) *ResponseError {
+	content, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	return &ResponseError{
+		Method:     request.Method,
+		URL:        request.URL.Redacted(),
+		StatusCode: response.StatusCode,
+		Body:       strings.TrimSpace(string(content)),
+	}
+}
+
+// drain reads the rest of a response's body and closes it so the connection can be reused.
+func drain(response *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxErrorBody))
+	_ = response.Body.Close()
+}
@@ -0,0 +1,192 @@
+package registry_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/reference"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`
+
+func mustParse(t *testing.T, s string) reference.Reference {
+	t.Helper()
+	ref, err := reference.Parse(s)
+	require.NoError(t, err)
+	return ref
+}
+
+func TestClientHead(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	digest := r.PushManifest("team/app", "v1", registry.MediaTypeOCIManifest, []byte(testManifest))
+	client := &registry.Client{PlainHTTP: true}
+
+	for _, image := range []string{"/team/app:v1", "/team/app@" + digest, "/team/app:v1@" + digest} {
+		descriptor, err := client.Head(t.Context(), mustParse(t, r.Host()+image))
+		require.NoError(t, err)
+		require.Equal(t, registry.Descriptor{
+			MediaType: registry.MediaTypeOCIManifest,
+			Digest:    digest,
+			Size:      int64(len(testManifest)),
+		}, descriptor)
+	}
+
+	_, err := client.Head(t.Context(), mustParse(t, r.Host()+"/team/app:v2"))
+	require.ErrorIs(t, err, registry.ErrNotFound)
+
+	_, err = client.Head(t.Context(), mustParse(t, "127.0.0.1:1/team/app:v1"))
+	require.ErrorIs(t, err, registry.ErrUnreachable)
+	require.ErrorContains(t, err, "failed to reach 127.0.0.1:1")
+	require.NotErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestClientManifest(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	digest := r.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(testManifest))
+	client := &registry.Client{PlainHTTP: true}
+
+	descriptor, content, err := client.Manifest(t.Context(), mustParse(t, r.Host()+"/app:v1"))
+	require.NoError(t, err)
+	require.Equal(t, testManifest, string(content))
+	require.Equal(t, digest, descriptor.Digest)
+
+	// a tag pointing to another manifest than the digest says is rejected
+	other := r.PushManifest("app", "", registry.MediaTypeOCIManifest, []byte(testManifest+"\n"))
+	r.PushManifest("app", other, registry.MediaTypeOCIManifest, []byte(testManifest))
+	_, _, err = client.Manifest(t.Context(), mustParse(t, r.Host()+"/app@"+other))
+	require.ErrorContains(t, err, "has digest "+digest)
+}
+
+func TestClientAuthentication(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.RequireCredentials("user", "secret")
+	digest := r.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(testManifest))
+	ref := mustParse(t, r.Host()+"/app:v1")
+
+	anonymous := &registry.Client{PlainHTTP: true}
+	_, err := anonymous.Head(t.Context(), ref)
+	require.ErrorIs(t, err, registry.ErrUnauthorized)
+
+	wrong := &registry.Client{PlainHTTP: true, Keychain: &registry.DockerConfig{
+		Auths: map[string]registry.DockerAuth{r.Host(): {Username: "user", Password: "wrong"}},
+	}}
+	_, err = wrong.Head(t.Context(), ref)
+	require.ErrorIs(t, err, registry.ErrUnauthorized)
+
+	client := &registry.Client{PlainHTTP: true, Keychain: &registry.DockerConfig{
+		Auths: map[string]registry.DockerAuth{"http://" + r.Host(): {Auth: base64.StdEncoding.EncodeToString([]byte("user:secret"))}},
+	}}
+	for range 2 {
+		descriptor, err := client.Head(t.Context(), ref)
+		require.NoError(t, err)
+		require.Equal(t, digest, descriptor.Digest)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	digest := r.PushManifest("app", "v1", registry.MediaTypeOCIManifest, []byte(testManifest))
+	ref := mustParse(t, r.Host()+"/app:v1")
+	client := &registry.Client{PlainHTTP: true, Retries: 2, RetryDelay: time.Millisecond}
+
+	r.Fail(2)
+	descriptor, err := client.Head(t.Context(), ref)
+	require.NoError(t, err)
+	require.Equal(t, digest, descriptor.Digest)
+
+	r.Fail(3)
+	_, err = client.Head(t.Context(), ref)
+	var responseError *registry.ResponseError
+	require.ErrorAs(t, err, &responseError)
+	require.Equal(t, http.StatusServiceUnavailable, responseError.StatusCode)
+}
+
+func TestClientTags(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	for _, tag := range []string{"1.25.0", "1.25.1", "1.26.0", "latest", "2.0.0-rc.1"} {
+		r.PushManifest("nginx", tag, registry.MediaTypeOCIManifest, []byte(testManifest))
+	}
+	r.PushManifest("nginx", "", registry.MediaTypeOCIManifest, []byte(`{"schemaVersion":2}`))
+	r.PaginateTags(2)
+	r.RequireCredentials("user", "pass")
+	client := &registry.Client{PlainHTTP: true, Keychain: &registry.DockerConfig{
+		Auths: map[string]registry.DockerAuth{r.Host(): {Username: "user", Password: "pass"}},
+	}}
+
+	tags, err := client.Tags(t.Context(), mustParse(t, r.Host()+"/nginx:1.25.0"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.25.0", "1.25.1", "1.26.0", "2.0.0-rc.1", "latest"}, tags)
+
+	_, err = client.Tags(t.Context(), mustParse(t, r.Host()+"/missing"))
+	require.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestClientTagsRejectsOtherHosts(t *testing.T) {
+	t.Parallel()
+	var leaked atomic.Bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(true)
+		_, _ = io.WriteString(w, `{"tags":[]}`)
+	}))
+	t.Cleanup(other.Close)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+other.URL+`/v2/nginx/tags/list?last=1.25>; rel="next"`)
+		_, _ = io.WriteString(w, `{"tags":["1.25"]}`)
+	}))
+	t.Cleanup(server.Close)
+	client := &registry.Client{PlainHTTP: true}
+
+	_, err := client.Tags(t.Context(), mustParse(t, strings.TrimPrefix(server.URL, "http://")+"/nginx:1.25"))
+	require.ErrorContains(t, err, "the next page isn't on "+server.URL)
+	require.False(t, leaked.Load())
+}
+
+func TestClientPush(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.RequireCredentials("user", "pass")
+	client := &registry.Client{PlainHTTP: true, Retries: 1, RetryDelay: time.Millisecond, Keychain: &registry.DockerConfig{
+		Auths: map[string]registry.DockerAuth{r.Host(): {Username: "user", Password: "pass"}},
+	}}
+	ref := mustParse(t, r.Host()+"/team/app:v1")
+	config := []byte(`{"os":"linux","architecture":"amd64"}`)
+	digest := registry.Digest(config)
+
+	exists, err := client.BlobExists(t.Context(), ref, digest)
+	require.NoError(t, err)
+	require.False(t, exists)
+	// the upload is sent again after the failure
+	r.Fail(1)
+	require.NoError(t, client.PushBlob(t.Context(), ref, digest, bytes.NewReader(config)))
+	exists, err = client.BlobExists(t.Context(), ref, digest)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Error(t, client.PushBlob(t.Context(), ref, registry.Digest([]byte("other")), bytes.NewReader(config)))
+
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` + digest + `","size":37}}`
+	pushed, err := client.PushManifest(t.Context(), ref, registry.MediaTypeOCIManifest, []byte(manifest))
+	require.NoError(t, err)
+	require.Equal(t, registry.Digest([]byte(manifest)), pushed)
+	descriptor, err := client.Head(t.Context(), ref)
+	require.NoError(t, err)
+	require.Equal(t, registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: pushed, Size: int64(len(manifest))}, descriptor)
+
+	// registries reject manifests whose blobs they don't have
+	_, err = client.PushManifest(t.Context(), mustParse(t, r.Host()+"/other"), registry.MediaTypeOCIManifest, []byte(manifest))
+	require.ErrorContains(t, err, "MANIFEST_BLOB_UNKNOWN")
+}
@@ -0,0 +1,428 @@
+// Package registrytest provides an in-memory registry implementing the parts of the OCI Distribution API skim uses, for tests.
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yardenshoham/skim/pkg/registry"
+)
+
+// token is the bearer token the registry hands out for valid credentials.
+const token = "registrytest-token"
+
+var (
+	routeRegexp  = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs|tags|referrers)/([^/]+)$`)
+	uploadRegexp = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([^/]*)$`)
+)
+
+type manifest struct {
+	mediaType string
+	content   []byte
+}
+
+// Registry is an in-memory registry served over plain HTTP, its host is used as the registry of image references.
+type Registry struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	username  string
+	password  string
+	failures  int
+	pageSize  int
+	manifests map[string]map[string]manifest
+	blobs     map[string]map[string][]byte
+	uploads   int
+}
+
+// New starts a registry that is stopped when the test ends.
+func New(t testing.TB) *Registry {
+	t.Helper()
+	r := &Registry{
+		manifests: make(map[string]map[string]manifest),
+		blobs:     make(map[string]map[string][]byte),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// Host returns the host of the registry, e.g. 127.0.0.1:12345.
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// RequireCredentials makes the registry require bearer tokens that are only given for these credentials.
+func (r *Registry) RequireCredentials(username string, password string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.username = username
+	r.password = password
+}
+
+// Fail makes the registry answer the next n requests with 503 Service Unavailable.
+func (r *Registry) Fail(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = n
+}
+
+// PaginateTags makes the registry split tag lists into pages of n tags linked by Link headers, like Docker Hub does.
+func (r *Registry) PaginateTags(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pageSize = n
+}
+
+// PushManifest stores a manifest in a repository under its digest and a tag, when it isn't empty, and returns its digest.
+func (r *Registry) PushManifest(repository string, tag string, mediaType string, content []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := registry.Digest(content)
+	if r.manifests[repository] == nil {
+		r.manifests[repository] = make(map[string]manifest)
+	}
+	m := manifest{mediaType: mediaType, content: content}
+	r.manifests[repository][digest] = m
+	if tag != "" {
+		r.manifests[repository][tag] = m
+	}
+	return digest
+}
+
+// PushBlob stores a blob in a repository and returns its digest.
+func (r *Registry) PushBlob(repository string, content []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := registry.Digest(content)
+	if r.blobs[repository] == nil {
+		r.blobs[repository] = make(map[string][]byte)
+	}
+	r.blobs[repository][digest] = content
+	return digest
+}
+
+// BaseLayer is the first layer of every image pushed with [Registry.PushImage], it is shared by all of them.
+var BaseLayer = []byte("registrytest base layer")
+
+// PushImage stores an image for each platform, linux/amd64 when there are none, and returns the digest of the tagged manifest.
+// Each image has a config, [BaseLayer] and a layer of its own. Images of several platforms are tagged through an index.
+func (r *Registry) PushImage(repository string, tag string, platforms ...registry.Platform) string {
+	if len(platforms) == 0 {
+		platforms = []registry.Platform{{OS: "linux", Architecture: "amd64"}}
+	}
+	index := registry.Manifest{SchemaVersion: 2, MediaType: registry.MediaTypeOCIIndex}
+	for _, platform := range platforms {
+		config := mustMarshal(platform)
+		layer := []byte(repository + ":" + tag + " " + platform.String() + " layer")
+		manifest := mustMarshal(registry.Manifest{
+			SchemaVersion: 2,
+			MediaType:     registry.MediaTypeOCIManifest,
+			Config:        r.pushDescriptor(repository, "application/vnd.oci.image.config.v1+json", config),
+			Layers: []registry.Descriptor{
+				*r.pushDescriptor(repository, "application/vnd.oci.image.layer.v1.tar+gzip", BaseLayer),
+				*r.pushDescriptor(repository, "application/vnd.oci.image.layer.v1.tar+gzip", layer),
+			},
+		})
+		if len(platforms) == 1 {
+			return r.PushManifest(repository, tag, registry.MediaTypeOCIManifest, manifest)
+		}
+		index.Manifests = append(index.Manifests, registry.Descriptor{
+			MediaType: registry.MediaTypeOCIManifest,
+			Digest:    r.PushManifest(repository, "", registry.MediaTypeOCIManifest, manifest),
+			Size:      int64(len(manifest)),
+			Platform:  &platform,
+		})
+	}
+	return r.PushManifest(repository, tag, registry.MediaTypeOCIIndex, mustMarshal(index))
+}
+
+func (r *Registry) pushDescriptor(repository string, mediaType string, content []byte) *registry.Descriptor {
+	return &registry.Descriptor{
+		MediaType: mediaType,
+		Digest:    r.PushBlob(repository, content),
+		Size:      int64(len(content)),
+	}
+}
+
+func mustMarshal(v any) []byte {
+	content, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	fail := r.failures > 0
+	r.failures = max(r.failures-1, 0)
+	r.mu.Unlock()
+	if fail {
+		writeError(w, http.StatusServiceUnavailable, "UNAVAILABLE")
+		return
+	}
+	if req.URL.Path == "/token" {
+		r.serveToken(w, req)
+		return
+	}
+	if matches := uploadRegexp.FindStringSubmatch(req.URL.Path); matches != nil {
+		if r.authorized(w, req, matches) {
+			r.serveUpload(w, req, matches[1], matches[2])
+		}
+		return
+	}
+	matches := routeRegexp.FindStringSubmatch(req.URL.Path)
+	if !r.authorized(w, req, matches) {
+		return
+	}
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	if matches == nil {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+		return
+	}
+	repository, kind, version := matches[1], matches[2], matches[3]
+	switch {
+	case kind == "blobs":
+		r.serveBlob(w, req, repository, version)
+		return
+	case kind == "tags" && version == "list":
+		r.serveTags(w, req, repository)
+		return
+	case kind == "tags":
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+		return
+	case kind == "referrers":
+		r.serveReferrers(w, req, repository, version)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		r.mu.Lock()
+		m, ok := r.manifests[repository][version]
+		r.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(m.content)))
+		w.Header().Set("Docker-Content-Digest", registry.Digest(m.content))
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(m.content)
+		}
+	case http.MethodPut:
+		content, err := io.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID")
+			return
+		}
+		tag := version
+		if strings.Contains(version, ":") {
+			if version != registry.Digest(content) {
+				writeError(w, http.StatusBadRequest, "DIGEST_INVALID")
+				return
+			}
+			tag = ""
+		}
+		if missing := r.missingReference(repository, content); missing != "" {
+			writeError(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN")
+			return
+		}
+		digest := r.PushManifest(repository, tag, req.Header.Get("Content-Type"), content)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Location", "/v2/"+repository+"/manifests/"+digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, repository string, digest string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		r.mu.Lock()
+		content, ok := r.blobs[repository][digest]
+		r.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Docker-Content-Digest", digest)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+// missingReference returns the first manifest or blob a pushed manifest refers to that the repository doesn't have,
+// registries reject such manifests.
+func (r *Registry) missingReference(repository string, content []byte) string {
+	var m registry.Manifest
+	if json.Unmarshal(content, &m) != nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, descriptor := range m.Manifests {
+		if _, ok := r.manifests[repository][descriptor.Digest]; !ok {
+			return descriptor.Digest
+		}
+	}
+	blobs := m.Layers
+	if m.Config != nil {
+		blobs = append(blobs, *m.Config)
+	}
+	for _, descriptor := range blobs {
+		if _, ok := r.blobs[repository][descriptor.Digest]; !ok {
+			return descriptor.Digest
+		}
+	}
+	return ""
+}
+
+// serveUpload starts monolithic blob uploads and completes them with the content of a PUT request.
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, repository string, id string) {
+	switch {
+	case req.Method == http.MethodPost && id == "":
+		r.mu.Lock()
+		r.uploads++
+		id = strconv.Itoa(r.uploads)
+		r.mu.Unlock()
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && id != "":
+		content, err := io.ReadAll(req.Body)
+		if err != nil || req.URL.Query().Get("digest") != registry.Digest(content) {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID")
+			return
+		}
+		digest := r.PushBlob(repository, content)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/"+digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+// serveTags lists the tags of a repository in lexical order, a page at a time when the n query parameter or [Registry.PaginateTags] asks for it.
+func (r *Registry) serveTags(w http.ResponseWriter, req *http.Request, repository string) {
+	r.mu.Lock()
+	var tags []string
+	for version := range r.manifests[repository] {
+		if !strings.Contains(version, ":") {
+			tags = append(tags, version)
+		}
+	}
+	pageSize := r.pageSize
+	r.mu.Unlock()
+	if tags == nil {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN")
+		return
+	}
+	slices.Sort(tags)
+	query := req.URL.Query()
+	if last := query.Get("last"); last != "" {
+		i, found := slices.BinarySearch(tags, last)
+		if found {
+			i++
+		}
+		tags = tags[i:]
+	}
+	if n, err := strconv.Atoi(query.Get("n")); err == nil && n > 0 {
+		pageSize = n
+	}
+	if pageSize > 0 && len(tags) > pageSize {
+		tags = tags[:pageSize]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=%d>; rel="next"`, repository, tags[len(tags)-1], pageSize))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(mustMarshal(map[string]any{"name": repository, "tags": tags}))
+}
+
+// serveReferrers lists the manifests of a repository whose subject is the manifest with the given digest.
+func (r *Registry) serveReferrers(w http.ResponseWriter, req *http.Request, repository string, digest string) {
+	artifactType := req.URL.Query().Get("artifactType")
+	index := registry.Manifest{SchemaVersion: 2, MediaType: registry.MediaTypeOCIIndex, Manifests: []registry.Descriptor{}}
+	r.mu.Lock()
+	for version, m := range r.manifests[repository] {
+		if version != registry.Digest(m.content) {
+			continue
+		}
+		var referrer registry.Manifest
+		if json.Unmarshal(m.content, &referrer) != nil || referrer.Subject == nil || referrer.Subject.Digest != digest {
+			continue
+		}
+		descriptor := registry.Descriptor{
+			MediaType:    m.mediaType,
+			Digest:       version,
+			Size:         int64(len(m.content)),
+			ArtifactType: referrer.ArtifactType,
+			Annotations:  referrer.Annotations,
+		}
+		if descriptor.ArtifactType == "" && referrer.Config != nil {
+			descriptor.ArtifactType = referrer.Config.MediaType
+		}
+		if artifactType == "" || descriptor.ArtifactType == artifactType {
+			index.Manifests = append(index.Manifests, descriptor)
+		}
+	}
+	r.mu.Unlock()
+	slices.SortFunc(index.Manifests, func(a, b registry.Descriptor) int { return strings.Compare(a.Digest, b.Digest) })
+	w.Header().Set("Content-Type", registry.MediaTypeOCIIndex)
+	if artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	_, _ = w.Write(mustMarshal(index))
+}
+
+// authorized checks the bearer token of a request when credentials are required and challenges the client if it is missing.
+func (r *Registry) authorized(w http.ResponseWriter, req *http.Request, matches []string) bool {
+	r.mu.Lock()
+	username := r.username
+	r.mu.Unlock()
+	if username == "" || req.Header.Get("Authorization") == "Bearer "+token {
+		return true
+	}
+	challenge := fmt.Sprintf(`Bearer realm="%s/token",service="registrytest"`, r.server.URL)
+	if matches != nil {
+		challenge += fmt.Sprintf(`,scope="repository:%s:pull"`, matches[1])
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeError(w, http.StatusUnauthorized, "UNAUTHORIZED")
+	return false
+}
+
+func (r *Registry) serveToken(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	username, password := r.username, r.password
+	r.mu.Unlock()
+	if u, p, ok := req.BasicAuth(); !ok || u != username || p != password {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"token":%q}`, token)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"errors":[{"code":%q}]}`, code)
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// PlatformSize is the compressed size of the image of a platform, the size of the blobs that are pulled to run it.
+type PlatformSize struct {
+	Platform Platform
+	// Digest is the digest of the manifest of the platform.
+	Digest string
+	// Size is the total size of the config and the layers.
+	Size int64
+	// Blobs are the config and the layers.
+	Blobs []Descriptor
+}
+
+// Sizes returns the size of an image for the platforms it provides matching platforms, every platform when there are
+// none, read from its manifests. Entries of an index that aren't images are left out like they are by
+// [Client.Platforms], and entries that don't match platforms are left out before their manifests are fetched.
+func (c *Client) Sizes(ctx context.Context, ref reference.Reference, platforms []Platform) ([]PlatformSize, error) {
+	descriptor, content, err := c.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParseManifest(descriptor, content)
+	if err != nil {
+		return nil, err
+	}
+	if !IsIndex(manifest.MediaType) && len(manifest.Manifests) == 0 {
+		platform, err := c.configPlatform(ctx, ref, manifest)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesAny(platform, platforms) {
+			return nil, nil
+		}
+		return []PlatformSize{newPlatformSize(platform, descriptor.Digest, manifest)}, nil
+	}
+	var sizes []PlatformSize
+	for _, entry := range manifest.Manifests {
+		if entry.Platform == nil || entry.Platform.OS == "unknown" || entry.Platform.Architecture == "unknown" || !matchesAny(*entry.Platform, platforms) {
+			continue
+		}
+		entryRef := ref
+		entryRef.Digest = entry.Digest
+		entryDescriptor, entryContent, err := c.Manifest(ctx, entryRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest of %s for %s: %w", ref, entry.Platform, err)
+		}
+		entryManifest, err := ParseManifest(entryDescriptor, entryContent)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, newPlatformSize(*entry.Platform, entry.Digest, entryManifest))
+	}
+	return sizes, nil
+}
+
+func newPlatformSize(platform Platform, digest string, manifest *Manifest) PlatformSize {
+	size := PlatformSize{Platform: platform, Digest: digest}
+	if manifest.Config != nil {
+		size.Blobs = append(size.Blobs, *manifest.Config)
+	}
+	size.Blobs = append(size.Blobs, manifest.Layers...)
+	for _, blob := range size.Blobs {
+		size.Size += blob.Size
+	}
+	return size
+}
+
+// matchesAny reports whether platform matches one of platforms, or there are none.
+func matchesAny(platform Platform, platforms []Platform) bool {
+	return len(platforms) == 0 || slices.ContainsFunc(platforms, platform.Matches)
+}
@@ -0,0 +1,72 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/registry"
+	"github.com/yardenshoham/skim/pkg/registry/registrytest"
+)
+
+func TestClientSizes(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	r.PushImage("multi", "v1", linuxAMD64, linuxARM64)
+	single := r.PushImage("single", "v1", linuxARM64)
+	client := &registry.Client{PlainHTTP: true}
+
+	// every image has a config, the base layer and a layer of its own
+	size := func(image string, platform registry.Platform) int64 {
+		config, err := json.Marshal(platform)
+		require.NoError(t, err)
+		return int64(len(config) + len(registrytest.BaseLayer) + len(image+" "+platform.String()+" layer"))
+	}
+	sizes, err := client.Sizes(t.Context(), mustParse(t, r.Host()+"/multi:v1"), nil)
+	require.NoError(t, err)
+	require.Len(t, sizes, 2)
+	require.Equal(t, linuxAMD64, sizes[0].Platform)
+	require.Equal(t, size("multi:v1", linuxAMD64), sizes[0].Size)
+	require.Len(t, sizes[0].Blobs, 3)
+	require.Equal(t, linuxARM64, sizes[1].Platform)
+	require.Equal(t, size("multi:v1", linuxARM64), sizes[1].Size)
+	require.Equal(t, sizes[0].Blobs[1], sizes[1].Blobs[1])
+
+	sizes, err = client.Sizes(t.Context(), mustParse(t, r.Host()+"/single:v1"), nil)
+	require.NoError(t, err)
+	require.Equal(t, []registry.PlatformSize{{Platform: linuxARM64, Digest: single, Size: size("single:v1", linuxARM64), Blobs: sizes[0].Blobs}}, sizes)
+
+	sizes, err = client.Sizes(t.Context(), mustParse(t, r.Host()+"/single:v1"), []registry.Platform{linuxAMD64})
+	require.NoError(t, err)
+	require.Empty(t, sizes)
+
+	_, err = client.Sizes(t.Context(), mustParse(t, r.Host()+"/missing:v1"), nil)
+	require.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestClientSizesSkipsOtherPlatforms(t *testing.T) {
+	t.Parallel()
+	r := registrytest.New(t)
+	arm64 := r.PushImage("app", "arm64", linuxARM64)
+	// the manifest of linux/amd64 was never pushed, fetching it fails
+	index, err := json.Marshal(registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIIndex,
+		Manifests: []registry.Descriptor{
+			{MediaType: registry.MediaTypeOCIManifest, Digest: registry.Digest([]byte("missing")), Size: 7, Platform: &linuxAMD64},
+			{MediaType: registry.MediaTypeOCIManifest, Digest: arm64, Size: 1, Platform: &linuxARM64},
+		},
+	})
+	require.NoError(t, err)
+	r.PushManifest("app", "v1", registry.MediaTypeOCIIndex, index)
+	client := &registry.Client{PlainHTTP: true}
+
+	sizes, err := client.Sizes(t.Context(), mustParse(t, r.Host()+"/app:v1"), []registry.Platform{linuxARM64})
+	require.NoError(t, err)
+	require.Len(t, sizes, 1)
+	require.Equal(t, linuxARM64, sizes[0].Platform)
+	require.Equal(t, arm64, sizes[0].Digest)
+
+	_, err = client.Sizes(t.Context(), mustParse(t, r.Host()+"/app:v1"), nil)
+	require.ErrorIs(t, err, registry.ErrNotFound)
+}
@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// maxTagPages limits how many pages of tags are fetched, registries page by 100 to 1000 tags.
+const maxTagPages = 1000
+
+// Tags lists the tags of the repository of ref, following the pages registries split long lists into.
+func (c *Client) Tags(ctx context.Context, ref reference.Reference) ([]string, error) {
+	var tags []string
+	next := c.url(ref, "/tags/list")
+	for range maxTagPages {
+		response, err := c.doURL(ctx, ref, http.MethodGet, next, nil, map[string]string{"Accept": "application/json"})
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&page)
+		link := response.Header.Get("Link")
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tags of %s: %w", ref.Name(), err)
+		}
+		tags = append(tags, page.Tags...)
+		if link == "" {
+			return tags, nil
+		}
+		next, err = nextPage(next, link)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", ref.Name(), err)
+		}
+	}
+	return nil, fmt.Errorf("failed to list tags of %s, it has more than %d pages", ref.Name(), maxTagPages)
+}
+
+// nextPage resolves the URL of a Link header, e.g. </v2/library/nginx/tags/list?last=1.25&n=100>; rel="next", against the current URL.
+// The next page must be on the same registry, credentials are sent along with the request for it.
+func nextPage(current string, link string) (string, error) {
+	target, _, _ := strings.Cut(link, ";")
+	target = strings.TrimSpace(target)
+	if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+		return "", fmt.Errorf("invalid Link header %q", link)
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", current, err)
+	}
+	next, err := base.Parse(target[1 : len(target)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid Link header %q: %w", link, err)
+	}
+	if next.Scheme != base.Scheme || next.Host != base.Host {
+		return "", fmt.Errorf("invalid Link header %q, the next page isn't on %s://%s", link, base.Scheme, base.Host)
+	}
+	return next.String(), nil
+}
@@ -0,0 +1,23 @@
+package sarif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestURI(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"deploy.yaml":                  "deploy.yaml",
+		"manifests/web app.yaml":       "manifests/web%20app.yaml",
+		"../testdata/pod.yaml":         "../testdata/pod.yaml",
+		"/tmp/manifests/deploy.yaml":   "file:///tmp/manifests/deploy.yaml",
+		"charts.tar.gz!/app/pod.yaml":  "charts.tar.gz%21/app/pod.yaml",
+		"manifests/weird#name?.yaml":   "manifests/weird%23name%3F.yaml",
+		"manifests/deploy:latest.yaml": "manifests/deploy:latest.yaml",
+	}
+	for path, uri := range tests {
+		require.Equal(t, uri, URI(path), path)
+	}
+}
@@ -0,0 +1,137 @@
+package sbom
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// CycloneDXSpecVersion is the CycloneDX version of BOMs.
+const CycloneDXSpecVersion = "1.5"
+
+// CycloneDX is a CycloneDX BOM, see https://cyclonedx.org/docs/1.5/json/.
+type CycloneDX struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     CycloneDXMetadata     `json:"metadata"`
+	Components   []CycloneDXComponent  `json:"components"`
+	Dependencies []CycloneDXDependency `json:"dependencies"`
+}
+
+// CycloneDXMetadata describes a BOM and the component it is about.
+type CycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     CycloneDXTools     `json:"tools"`
+	Component CycloneDXComponent `json:"component"`
+}
+
+// CycloneDXTools are the tools that created a BOM.
+type CycloneDXTools struct {
+	Components []CycloneDXComponent `json:"components"`
+}
+
+// CycloneDXComponent is a component of a BOM.
+type CycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []CycloneDXHash     `json:"hashes,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []CycloneDXProperty `json:"properties,omitempty"`
+}
+
+// CycloneDXHash is a hash of a component.
+type CycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// CycloneDXProperty is a name-value pair describing a component.
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDXDependency lists the components a component depends on.
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// CycloneDX returns the inventory as a CycloneDX BOM. The deployment is the component of the metadata, it depends on
+// the workloads, one application component per object, which depend on the images, one container component per image.
+// Components are referred to by their package URL, images with the same package URL, e.g. nginx and nginx:latest, are
+// a single component.
+func (inv Inventory) CycloneDX() CycloneDX {
+	bom := CycloneDX{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: inv.Created.UTC().Format(time.RFC3339),
+			Tools: CycloneDXTools{
+				Components: []CycloneDXComponent{{Type: "application", Name: inv.Tool.Name, Version: inv.Tool.Version}},
+			},
+			Component: CycloneDXComponent{Type: "application", BOMRef: inv.Name, Name: inv.Name, Version: inv.Version},
+		},
+		Components:   []CycloneDXComponent{},
+		Dependencies: []CycloneDXDependency{},
+	}
+	root := CycloneDXDependency{Ref: inv.Name, DependsOn: []string{}}
+	refs := make(map[string]string, len(inv.Images))
+	for _, image := range inv.Images {
+		refs[image.Reference.String()] = image.PURL()
+	}
+	for _, workload := range inv.Workloads {
+		ref := workloadRef(workload)
+		component := CycloneDXComponent{
+			Type:       "application",
+			BOMRef:     ref,
+			Name:       workload.Object.Name,
+			Properties: []CycloneDXProperty{{Name: "skim:apiVersion", Value: workload.Object.APIVersion}, {Name: "skim:kind", Value: workload.Object.Kind}},
+		}
+		if workload.Object.Namespace != "" {
+			component.Properties = append(component.Properties, CycloneDXProperty{Name: "skim:namespace", Value: workload.Object.Namespace})
+		}
+		bom.Components = append(bom.Components, component)
+		root.DependsOn = append(root.DependsOn, ref)
+		dependency := CycloneDXDependency{Ref: ref, DependsOn: []string{}}
+		for _, image := range workload.Images {
+			if !slices.Contains(dependency.DependsOn, refs[image]) {
+				dependency.DependsOn = append(dependency.DependsOn, refs[image])
+			}
+		}
+		bom.Dependencies = append(bom.Dependencies, dependency)
+	}
+	added := make(map[string]bool, len(inv.Images))
+	for _, image := range inv.Images {
+		if added[image.PURL()] {
+			continue
+		}
+		added[image.PURL()] = true
+		component := CycloneDXComponent{
+			Type:       "container",
+			BOMRef:     image.PURL(),
+			Name:       image.Reference.Name(),
+			Version:    image.version(),
+			PURL:       image.PURL(),
+			Properties: []CycloneDXProperty{{Name: "skim:image", Value: image.Reference.String()}},
+		}
+		if hex, ok := strings.CutPrefix(image.Digest, "sha256:"); ok {
+			component.Hashes = []CycloneDXHash{{Algorithm: "SHA-256", Content: hex}}
+		}
+		bom.Components = append(bom.Components, component)
+		bom.Dependencies = append(bom.Dependencies, CycloneDXDependency{Ref: image.PURL(), DependsOn: []string{}})
+	}
+	bom.Dependencies = append([]CycloneDXDependency{root}, bom.Dependencies...)
+	return bom
+}
+
+// workloadRef identifies a workload, e.g. apps/v1/Deployment/production/web.
+func workloadRef(workload Workload) string {
+	return workload.Object.APIVersion + "/" + workload.Object.String()
+}
@@ -0,0 +1,145 @@
+// Package sbom builds deployment-level software bills of materials, an inventory of the container images Kubernetes
+// resources deploy and of the objects using them, in the CycloneDX and SPDX JSON formats.
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+// Inventory is what a deployment consists of.
+type Inventory struct {
+	// Name is the name of the deployment, e.g. the name of a release.
+	Name string
+	// Version is the version of the deployment, it may be empty.
+	Version string
+	// Tool is the tool that created the inventory.
+	Tool Tool
+	// Created is when the inventory was created.
+	Created time.Time
+	// Images are the container images, sorted by reference.
+	Images []Image
+	// Workloads are the objects using the images, in the order they were found.
+	Workloads []Workload
+}
+
+// Tool is a tool that created an inventory.
+type Tool struct {
+	Name    string
+	Version string
+}
+
+// Image is a container image of a deployment.
+type Image struct {
+	// Reference is the normalized image reference, e.g. docker.io/library/nginx:1.25.
+	Reference reference.Reference
+	// Digest is the digest of the image manifest, it is empty when it isn't known.
+	// It's the digest of the reference when the reference has one.
+	Digest string
+}
+
+// Workload is an object using container images.
+type Workload struct {
+	Object images.Object
+	// Images are the normalized references of the images the object uses, in the order they were found.
+	Images []string
+}
+
+// NewInventory groups occurrences into the images and the workloads using them.
+// The references of the occurrences that can't be parsed are skipped and returned.
+func NewInventory(occurrences []images.Occurrence) (inventory Inventory, invalid []string) {
+	found := make(map[string]bool)
+	workloads := make(map[images.Object]int)
+	for _, occurrence := range occurrences {
+		ref, err := reference.Parse(occurrence.Image)
+		if err != nil {
+			if !slices.Contains(invalid, occurrence.Image) {
+				invalid = append(invalid, occurrence.Image)
+			}
+			continue
+		}
+		image := ref.String()
+		if !found[image] {
+			found[image] = true
+			inventory.Images = append(inventory.Images, Image{Reference: ref, Digest: ref.Digest})
+		}
+		i, ok := workloads[occurrence.Object]
+		if !ok {
+			i = len(inventory.Workloads)
+			workloads[occurrence.Object] = i
+			inventory.Workloads = append(inventory.Workloads, Workload{Object: occurrence.Object})
+		}
+		if !slices.Contains(inventory.Workloads[i].Images, image) {
+			inventory.Workloads[i].Images = append(inventory.Workloads[i].Images, image)
+		}
+	}
+	slices.SortFunc(inventory.Images, func(a, b Image) int {
+		return strings.Compare(a.Reference.String(), b.Reference.String())
+	})
+	return inventory, invalid
+}
+
+// tag returns the tag of the image, latest when the reference has neither a tag nor a digest.
+func (i Image) tag() string {
+	if i.Reference.Tag == "" && i.Reference.Digest == "" {
+		return "latest"
+	}
+	return i.Reference.Tag
+}
+
+// version returns the version of the image, its tag or its digest when it has no tag.
+func (i Image) version() string {
+	if tag := i.tag(); tag != "" {
+		return tag
+	}
+	return i.Digest
+}
+
+// PURL returns the package URL of the image, e.g.
+// pkg:oci/nginx@sha256%3A...?repository_url=docker.io/library/nginx&tag=1.25,
+// see https://github.com/package-url/purl-spec/blob/main/types-doc/oci-definition.md.
+func (i Image) PURL() string {
+	ref := i.Reference
+	name := ref.Repository[strings.LastIndex(ref.Repository, "/")+1:]
+	purl := "pkg:oci/" + escape(name, false)
+	if i.Digest != "" {
+		purl += "@" + escape(i.Digest, false)
+	}
+	// qualifiers are sorted by key
+	purl += "?repository_url=" + escape(ref.Name(), true)
+	if tag := i.tag(); tag != "" {
+		purl += "&tag=" + escape(tag, false)
+	}
+	return purl
+}
+
+// escape percent-encodes everything but unreserved characters, and slashes when keepSlashes is set.
+func escape(s string, keepSlashes bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			sb.WriteByte(b)
+		case b == '/' && keepSlashes:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
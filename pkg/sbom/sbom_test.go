@@ -0,0 +1,156 @@
+package sbom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yardenshoham/skim/pkg/images"
+	"github.com/yardenshoham/skim/pkg/reference"
+)
+
+const digest = "sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+
+var (
+	web   = images.Object{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "production", Name: "web"}
+	debug = images.Object{APIVersion: "v1", Kind: "Pod", Name: "debug"}
+)
+
+func newTestInventory(t *testing.T) Inventory {
+	t.Helper()
+	inventory, invalid := NewInventory([]images.Occurrence{
+		{Image: "nginx:1.25", Object: web},
+		{Image: "localhost:5000/team/sidecar@" + digest, Object: web},
+		{Image: "docker.io/library/nginx:1.25", Object: debug},
+		{Image: "busybox", Object: debug},
+		{Image: "Invalid Image", Object: debug},
+	})
+	require.Equal(t, []string{"Invalid Image"}, invalid)
+	inventory.Name = "release"
+	inventory.Version = "1.0.0"
+	inventory.Tool = Tool{Name: "skim", Version: "v1.2.3"}
+	inventory.Created = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return inventory
+}
+
+func TestNewInventory(t *testing.T) {
+	t.Parallel()
+	inventory := newTestInventory(t)
+	var refs []string
+	for _, image := range inventory.Images {
+		refs = append(refs, image.Reference.String())
+	}
+	require.Equal(t, []string{"docker.io/library/busybox", "docker.io/library/nginx:1.25", "localhost:5000/team/sidecar@" + digest}, refs)
+	require.Equal(t, digest, inventory.Images[2].Digest)
+	require.Equal(t, []Workload{
+		{Object: web, Images: []string{"docker.io/library/nginx:1.25", "localhost:5000/team/sidecar@" + digest}},
+		{Object: debug, Images: []string{"docker.io/library/nginx:1.25", "docker.io/library/busybox"}},
+	}, inventory.Workloads)
+}
+
+func TestPURL(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"nginx:1.25":                            "pkg:oci/nginx?repository_url=docker.io/library/nginx&tag=1.25",
+		"busybox":                               "pkg:oci/busybox?repository_url=docker.io/library/busybox&tag=latest",
+		"localhost:5000/team/sidecar@" + digest: "pkg:oci/sidecar@sha256%3A" + digest[7:] + "?repository_url=localhost%3A5000/team/sidecar",
+		"quay.io/app:1.0@" + digest:             "pkg:oci/app@sha256%3A" + digest[7:] + "?repository_url=quay.io/app&tag=1.0",
+	}
+	for image, expected := range tests {
+		ref, err := reference.Parse(image)
+		require.NoError(t, err)
+		require.Equal(t, expected, Image{Reference: ref, Digest: ref.Digest}.PURL(), image)
+	}
+	resolved := Image{Reference: reference.Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "v1"}, Digest: digest}
+	require.Equal(t, "pkg:oci/app@sha256%3A"+digest[7:]+"?repository_url=ghcr.io/org/app&tag=v1", resolved.PURL())
+}
+
+func TestCycloneDX(t *testing.T) {
+	t.Parallel()
+	bom := newTestInventory(t).CycloneDX()
+	require.Equal(t, "CycloneDX", bom.BOMFormat)
+	require.Equal(t, "1.5", bom.SpecVersion)
+	require.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bom.SerialNumber)
+	require.Equal(t, "2025-01-02T03:04:05Z", bom.Metadata.Timestamp)
+	require.Equal(t, CycloneDXComponent{Type: "application", BOMRef: "release", Name: "release", Version: "1.0.0"}, bom.Metadata.Component)
+	require.Len(t, bom.Components, 5)
+	require.Equal(t, CycloneDXComponent{
+		Type:    "container",
+		BOMRef:  "pkg:oci/sidecar@sha256%3A" + digest[7:] + "?repository_url=localhost%3A5000/team/sidecar",
+		Name:    "localhost:5000/team/sidecar",
+		Version: digest,
+		Hashes:  []CycloneDXHash{{Algorithm: "SHA-256", Content: digest[7:]}},
+		PURL:    "pkg:oci/sidecar@sha256%3A" + digest[7:] + "?repository_url=localhost%3A5000/team/sidecar",
+		Properties: []CycloneDXProperty{
+			{Name: "skim:image", Value: "localhost:5000/team/sidecar@" + digest},
+		},
+	}, bom.Components[4])
+	require.Equal(t, "1.25", bom.Components[3].Version)
+	require.Equal(t, []CycloneDXDependency{
+		{Ref: "release", DependsOn: []string{"apps/v1/Deployment/production/web", "v1/Pod/debug"}},
+		{Ref: "apps/v1/Deployment/production/web", DependsOn: []string{bom.Components[3].BOMRef, bom.Components[4].BOMRef}},
+		{Ref: "v1/Pod/debug", DependsOn: []string{bom.Components[3].BOMRef, bom.Components[2].BOMRef}},
+		{Ref: bom.Components[2].BOMRef, DependsOn: []string{}},
+		{Ref: bom.Components[3].BOMRef, DependsOn: []string{}},
+		{Ref: bom.Components[4].BOMRef, DependsOn: []string{}},
+	}, bom.Dependencies)
+}
+
+func TestCycloneDXSamePURL(t *testing.T) {
+	t.Parallel()
+	inventory, _ := NewInventory([]images.Occurrence{
+		{Image: "nginx", Object: web},
+		{Image: "nginx:latest", Object: web},
+		{Image: "quay.io/app:1.0", Object: web},
+		{Image: "quay.io/app:1.0@" + digest, Object: debug},
+	})
+	// the digest of quay.io/app:1.0 was resolved
+	inventory.Images[2].Digest = digest
+	inventory.Name = "release"
+	bom := inventory.CycloneDX()
+	require.Len(t, bom.Components, 4)
+	nginx := "pkg:oci/nginx?repository_url=docker.io/library/nginx&tag=latest"
+	app := "pkg:oci/app@sha256%3A" + digest[7:] + "?repository_url=quay.io/app&tag=1.0"
+	require.Equal(t, nginx, bom.Components[2].BOMRef)
+	require.Equal(t, app, bom.Components[3].BOMRef)
+	require.Equal(t, []CycloneDXDependency{
+		{Ref: "release", DependsOn: []string{"apps/v1/Deployment/production/web", "v1/Pod/debug"}},
+		{Ref: "apps/v1/Deployment/production/web", DependsOn: []string{nginx, app}},
+		{Ref: "v1/Pod/debug", DependsOn: []string{app}},
+		{Ref: nginx, DependsOn: []string{}},
+		{Ref: app, DependsOn: []string{}},
+	}, bom.Dependencies)
+}
+
+func TestSPDX(t *testing.T) {
+	t.Parallel()
+	document := newTestInventory(t).SPDX()
+	require.Equal(t, "SPDX-2.3", document.SPDXVersion)
+	require.Regexp(t, `^https://github.com/yardenshoham/skim/spdxdocs/release-[0-9a-f-]{36}$`, document.DocumentNamespace)
+	require.Equal(t, SPDXCreationInfo{Created: "2025-01-02T03:04:05Z", Creators: []string{"Tool: skim-v1.2.3"}}, document.CreationInfo)
+	require.Len(t, document.Packages, 6)
+	require.Equal(t, SPDXPackage{
+		Name:                  "localhost:5000/team/sidecar",
+		SPDXID:                "SPDXRef-Image-3",
+		VersionInfo:           digest,
+		DownloadLocation:      "NOASSERTION",
+		PrimaryPackagePurpose: "CONTAINER",
+		Checksums:             []SPDXChecksum{{Algorithm: "SHA256", Value: digest[7:]}},
+		ExternalRefs: []SPDXExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  "pkg:oci/sidecar@sha256%3A" + digest[7:] + "?repository_url=localhost%3A5000/team/sidecar",
+		}},
+		Comment: "localhost:5000/team/sidecar@" + digest,
+	}, document.Packages[3])
+	require.Equal(t, "Deployment/production/web", document.Packages[4].Name)
+	require.Equal(t, []SPDXRelationship{
+		{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: "SPDXRef-Deployment"},
+		{Element: "SPDXRef-Deployment", Type: "CONTAINS", Related: "SPDXRef-Workload-1"},
+		{Element: "SPDXRef-Workload-1", Type: "DEPENDS_ON", Related: "SPDXRef-Image-2"},
+		{Element: "SPDXRef-Workload-1", Type: "DEPENDS_ON", Related: "SPDXRef-Image-3"},
+		{Element: "SPDXRef-Deployment", Type: "CONTAINS", Related: "SPDXRef-Workload-2"},
+		{Element: "SPDXRef-Workload-2", Type: "DEPENDS_ON", Related: "SPDXRef-Image-2"},
+		{Element: "SPDXRef-Workload-2", Type: "DEPENDS_ON", Related: "SPDXRef-Image-1"},
+	}, document.Relationships)
+}
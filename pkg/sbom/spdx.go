@@ -0,0 +1,127 @@
+package sbom
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SPDXVersion is the SPDX version of documents.
+const SPDXVersion = "SPDX-2.3"
+
+// noAssertion is the SPDX value of fields whose value isn't known.
+const noAssertion = "NOASSERTION"
+
+// SPDX is an SPDX document, see https://spdx.github.io/spdx-spec/v2.3/.
+type SPDX struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	Packages          []SPDXPackage      `json:"packages"`
+	Relationships     []SPDXRelationship `json:"relationships"`
+}
+
+// SPDXCreationInfo describes when and by whom a document was created.
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+// SPDXPackage is a package of a document.
+type SPDXPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+	Checksums             []SPDXChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []SPDXExternalRef `json:"externalRefs,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+}
+
+// SPDXChecksum is a checksum of a package.
+type SPDXChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+// SPDXExternalRef refers to a package outside the document, e.g. with a package URL.
+type SPDXExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+// SPDXRelationship relates two elements of a document.
+type SPDXRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+// SPDX returns the inventory as an SPDX document. The document describes the deployment, a package that contains
+// the workloads, one package per object, which depend on the images, one container package per image.
+func (inv Inventory) SPDX() SPDX {
+	const documentID, rootID = "SPDXRef-DOCUMENT", "SPDXRef-Deployment"
+	creator := "Tool: " + inv.Tool.Name
+	if inv.Tool.Version != "" {
+		creator += "-" + inv.Tool.Version
+	}
+	document := SPDX{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            documentID,
+		Name:              inv.Name,
+		DocumentNamespace: "https://github.com/yardenshoham/skim/spdxdocs/" + url.PathEscape(inv.Name) + "-" + newUUID(),
+		CreationInfo: SPDXCreationInfo{
+			Created:  inv.Created.UTC().Format(time.RFC3339),
+			Creators: []string{creator},
+		},
+		Packages: []SPDXPackage{{
+			Name:                  inv.Name,
+			SPDXID:                rootID,
+			VersionInfo:           inv.Version,
+			DownloadLocation:      noAssertion,
+			PrimaryPackagePurpose: "APPLICATION",
+		}},
+		Relationships: []SPDXRelationship{{Element: documentID, Type: "DESCRIBES", Related: rootID}},
+	}
+	ids := make(map[string]string, len(inv.Images))
+	for i, image := range inv.Images {
+		id := "SPDXRef-Image-" + strconv.Itoa(i+1)
+		ids[image.Reference.String()] = id
+		pkg := SPDXPackage{
+			Name:                  image.Reference.Name(),
+			SPDXID:                id,
+			VersionInfo:           image.version(),
+			DownloadLocation:      noAssertion,
+			PrimaryPackagePurpose: "CONTAINER",
+			ExternalRefs:          []SPDXExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: image.PURL()}},
+			Comment:               image.Reference.String(),
+		}
+		if hex, ok := strings.CutPrefix(image.Digest, "sha256:"); ok {
+			pkg.Checksums = []SPDXChecksum{{Algorithm: "SHA256", Value: hex}}
+		}
+		document.Packages = append(document.Packages, pkg)
+	}
+	for i, workload := range inv.Workloads {
+		id := "SPDXRef-Workload-" + strconv.Itoa(i+1)
+		document.Packages = append(document.Packages, SPDXPackage{
+			Name:                  workload.Object.String(),
+			SPDXID:                id,
+			DownloadLocation:      noAssertion,
+			PrimaryPackagePurpose: "APPLICATION",
+			Comment:               workload.Object.APIVersion,
+		})
+		document.Relationships = append(document.Relationships, SPDXRelationship{Element: rootID, Type: "CONTAINS", Related: id})
+		for _, image := range workload.Images {
+			document.Relationships = append(document.Relationships, SPDXRelationship{Element: id, Type: "DEPENDS_ON", Related: ids[image]})
+		}
+	}
+	return document
+}
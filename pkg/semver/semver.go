@@ -0,0 +1,155 @@
+// Package semver parses and compares semver-like image tags such as 1.25, v1.2.3, 1.2.3-alpine and 1.2.3-rc.1.
+package semver
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	tagRegexp = regexp.MustCompile(`^(v?)(\d+(?:\.\d+){0,2})(?:-(.+))?$`)
+	// prereleaseRegexp matches the suffixes of pre-releases, other suffixes are variants such as alpine or bookworm.
+	prereleaseRegexp = regexp.MustCompile(`(?i)^(?:alpha|beta|rc|pre|preview|dev|snapshot|nightly|canary|next)(?:[.-]?\d+)*$`)
+	runRegexp        = regexp.MustCompile(`\d+|\D+`)
+)
+
+// Version is a parsed semver-like tag.
+type Version struct {
+	// Tag is the tag as written, e.g. v1.2.3-alpine.
+	Tag string
+	// Prefix is v for tags such as v1.2.3 and empty otherwise.
+	Prefix string
+	// Segments are the numeric parts, e.g. [1 2 3], there are one to three of them.
+	Segments []int
+	// Prerelease is the pre-release suffix, e.g. rc.1.
+	Prerelease string
+	// Variant is a suffix that isn't a pre-release, e.g. alpine. Versions are only comparable within a variant.
+	Variant string
+}
+
+// Parse parses a semver-like tag, it returns false for tags such as latest or stable.
+func Parse(tag string) (Version, bool) {
+	matches := tagRegexp.FindStringSubmatch(tag)
+	if matches == nil {
+		return Version{}, false
+	}
+	version := Version{Tag: tag, Prefix: matches[1]}
+	for segment := range strings.SplitSeq(matches[2], ".") {
+		n, err := strconv.Atoi(segment)
+		if err != nil {
+			return Version{}, false
+		}
+		version.Segments = append(version.Segments, n)
+	}
+	if prereleaseRegexp.MatchString(matches[3]) {
+		version.Prerelease = matches[3]
+	} else {
+		version.Variant = matches[3]
+	}
+	return version, true
+}
+
+// IsPrerelease reports whether the version is a pre-release, e.g. 1.2.3-rc.1.
+func (v Version) IsPrerelease() bool {
+	return v.Prerelease != ""
+}
+
+// Comparable reports whether two versions follow the same scheme: the same prefix, number of segments and variant.
+// Tags of other schemes, e.g. 1.25 for 1.25.3 or 1.25.3-alpine for 1.25.3, aren't updates of each other.
+func (v Version) Comparable(other Version) bool {
+	return v.Prefix == other.Prefix && len(v.Segments) == len(other.Segments) && v.Variant == other.Variant
+}
+
+// Compare compares the precedence of two comparable versions, pre-releases precede their release.
+func (v Version) Compare(other Version) int {
+	if c := slices.Compare(v.Segments, other.Segments); c != 0 {
+		return c
+	}
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return naturalCompare(v.Prerelease, other.Prerelease)
+}
+
+// naturalCompare compares strings ordering runs of digits by their value, e.g. rc.2 before rc.10.
+func naturalCompare(a string, b string) int {
+	aRuns, bRuns := runRegexp.FindAllString(a, -1), runRegexp.FindAllString(b, -1)
+	for i := range min(len(aRuns), len(bRuns)) {
+		aNumber, aErr := strconv.Atoi(aRuns[i])
+		bNumber, bErr := strconv.Atoi(bRuns[i])
+		var c int
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(aNumber, bNumber)
+		} else {
+			c = strings.Compare(aRuns[i], bRuns[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(aRuns), len(bRuns))
+}
+
+// Updates are the newest versions of each kind of update, a field is empty when there is no such update.
+type Updates struct {
+	// Patch is the newest version with the same major and minor segments, e.g. 1.2.4 for 1.2.3.
+	Patch string `json:"patch,omitempty"`
+	// Minor is the newest version with the same major segment and a newer minor segment.
+	Minor string `json:"minor,omitempty"`
+	// Major is the newest version with a newer major segment.
+	Major string `json:"major,omitempty"`
+}
+
+// Newest returns the newest update, preferring major over minor over patch updates.
+func (u Updates) Newest() string {
+	return cmp.Or(u.Major, u.Minor, u.Patch)
+}
+
+// FindUpdates finds the updates of current among tags. Tags that don't parse or aren't comparable to current are ignored,
+// and so are pre-releases unless includePrereleases is set.
+func FindUpdates(current Version, tags []string, includePrereleases bool) Updates {
+	var patch, minor, major *Version
+	newer := func(newest *Version, candidate Version) *Version {
+		if newest == nil || candidate.Compare(*newest) > 0 {
+			return &candidate
+		}
+		return newest
+	}
+	for _, tag := range tags {
+		candidate, ok := Parse(tag)
+		if !ok || !current.Comparable(candidate) || candidate.Compare(current) <= 0 {
+			continue
+		}
+		if candidate.IsPrerelease() && !includePrereleases {
+			continue
+		}
+		switch {
+		case candidate.Segments[0] != current.Segments[0]:
+			major = newer(major, candidate)
+		case len(current.Segments) > 1 && candidate.Segments[1] != current.Segments[1]:
+			minor = newer(minor, candidate)
+		default:
+			// a newer patch segment, or the release of the current pre-release, e.g. 1.2 for 1.2-rc.1
+			patch = newer(patch, candidate)
+		}
+	}
+	var updates Updates
+	if patch != nil {
+		updates.Patch = patch.Tag
+	}
+	if minor != nil {
+		updates.Minor = minor.Tag
+	}
+	if major != nil {
+		updates.Major = major.Tag
+	}
+	return updates
+}
@@ -0,0 +1,73 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := map[string]*Version{
+		"1.25.3":        {Tag: "1.25.3", Segments: []int{1, 25, 3}},
+		"v1.2":          {Tag: "v1.2", Prefix: "v", Segments: []int{1, 2}},
+		"16":            {Tag: "16", Segments: []int{16}},
+		"1.25.3-alpine": {Tag: "1.25.3-alpine", Segments: []int{1, 25, 3}, Variant: "alpine"},
+		"2.0.0-rc.1":    {Tag: "2.0.0-rc.1", Segments: []int{2, 0, 0}, Prerelease: "rc.1"},
+		"3.9.0-beta2":   {Tag: "3.9.0-beta2", Segments: []int{3, 9, 0}, Prerelease: "beta2"},
+		"latest":        nil,
+		"1.2.3.4":       nil,
+		"stable-alpine": nil,
+		"v":             nil,
+	}
+	for tag, expected := range tests {
+		version, ok := Parse(tag)
+		if expected == nil {
+			require.False(t, ok, tag)
+			continue
+		}
+		require.True(t, ok, tag)
+		require.Equal(t, *expected, version, tag)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	ordered := []string{"1.2.3-alpha", "1.2.3-beta.2", "1.2.3-beta.10", "1.2.3-rc.1", "1.2.3", "1.2.4", "1.10.0", "2.0.0"}
+	for i := range len(ordered) - 1 {
+		a, _ := Parse(ordered[i])
+		b, _ := Parse(ordered[i+1])
+		require.Negative(t, a.Compare(b), "%s < %s", a.Tag, b.Tag)
+		require.Positive(t, b.Compare(a), "%s > %s", b.Tag, a.Tag)
+	}
+	a, _ := Parse("1.2.3")
+	require.Zero(t, a.Compare(a))
+}
+
+func TestFindUpdates(t *testing.T) {
+	t.Parallel()
+	tags := []string{
+		"latest", "1.24.0", "1.25.2", "1.25.3", "1.25.10", "1.26.0", "1.27.1", "1.27", "2.0.0", "2.1.0-rc.1", "3.0.0-beta.1",
+		"1.25.11-alpine", "1.26.0-alpine", "v1.30.0",
+	}
+	tests := []struct {
+		current            string
+		includePrereleases bool
+		updates            Updates
+	}{
+		{current: "1.25.2", updates: Updates{Patch: "1.25.10", Minor: "1.27.1", Major: "2.0.0"}},
+		{current: "1.25.2", includePrereleases: true, updates: Updates{Patch: "1.25.10", Minor: "1.27.1", Major: "3.0.0-beta.1"}},
+		{current: "1.27.1", updates: Updates{Major: "2.0.0"}},
+		{current: "2.0.0", updates: Updates{}},
+		{current: "2.0.0", includePrereleases: true, updates: Updates{Minor: "2.1.0-rc.1", Major: "3.0.0-beta.1"}},
+		{current: "1.25.3-alpine", updates: Updates{Patch: "1.25.11-alpine", Minor: "1.26.0-alpine"}},
+		{current: "1.26", updates: Updates{Minor: "1.27"}},
+		{current: "v1.29.0", updates: Updates{Minor: "v1.30.0"}},
+	}
+	for _, tt := range tests {
+		current, ok := Parse(tt.current)
+		require.True(t, ok)
+		require.Equal(t, tt.updates, FindUpdates(current, tags, tt.includePrereleases), tt.current)
+	}
+	require.Equal(t, "2.0.0", Updates{Patch: "1.25.10", Major: "2.0.0"}.Newest())
+}